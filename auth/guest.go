@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"crypto/rand"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"time"
+)
+
+// guestType is the typ claim of guest tokens distinguishing them from user tokens.
+const guestType = "guest"
+
+// GuestClaims are claims of a guest token.
+type GuestClaims struct {
+	GuestId   uuid.UUID `json:"guest_id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"typ"`
+	IssuedAt  int64     `json:"iat"`
+	ExpiresAt int64     `json:"exp"`
+}
+
+// Valid implements jwt.Claims. Claims are validated by GuestTokens.
+func (GuestClaims) Valid() error {
+	return nil
+}
+
+// GuestTokens issues and verifies tokens restoring identities of guests on reconnect.
+type GuestTokens struct {
+	key []byte
+}
+
+// NewGuestTokens creates GuestTokens signing with key, or with a random key when key is empty.
+func NewGuestTokens(key string) *GuestTokens {
+	if key != "" {
+		return &GuestTokens{key: []byte(key)}
+	}
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		// Reading fails only without a source of randomness in the OS.
+		panic(fmt.Sprintf("cannot generate guest signing key: %s", err))
+	}
+	return &GuestTokens{key: random}
+}
+
+// Issue returns a token of the guest valid for ttl.
+func (g *GuestTokens) Issue(id uuid.UUID, name string, ttl time.Duration, now time.Time) (string, time.Time, error) {
+	expires := now.Add(ttl)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, GuestClaims{
+		GuestId:   id,
+		Name:      name,
+		Type:      guestType,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expires.Unix(),
+	})
+	signed, err := token.SignedString(g.key)
+	return signed, expires, err
+}
+
+// Verify checks the signature and expiry of the guest token.
+func (g *GuestTokens) Verify(token string, now time.Time) (claims GuestClaims, err error) {
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	_, err = parser.ParseWithClaims(token, &claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+			return nil, fmt.Errorf("%w %s", ErrAlgorithm, token.Method.Alg())
+		}
+		return g.key, nil
+	})
+	if err != nil {
+		return claims, parseError(err)
+	}
+	if claims.Type != guestType || claims.GuestId == uuid.Nil || claims.ExpiresAt == 0 {
+		return claims, fmt.Errorf("%w: typ, guest_id and exp are required", ErrMissingClaims)
+	}
+	if expires := time.Unix(claims.ExpiresAt, 0); !now.Before(expires) {
+		return claims, fmt.Errorf("%w at %s", ErrExpired, expires.UTC().Format(time.RFC3339))
+	}
+	return claims, nil
+}
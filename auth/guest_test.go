@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestGuestTokens(t *testing.T) {
+	tokens := NewGuestTokens("guest-key")
+	now := time.Now()
+	id := uuid.New()
+	token, expires, err := tokens.Issue(id, "Ann", time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expires.Equal(now.Add(time.Hour)) {
+		t.Fatalf("token expires at %s", expires)
+	}
+
+	tests := []struct {
+		name   string
+		tokens *GuestTokens
+		token  string
+		now    time.Time
+		err    error
+	}{
+		{name: "restored", tokens: tokens, token: token, now: now.Add(59 * time.Minute)},
+		{name: "expired", tokens: tokens, token: token, now: now.Add(time.Hour), err: ErrExpired},
+		{name: "another key", tokens: NewGuestTokens("other-key"), token: token, now: now, err: ErrSignature},
+		{name: "random key", tokens: NewGuestTokens(""), token: token, now: now, err: ErrSignature},
+		{name: "user token", tokens: tokens, token: sign(t, jwt.SigningMethodHS256, []byte("guest-key"), "", validClaims()), now: now, err: ErrMissingClaims},
+		{name: "another algorithm", tokens: tokens, token: sign(t, jwt.SigningMethodHS512, []byte("guest-key"), "", jwt.MapClaims{
+			"guest_id": id.String(), "typ": "guest", "exp": now.Add(time.Hour).Unix()}), now: now, err: ErrAlgorithm},
+		{name: "malformed", tokens: tokens, token: "guest", now: now, err: ErrMalformed},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := tt.tokens.Verify(tt.token, tt.now)
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("error is %v, expected %v", err, tt.err)
+			}
+			if tt.err == nil && (claims.GuestId != id || claims.Name != "Ann") {
+				t.Fatalf("claims are %+v", claims)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// jwk is a public key of a JSON Web Key Set document (RFC 7517).
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA modulus and exponent.
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC curve and coordinates.
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// publicKey is a verification key with the algorithm it verifies.
+type publicKey struct {
+	id        string
+	algorithm string
+	key       interface{}
+}
+
+// errUnsupportedKey is returned for keys of types, curves and algorithms which
+// are not used by ConnectTeam. Such keys are skipped.
+var errUnsupportedKey = errors.New("unsupported key")
+
+// parseJWKS returns signing keys of the document. Keys of other uses and of
+// unsupported types, curves and algorithms are skipped, so the issuer can
+// publish them next to keys of this service. A malformed key of a supported
+// type fails the document, which must contain at least one usable signing key.
+func parseJWKS(data []byte) ([]publicKey, error) {
+	var document jwks
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("cannot decode JWKS: %w", err)
+	}
+	keys := make([]publicKey, 0, len(document.Keys))
+	for i, key := range document.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		parsed, err := key.publicKey()
+		if errors.Is(err, errUnsupportedKey) || (err == nil && key.Alg != "" && key.Alg != parsed.algorithm) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %d (kid %q): %w", i, key.Kid, err)
+		}
+		keys = append(keys, parsed)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS has no usable signing keys")
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (publicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return publicKey{}, fmt.Errorf("n: %w", err)
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return publicKey{}, fmt.Errorf("e: %w", err)
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return publicKey{}, errors.New("e: too large")
+		}
+		return publicKey{id: k.Kid, algorithm: "RS256", key: &rsa.PublicKey{N: n, E: int(e.Int64())}}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return publicKey{}, fmt.Errorf("%w: curve %q", errUnsupportedKey, k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return publicKey{}, fmt.Errorf("x: %w", err)
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return publicKey{}, fmt.Errorf("y: %w", err)
+		}
+		curve := elliptic.P256()
+		if !curve.IsOnCurve(x, y) {
+			return publicKey{}, errors.New("point is not on curve")
+		}
+		return publicKey{id: k.Kid, algorithm: "ES256", key: &ecdsa.PublicKey{Curve: curve, X: x, Y: y}}, nil
+	default:
+		return publicKey{}, fmt.Errorf("%w: type %q", errUnsupportedKey, k.Kty)
+	}
+}
+
+func decodeInt(value string) (*big.Int, error) {
+	if value == "" {
+		return nil, errors.New("is required")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// JWK returns a JSON Web Key of an RSA or ECDSA P-256 public key, e.g. to
+// publish keys signing test tokens.
+func JWK(id string, key interface{}) (json.RawMessage, error) {
+	encode := func(value *big.Int, size int) string {
+		return base64.RawURLEncoding.EncodeToString(value.FillBytes(make([]byte, size)))
+	}
+	var result jwk
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		result = jwk{Kty: "RSA", Kid: id, Use: "sig", Alg: "RS256",
+			N: encode(key.N, (key.N.BitLen()+7)/8), E: encode(big.NewInt(int64(key.E)), 3)}
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return nil, errors.New("unsupported curve")
+		}
+		result = jwk{Kty: "EC", Kid: id, Use: "sig", Alg: "ES256", Crv: "P-256",
+			X: encode(key.X, 32), Y: encode(key.Y, 32)}
+	default:
+		return nil, fmt.Errorf("unsupported key %T", key)
+	}
+	return json.Marshal(result)
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var (
+	testRSAKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+	testECKey, _  = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+)
+
+// testJWK returns the JSON Web Key of the public key.
+func testJWK(t *testing.T, kid string, key interface{}) string {
+	t.Helper()
+	data, err := JWK(kid, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// testJWKS returns a JWKS document of the keys.
+func testJWKS(keys ...string) string {
+	return `{"keys":[` + strings.Join(keys, ",") + `]}`
+}
+
+func TestParseJWKS(t *testing.T) {
+	rsaKey := testJWK(t, "rsa", &testRSAKey.PublicKey)
+	ecKey := testJWK(t, "ec", &testECKey.PublicKey)
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+
+	tests := []struct {
+		name     string
+		document string
+		kids     []string
+		err      string
+	}{
+		{name: "rsa and ec", document: testJWKS(rsaKey, ecKey), kids: []string{"rsa", "ec"}},
+		{name: "unsupported type", document: testJWKS(`{"kty":"oct","kid":"hmac","k":"c2VjcmV0"}`, rsaKey), kids: []string{"rsa"}},
+		{name: "unsupported curve", document: testJWKS(`{"kty":"EC","kid":"p384","crv":"P-384","x":"`+
+			b64(p384.X.Bytes())+`","y":"`+b64(p384.Y.Bytes())+`"}`, ecKey), kids: []string{"ec"}},
+		{name: "encryption key", document: testJWKS(strings.Replace(ecKey, `"use":"sig"`, `"use":"enc"`, 1), rsaKey), kids: []string{"rsa"}},
+		{name: "other algorithm", document: testJWKS(strings.Replace(rsaKey, `"alg":"RS256"`, `"alg":"RS512"`, 1), ecKey), kids: []string{"ec"}},
+		{name: "only unsupported keys", document: testJWKS(`{"kty":"OKP","kid":"ed","crv":"Ed25519","x":"AA"}`), err: "no usable signing keys"},
+		{name: "malformed supported key", document: testJWKS(`{"kty":"RSA","kid":"broken","n":"!","e":"AQAB"}`, ecKey), err: `kid "broken"`},
+		{name: "point not on curve", document: testJWKS(`{"kty":"EC","kid":"off","crv":"P-256","x":"AQ","y":"AQ"}`), err: "not on curve"},
+		{name: "missing exponent", document: testJWKS(`{"kty":"RSA","kid":"no-e","n":"AQAB"}`), err: "e: is required"},
+		{name: "malformed document", document: `{"keys":`, err: "cannot decode JWKS"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := parseJWKS([]byte(tt.document))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error is %v, expected %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			kids := make([]string, len(keys))
+			for i := range keys {
+				kids[i] = keys[i].id
+			}
+			if strings.Join(kids, ",") != strings.Join(tt.kids, ",") {
+				t.Fatalf("keys are %v, expected %v", kids, tt.kids)
+			}
+		})
+	}
+}
+
+func TestJWKRejectsUnsupportedKeys(t *testing.T) {
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	for _, key := range []interface{}{&p384.PublicKey, []byte("secret")} {
+		if _, err := JWK("kid", key); err == nil {
+			t.Errorf("JWK of %T is created", key)
+		}
+	}
+}
+
+func TestJWKRoundTrip(t *testing.T) {
+	keys, err := parseJWKS([]byte(testJWKS(testJWK(t, "rsa", &testRSAKey.PublicKey), testJWK(t, "ec", &testECKey.PublicKey))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsaKey, ok := keys[0].key.(*rsa.PublicKey); !ok || !rsaKey.Equal(&testRSAKey.PublicKey) || keys[0].algorithm != "RS256" {
+		t.Errorf("RSA key is %+v", keys[0])
+	}
+	if ecKey, ok := keys[1].key.(*ecdsa.PublicKey); !ok || !ecKey.Equal(&testECKey.PublicKey) || keys[1].algorithm != "ES256" {
+		t.Errorf("EC key is %+v", keys[1])
+	}
+	var document jwks
+	if err := json.Unmarshal([]byte(testJWKS(testJWK(t, "ec", &testECKey.PublicKey))), &document); err != nil || len(document.Keys[0].X) != 43 {
+		t.Errorf("coordinates are not padded to 32 bytes: %+v", document)
+	}
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,324 @@
+// Package auth verifies user tokens issued by ConnectTeam.
+//
+// Tokens are signed with HS256 and the shared auth.signing_key, or with RS256
+// and ES256 keys published in a JWKS document. Several keys may be active at
+// once, so ConnectTeam can rotate them: a token is verified with the key named
+// by its kid header, and an unknown kid reloads the document.
+package auth
+
+import (
+	"GameService/config"
+	"GameService/metrics"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reasons of rejecting a token. Errors returned by Verifier.Verify wrap one of them.
+var (
+	ErrMalformed     = errors.New("malformed token")
+	ErrAlgorithm     = errors.New("unsupported signing algorithm")
+	ErrUnknownKey    = errors.New("unknown signing key")
+	ErrSignature     = errors.New("invalid signature")
+	ErrExpired       = errors.New("token is expired")
+	ErrNotYetValid   = errors.New("token is not valid yet")
+	ErrIssuer        = errors.New("unexpected issuer")
+	ErrAudience      = errors.New("unexpected audience")
+	ErrMissingClaims = errors.New("required claims are missing")
+)
+
+var reasons = map[error]string{
+	ErrMalformed:     "malformed",
+	ErrAlgorithm:     "algorithm",
+	ErrUnknownKey:    "unknown_key",
+	ErrSignature:     "signature",
+	ErrExpired:       "expired",
+	ErrNotYetValid:   "not_yet_valid",
+	ErrIssuer:        "issuer",
+	ErrAudience:      "audience",
+	ErrMissingClaims: "missing_claims",
+}
+
+// reasonInvalid is the reason of errors not wrapping a known reason.
+const reasonInvalid = "invalid"
+
+// Reason returns a short reason of rejecting a token for metrics and responses.
+func Reason(err error) string {
+	for reason, label := range reasons {
+		if errors.Is(err, reason) {
+			return label
+		}
+	}
+	return reasonInvalid
+}
+
+// MinReloadInterval is the minimal interval between JWKS loads, so tokens with
+// random kids cannot flood the JWKS endpoint.
+var MinReloadInterval = 10 * time.Second
+
+// Claims are claims of a user token.
+type Claims struct {
+	UserId    uuid.UUID `json:"user_id"`
+	Access    string    `json:"access"`
+	Issuer    string    `json:"iss,omitempty"`
+	Audience  Audience  `json:"aud,omitempty"`
+	ExpiresAt int64     `json:"exp,omitempty"`
+	NotBefore int64     `json:"nbf,omitempty"`
+	IssuedAt  int64     `json:"iat,omitempty"`
+}
+
+// Valid implements jwt.Claims. Claims are validated by Verifier.
+func (Claims) Valid() error {
+	return nil
+}
+
+// Audience is the aud claim which is either a string or a list of strings.
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// Verifier verifies user tokens. It is safe for concurrent use.
+type Verifier struct {
+	settings config.Auth
+	client   *http.Client
+
+	mutex    sync.Mutex
+	keys     []publicKey
+	loadedAt time.Time
+	// Time of the last attempt to load keys, successful or not.
+	triedAt time.Time
+	// loading is closed when the load in progress finishes, nil when keys are not loading.
+	loading chan struct{}
+}
+
+// NewVerifier creates a Verifier of tokens signed with settings.SigningKey or
+// keys of settings.JWKSFile or settings.JWKSURL. Keys are loaded on first use.
+func NewVerifier(settings config.Auth) *Verifier {
+	return &Verifier{settings: settings, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// ParseToken returns user ID and access of a valid token.
+func (v *Verifier) ParseToken(token string) (uuid.UUID, string, error) {
+	claims, err := v.Verify(token)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	return claims.UserId, claims.Access, nil
+}
+
+// Verify checks the signature and claims of the token.
+func (v *Verifier) Verify(token string) (claims Claims, err error) {
+	defer func() {
+		if err != nil {
+			metrics.TokenRejections.WithLabelValues(Reason(err)).Inc()
+		}
+	}()
+
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	if _, err = parser.ParseWithClaims(token, &claims, v.key); err != nil {
+		return claims, parseError(err)
+	}
+	return claims, v.validate(claims, time.Now())
+}
+
+// parseError returns the reason of a jwt parsing error.
+func parseError(err error) error {
+	var validationError *jwt.ValidationError
+	switch {
+	case !errors.As(err, &validationError):
+		return fmt.Errorf("%w: %w", ErrMalformed, err)
+	case validationError.Inner != nil && Reason(validationError.Inner) != reasonInvalid:
+		return validationError.Inner
+	case validationError.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+		return ErrSignature
+	default:
+		return fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+}
+
+// validate checks time, issuer and audience claims.
+func (v *Verifier) validate(claims Claims, now time.Time) error {
+	leeway := v.settings.Leeway
+	if claims.UserId == uuid.Nil || claims.ExpiresAt == 0 {
+		return fmt.Errorf("%w: user_id and exp are required", ErrMissingClaims)
+	}
+	if expires := time.Unix(claims.ExpiresAt, 0); !now.Before(expires.Add(leeway)) {
+		return fmt.Errorf("%w at %s", ErrExpired, expires.UTC().Format(time.RFC3339))
+	}
+	if claims.NotBefore != 0 && now.Add(leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return fmt.Errorf("%w: nbf is in the future", ErrNotYetValid)
+	}
+	if claims.IssuedAt != 0 && now.Add(leeway).Before(time.Unix(claims.IssuedAt, 0)) {
+		return fmt.Errorf("%w: iat is in the future", ErrNotYetValid)
+	}
+	if v.settings.Issuer != "" && claims.Issuer != v.settings.Issuer {
+		return fmt.Errorf("%w %q", ErrIssuer, claims.Issuer)
+	}
+	if v.settings.Audience != "" && !contains(claims.Audience, v.settings.Audience) {
+		return fmt.Errorf("%w %q", ErrAudience, []string(claims.Audience))
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// key returns the key verifying the token by its algorithm and kid header.
+func (v *Verifier) key(token *jwt.Token) (interface{}, error) {
+	algorithm := token.Method.Alg()
+	switch algorithm {
+	case "HS256":
+		if v.settings.SigningKey == "" {
+			return nil, fmt.Errorf("%w %s", ErrAlgorithm, algorithm)
+		}
+		return []byte(v.settings.SigningKey), nil
+	case "RS256", "ES256":
+		if v.settings.JWKSFile == "" && v.settings.JWKSURL == "" {
+			return nil, fmt.Errorf("%w %s", ErrAlgorithm, algorithm)
+		}
+	default:
+		return nil, fmt.Errorf("%w %s", ErrAlgorithm, algorithm)
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, err := v.find(kid, algorithm, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return key.key, nil
+}
+
+// find returns the key with kid, reloading keys when they are older than the
+// refresh interval or kid is unknown. Without kid the only key of the algorithm is used.
+func (v *Verifier) find(kid string, algorithm string, now time.Time) (publicKey, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	stale := now.Sub(v.loadedAt) >= v.settings.JWKSRefreshInterval
+	if stale && v.canReload(now) {
+		v.reload(now)
+	}
+	key, err := v.lookup(kid, algorithm)
+	if errors.Is(err, ErrUnknownKey) && !stale && v.canReload(now) {
+		// The key may have been rotated since the last reload.
+		v.reload(now)
+		key, err = v.lookup(kid, algorithm)
+	}
+	return key, err
+}
+
+// canReload reports whether keys are loading or can be loaded again. v.mutex must be held.
+func (v *Verifier) canReload(now time.Time) bool {
+	return v.loading != nil || now.Sub(v.triedAt) >= MinReloadInterval
+}
+
+func (v *Verifier) lookup(kid string, algorithm string) (publicKey, error) {
+	var found []publicKey
+	for _, key := range v.keys {
+		if key.algorithm == algorithm && (kid == "" || key.id == kid) {
+			found = append(found, key)
+		}
+	}
+	switch {
+	case len(found) == 1:
+		return found[0], nil
+	case len(found) > 1:
+		return publicKey{}, fmt.Errorf("%w: token without kid matches several keys", ErrUnknownKey)
+	default:
+		return publicKey{}, fmt.Errorf("%w: kid %q, algorithm %s", ErrUnknownKey, kid, algorithm)
+	}
+}
+
+// reload replaces keys with keys of the JWKS document. Keys are kept when the
+// document cannot be loaded, so verification survives JWKS outages. v.mutex must
+// be held; it is released while the document is read, and callers finding a load
+// in progress wait for it instead of starting another one.
+func (v *Verifier) reload(now time.Time) {
+	if loading := v.loading; loading != nil {
+		v.mutex.Unlock()
+		<-loading
+		v.mutex.Lock()
+		return
+	}
+	loading := make(chan struct{})
+	v.loading = loading
+	v.triedAt = now
+	v.mutex.Unlock()
+
+	keys := v.load()
+
+	v.mutex.Lock()
+	if keys != nil {
+		v.keys = keys
+		v.loadedAt = now
+	}
+	v.loading = nil
+	close(loading)
+}
+
+// load reads and parses the JWKS document. It returns nil when the document
+// cannot be loaded or is invalid.
+func (v *Verifier) load() []publicKey {
+	source := v.settings.JWKSURL
+	if source == "" {
+		source = v.settings.JWKSFile
+	}
+	logger := logrus.WithField("jwks", source)
+
+	data, err := v.read()
+	if err != nil {
+		metrics.JWKSRefreshes.WithLabelValues(metrics.OutcomeError).Inc()
+		logger.WithError(err).Error("cannot load JWKS")
+		return nil
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		metrics.JWKSRefreshes.WithLabelValues(metrics.OutcomeInvalid).Inc()
+		logger.WithError(err).Error("invalid JWKS")
+		return nil
+	}
+	metrics.JWKSRefreshes.WithLabelValues(metrics.OutcomeOk).Inc()
+	logger.WithField("keys", len(keys)).Debug("JWKS loaded")
+	return keys
+}
+
+func (v *Verifier) read() ([]byte, error) {
+	if v.settings.JWKSURL == "" {
+		return os.ReadFile(v.settings.JWKSFile)
+	}
+	response, err := v.client.Get(v.settings.JWKSURL)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", response.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(response.Body, 1<<20))
+}
@@ -0,0 +1,280 @@
+package auth
+
+import (
+	"GameService/config"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testSigningKey = "verifier-test-key"
+
+// sign returns the token with claims signed by key with method. kid is set when not empty.
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// validClaims returns claims of a token valid for an hour.
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{"user_id": uuid.NewString(), "access": "user", "exp": time.Now().Add(time.Hour).Unix()}
+}
+
+func withClaims(changes jwt.MapClaims) jwt.MapClaims {
+	claims := validClaims()
+	for name, value := range changes {
+		if value == nil {
+			delete(claims, name)
+			continue
+		}
+		claims[name] = value
+	}
+	return claims
+}
+
+func TestVerifyClaims(t *testing.T) {
+	verifier := NewVerifier(config.Auth{
+		SigningKey: testSigningKey,
+		Issuer:     "connect-team",
+		Audience:   "game-service",
+		Leeway:     time.Minute,
+	})
+	now := time.Now()
+	issued := jwt.MapClaims{"iss": "connect-team", "aud": "game-service"}
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		err    error
+	}{
+		{name: "valid", claims: withClaims(issued)},
+		{name: "audience list", claims: withClaims(jwt.MapClaims{"iss": "connect-team", "aud": []string{"web", "game-service"}})},
+		{name: "expired within leeway", claims: withClaims(jwt.MapClaims{"iss": "connect-team", "aud": "game-service",
+			"exp": now.Add(-30 * time.Second).Unix()})},
+		{name: "expired", claims: withClaims(jwt.MapClaims{"iss": "connect-team", "aud": "game-service",
+			"exp": now.Add(-2 * time.Minute).Unix()}), err: ErrExpired},
+		{name: "not before", claims: withClaims(jwt.MapClaims{"iss": "connect-team", "aud": "game-service",
+			"nbf": now.Add(2 * time.Minute).Unix()}), err: ErrNotYetValid},
+		{name: "issued in future", claims: withClaims(jwt.MapClaims{"iss": "connect-team", "aud": "game-service",
+			"iat": now.Add(2 * time.Minute).Unix()}), err: ErrNotYetValid},
+		{name: "issuer", claims: withClaims(jwt.MapClaims{"iss": "other", "aud": "game-service"}), err: ErrIssuer},
+		{name: "missing issuer", claims: withClaims(jwt.MapClaims{"aud": "game-service"}), err: ErrIssuer},
+		{name: "audience", claims: withClaims(jwt.MapClaims{"iss": "connect-team", "aud": []string{"web"}}), err: ErrAudience},
+		{name: "missing user", claims: withClaims(jwt.MapClaims{"iss": "connect-team", "aud": "game-service", "user_id": nil}), err: ErrMissingClaims},
+		{name: "missing exp", claims: withClaims(jwt.MapClaims{"iss": "connect-team", "aud": "game-service", "exp": nil}), err: ErrMissingClaims},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			token := sign(t, jwt.SigningMethodHS256, []byte(testSigningKey), "", tt.claims)
+			claims, err := verifier.Verify(token)
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("error is %v, expected %v", err, tt.err)
+			}
+			if tt.err == nil && claims.UserId.String() != tt.claims["user_id"] {
+				t.Fatalf("user is %s, expected %s", claims.UserId, tt.claims["user_id"])
+			}
+		})
+	}
+}
+
+func TestVerifyAlgorithm(t *testing.T) {
+	hmacOnly := NewVerifier(config.Auth{SigningKey: testSigningKey})
+	jwksOnly := NewVerifier(config.Auth{JWKSFile: "testdata/missing.json"})
+	none := sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", validClaims())
+
+	tests := []struct {
+		name     string
+		verifier *Verifier
+		token    string
+		err      error
+	}{
+		{name: "none", verifier: hmacOnly, token: none, err: ErrAlgorithm},
+		{name: "HS512", verifier: hmacOnly, token: sign(t, jwt.SigningMethodHS512, []byte(testSigningKey), "", validClaims()), err: ErrAlgorithm},
+		{name: "RS256 without JWKS", verifier: hmacOnly, token: sign(t, jwt.SigningMethodRS256, testRSAKey, "rsa", validClaims()), err: ErrAlgorithm},
+		{name: "HS256 without signing key", verifier: jwksOnly, token: sign(t, jwt.SigningMethodHS256, []byte(testSigningKey), "", validClaims()), err: ErrAlgorithm},
+		{name: "another signing key", verifier: hmacOnly, token: sign(t, jwt.SigningMethodHS256, []byte("other"), "", validClaims()), err: ErrSignature},
+		{name: "malformed", verifier: hmacOnly, token: "not.a.token", err: ErrMalformed},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.verifier.Verify(tt.token); !errors.Is(err, tt.err) {
+				t.Fatalf("error is %v, expected %v", err, tt.err)
+			}
+		})
+	}
+}
+
+// jwksServer serves a JWKS document which can be replaced, counting requests.
+type jwksServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	document string
+	requests atomic.Int32
+	// release blocks responses until it is closed when not nil.
+	release chan struct{}
+}
+
+func newJWKSServer(t *testing.T, document string) *jwksServer {
+	s := &jwksServer{document: document}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		s.mutex.Lock()
+		document, release := s.document, s.release
+		s.mutex.Unlock()
+		if release != nil {
+			<-release
+		}
+		_, _ = w.Write([]byte(document))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) publish(document string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.document = document
+}
+
+// setMinReloadInterval replaces MinReloadInterval for the test.
+func setMinReloadInterval(t *testing.T, interval time.Duration) {
+	previous := MinReloadInterval
+	MinReloadInterval = interval
+	t.Cleanup(func() { MinReloadInterval = previous })
+}
+
+func TestVerifyWithJWKS(t *testing.T) {
+	setMinReloadInterval(t, 0)
+	server := newJWKSServer(t, testJWKS(testJWK(t, "rsa", &testRSAKey.PublicKey), testJWK(t, "ec", &testECKey.PublicKey)))
+	verifier := NewVerifier(config.Auth{JWKSURL: server.URL, JWKSRefreshInterval: time.Hour})
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{name: "rsa kid", token: sign(t, jwt.SigningMethodRS256, testRSAKey, "rsa", validClaims())},
+		{name: "ec kid", token: sign(t, jwt.SigningMethodES256, testECKey, "ec", validClaims())},
+		{name: "only key of algorithm", token: sign(t, jwt.SigningMethodES256, testECKey, "", validClaims())},
+		{name: "unknown kid", token: sign(t, jwt.SigningMethodRS256, testRSAKey, "retired", validClaims()), err: ErrUnknownKey},
+		{name: "kid of another algorithm", token: sign(t, jwt.SigningMethodES256, testECKey, "rsa", validClaims()), err: ErrUnknownKey},
+		{name: "another key with kid", token: sign(t, jwt.SigningMethodRS256, otherKey, "rsa", validClaims()), err: ErrSignature},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := verifier.Verify(tt.token); !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("error is %v, expected %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestVerifyRotatedKey(t *testing.T) {
+	setMinReloadInterval(t, 0)
+	server := newJWKSServer(t, testJWKS(testJWK(t, "old", &testRSAKey.PublicKey)))
+	verifier := NewVerifier(config.Auth{JWKSURL: server.URL, JWKSRefreshInterval: time.Hour})
+	if _, err := verifier.Verify(sign(t, jwt.SigningMethodRS256, testRSAKey, "old", validClaims())); err != nil {
+		t.Fatal(err)
+	}
+
+	// The issuer rotates to a new key, the unknown kid reloads the document.
+	server.publish(testJWKS(testJWK(t, "new", &testECKey.PublicKey)))
+	if _, err := verifier.Verify(sign(t, jwt.SigningMethodES256, testECKey, "new", validClaims())); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifier.Verify(sign(t, jwt.SigningMethodRS256, testRSAKey, "old", validClaims())); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("token of the retired key: %v", err)
+	}
+	if requests := server.requests.Load(); requests != 3 {
+		t.Fatalf("JWKS loaded %d times, expected 3", requests)
+	}
+
+	// Keys are kept when the document becomes invalid.
+	server.publish(`{"keys":[]}`)
+	if _, err := verifier.Verify(sign(t, jwt.SigningMethodES256, testECKey, "new", validClaims())); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadIsThrottled(t *testing.T) {
+	setMinReloadInterval(t, 10*time.Second)
+	server := newJWKSServer(t, testJWKS(testJWK(t, "rsa", &testRSAKey.PublicKey)))
+	verifier := NewVerifier(config.Auth{JWKSURL: server.URL, JWKSRefreshInterval: time.Hour})
+	start := time.Now()
+
+	tests := []struct {
+		name     string
+		kid      string
+		after    time.Duration
+		requests int32
+		err      error
+	}{
+		{name: "first use loads keys", kid: "rsa", requests: 1},
+		{name: "unknown kid within interval", kid: "random-1", after: time.Second, requests: 1, err: ErrUnknownKey},
+		{name: "another unknown kid within interval", kid: "random-2", after: 9 * time.Second, requests: 1, err: ErrUnknownKey},
+		{name: "unknown kid after interval", kid: "random-3", after: 10 * time.Second, requests: 2, err: ErrUnknownKey},
+		{name: "known kid", kid: "rsa", after: 11 * time.Second, requests: 2},
+		{name: "stale keys", kid: "rsa", after: time.Hour + 11*time.Second, requests: 3},
+	}
+	for _, tt := range tests {
+		if _, err := verifier.find(tt.kid, "RS256", start.Add(tt.after)); !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+			t.Fatalf("%s: error is %v, expected %v", tt.name, err, tt.err)
+		}
+		if requests := server.requests.Load(); requests != tt.requests {
+			t.Fatalf("%s: JWKS loaded %d times, expected %d", tt.name, requests, tt.requests)
+		}
+	}
+}
+
+func TestReloadIsSingleFlight(t *testing.T) {
+	setMinReloadInterval(t, 0)
+	server := newJWKSServer(t, testJWKS(testJWK(t, "rsa", &testRSAKey.PublicKey)))
+	server.release = make(chan struct{})
+	verifier := NewVerifier(config.Auth{JWKSURL: server.URL, JWKSRefreshInterval: time.Hour})
+	token := sign(t, jwt.SigningMethodRS256, testRSAKey, "rsa", validClaims())
+
+	const callers = 10
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			_, err := verifier.Verify(token)
+			errs <- err
+		}()
+	}
+	// Wait until every caller waits for the load in progress.
+	deadline := time.Now().Add(time.Second)
+	for server.requests.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(server.release)
+
+	for i := 0; i < callers; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests := server.requests.Load(); requests != 1 {
+		t.Fatalf("JWKS loaded %d times by concurrent callers, expected 1", requests)
+	}
+}
@@ -0,0 +1,364 @@
+// Package client is a Go client of the game WebSocket protocol served at /ws.
+//
+// A Client sends actions with typed methods and receives server messages as
+// Events whose payloads are decoded into the types of this package.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventBufferSize is the number of received events queued until they are read with Events or Next.
+var EventBufferSize = 256
+
+// ErrClosed is returned by Next after the connection is closed.
+var ErrClosed = errors.New("connection closed")
+
+// Credentials identify the connecting user. Token is a ConnectTeam user token
+// sent in Authorization header, Ticket is a single-use ticket issued with
+// IssueTicket; a guest connects with Name, or with GuestToken of a previous
+// connection sent in X-Guest-Token header to restore the identity.
+type Credentials struct {
+	Token      string
+	Ticket     string
+	Name       string
+	GuestToken string
+}
+
+// Client is a connection to the game server. Its methods can be called concurrently.
+type Client struct {
+	conn   *websocket.Conn
+	events chan Event
+	// writeMutex serializes writes as the connection supports one concurrent writer.
+	writeMutex sync.Mutex
+	mutex      sync.Mutex
+	user       User
+	guest      *GuestIdentity
+	err        error
+}
+
+// Dial connects to the game server at server, e.g. "ws://localhost:8080" or
+// "http://localhost:8080". The /ws path is used when server has no path.
+func Dial(ctx context.Context, server string, credentials Credentials) (*Client, error) {
+	address, err := wsURL(server, credentials)
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	switch {
+	case credentials.Ticket != "":
+	case credentials.Token != "":
+		header.Set("Authorization", "Bearer "+credentials.Token)
+	case credentials.GuestToken != "":
+		header.Set("X-Guest-Token", credentials.GuestToken)
+	}
+	conn, response, err := websocket.DefaultDialer.DialContext(ctx, address, header)
+	if err != nil {
+		if response != nil {
+			return nil, fmt.Errorf("cannot connect: %w (status %d)", err, response.StatusCode)
+		}
+		return nil, fmt.Errorf("cannot connect: %w", err)
+	}
+	client := &Client{
+		conn:   conn,
+		events: make(chan Event, EventBufferSize),
+		user:   User{Name: credentials.Name},
+	}
+	go client.read()
+	return client, nil
+}
+
+func wsURL(server string, credentials Credentials) (string, error) {
+	address, err := url.Parse(server)
+	if err != nil {
+		return "", err
+	}
+	switch address.Scheme {
+	case "http":
+		address.Scheme = "ws"
+	case "https":
+		address.Scheme = "wss"
+	case "ws", "wss":
+	default:
+		return "", fmt.Errorf("unsupported scheme %q", address.Scheme)
+	}
+	if address.Path == "" || address.Path == "/" {
+		address.Path = "/ws"
+	}
+	query := address.Query()
+	switch {
+	case credentials.Ticket != "":
+		query.Set("ticket", credentials.Ticket)
+	case credentials.Token != "", credentials.GuestToken != "":
+		// Tokens are sent in headers to keep them out of URLs.
+	case strings.TrimSpace(credentials.Name) != "":
+		query.Set("name", credentials.Name)
+	default:
+		return "", errors.New("token, ticket, guest token or guest name is required")
+	}
+	address.RawQuery = query.Encode()
+	return address.String(), nil
+}
+
+// IssueTicket exchanges the user token for a single-use ticket connecting to
+// the game server at server with Credentials.Ticket.
+func IssueTicket(ctx context.Context, server string, token string) (string, error) {
+	address, err := url.Parse(server)
+	if err != nil {
+		return "", err
+	}
+	switch address.Scheme {
+	case "ws":
+		address.Scheme = "http"
+	case "wss":
+		address.Scheme = "https"
+	}
+	address.Path = strings.TrimSuffix(address.Path, "/ws")
+	address.Path = strings.TrimSuffix(address.Path, "/") + "/ws/ticket"
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, address.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("cannot issue ticket: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("cannot issue ticket: status %d", response.StatusCode)
+	}
+	var body struct {
+		Ticket string `json:"ticket"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("cannot issue ticket: %w", err)
+	}
+	return body.Ticket, nil
+}
+
+// read receives frames until the connection is closed. The server batches
+// queued messages into one frame separated by newlines.
+func (c *Client) read() {
+	defer close(c.events)
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			c.mutex.Lock()
+			c.err = err
+			c.mutex.Unlock()
+			return
+		}
+		for _, line := range bytes.Split(data, []byte{'\n'}) {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			event, err := decodeEvent(line)
+			if err != nil {
+				continue
+			}
+			if event.Action == UserJoinedAction && event.Sender != nil {
+				c.mutex.Lock()
+				c.user = *event.Sender
+				c.mutex.Unlock()
+			}
+			if guest, ok := event.Data.(*GuestIdentity); ok {
+				c.mutex.Lock()
+				c.user = User{Id: guest.Id, Name: guest.Name}
+				c.guest = guest
+				c.mutex.Unlock()
+			}
+			c.events <- event
+		}
+	}
+}
+
+// User returns the user of the client. Id of a guest is known after guest-identity is received.
+func (c *Client) User() User {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.user
+}
+
+// Guest returns the identity of a guest, nil until guest-identity is received.
+// Its token restores the identity with Credentials.GuestToken.
+func (c *Client) Guest() *GuestIdentity {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.guest
+}
+
+// Events returns received events. The channel is closed when the connection is closed.
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+// Next returns the next event. It fails when ctx is done or the connection is closed.
+func (c *Client) Next(ctx context.Context) (Event, error) {
+	select {
+	case event, ok := <-c.events:
+		if !ok {
+			return Event{}, c.Err()
+		}
+		return event, nil
+	case <-ctx.Done():
+		return Event{}, ctx.Err()
+	}
+}
+
+// Err returns the error which closed the connection.
+func (c *Client) Err() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.err == nil {
+		return ErrClosed
+	}
+	return fmt.Errorf("%w: %w", ErrClosed, c.err)
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	c.writeMutex.Lock()
+	_ = c.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	c.writeMutex.Unlock()
+	return c.conn.Close()
+}
+
+// Send sends action with payload to the game. Prefer methods named after actions.
+func (c *Client) Send(action string, gameId uuid.UUID, payload interface{}) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	return c.conn.WriteJSON(message{Action: action, Target: gameId, Payload: payload})
+}
+
+// JoinGame joins the game. The server responds with join-success.
+func (c *Client) JoinGame(gameId uuid.UUID) error {
+	return c.Send(JoinGameAction, gameId, nil)
+}
+
+// JoinGameWithPasscode joins the game which requires a passcode from users who are not invited.
+func (c *Client) JoinGameWithPasscode(gameId uuid.UUID, passcode string) error {
+	return c.Send(JoinGameAction, gameId, map[string]string{"passcode": passcode})
+}
+
+// JoinGameByCode joins the game with the join code, e.g. "K7QX2M". Passcode may be empty.
+func (c *Client) JoinGameByCode(code string, passcode string) error {
+	return c.Send(JoinGameAction, uuid.Nil, map[string]string{"code": code, "passcode": passcode})
+}
+
+// SpectateGame joins the game as a spectator watching it without playing.
+func (c *Client) SpectateGame(gameId uuid.UUID) error {
+	return c.Send(JoinGameAction, gameId, map[string]bool{"spectator": true})
+}
+
+// LeaveGame leaves the game.
+func (c *Client) LeaveGame(gameId uuid.UUID) error {
+	return c.Send(LeaveGameAction, gameId, nil)
+}
+
+// SendMessage sends a chat message to players of the game.
+func (c *Client) SendMessage(gameId uuid.UUID, message interface{}) error {
+	return c.Send(SendMessageAction, gameId, message)
+}
+
+// SelectTopics selects topics of the game. Without topics random topics are selected,
+// which is the only choice on plans without custom topics.
+func (c *Client) SelectTopics(gameId uuid.UUID, topicIds ...uuid.UUID) error {
+	if len(topicIds) == 0 {
+		return c.Send(SelectTopicAction, gameId, nil)
+	}
+	return c.Send(SelectTopicAction, gameId, topicIds)
+}
+
+// StartGame starts the game.
+func (c *Client) StartGame(gameId uuid.UUID) error {
+	return c.Send(StartGameAction, gameId, nil)
+}
+
+// StartRound starts a round with questions of the topic.
+func (c *Client) StartRound(gameId uuid.UUID, topicId uuid.UUID) error {
+	return c.Send(StartRoundAction, gameId, topicId.String())
+}
+
+// StartStage moves the game to the next respondent, the end of the round or the end of the game.
+func (c *Client) StartStage(gameId uuid.UUID) error {
+	return c.Send(StartStageAction, gameId, nil)
+}
+
+// StartStageWithAnswerTime starts the next stage like StartStage and ends the answer of
+// the respondent after answerTime. It requires the timers entitlement.
+func (c *Client) StartStageWithAnswerTime(gameId uuid.UUID, answerTime time.Duration) error {
+	return c.Send(StartStageAction, gameId, map[string]interface{}{"answer_time": int(answerTime / time.Second)})
+}
+
+// StartAnswer notifies players that the respondent started answering.
+func (c *Client) StartAnswer(gameId uuid.UUID) error {
+	return c.Send(UserStartAnswerAction, gameId, nil)
+}
+
+// EndAnswer notifies players that the respondent finished answering.
+func (c *Client) EndAnswer(gameId uuid.UUID) error {
+	return c.Send(UserEndAnswerAction, gameId, nil)
+}
+
+// Rate rates the answer of the user.
+func (c *Client) Rate(gameId uuid.UUID, userId uuid.UUID, value int, tags ...uuid.UUID) error {
+	if tags == nil {
+		tags = []uuid.UUID{}
+	}
+	return c.Send(RateAction, gameId, Rating{Value: value, UserId: userId, Tags: tags})
+}
+
+// EndGame aborts the game without saving results.
+func (c *Client) EndGame(gameId uuid.UUID) error {
+	return c.Send(EndGameAction, gameId, nil)
+}
+
+// AddBot adds a bot player to the game in lobby. A default name is used when name is empty.
+func (c *Client) AddBot(gameId uuid.UUID, name string) error {
+	return c.Send(AddBotAction, gameId, name)
+}
+
+// DeleteUser removes the user from the game. Bots are removed in the same way.
+func (c *Client) DeleteUser(gameId uuid.UUID, userId uuid.UUID) error {
+	return c.Send(DeleteUserAction, gameId, userId.String())
+}
+
+// SetRole promotes the player to RoleCoHost or demotes a co-host to RolePlayer.
+func (c *Client) SetRole(gameId uuid.UUID, userId uuid.UUID, role Role) error {
+	return c.Send(SetRoleAction, gameId, RoleChange{UserId: userId, Role: role})
+}
+
+// AdmitGuest lets the guest waiting for approval join the game, or denies joining when admit is false.
+func (c *Client) AdmitGuest(gameId uuid.UUID, userId uuid.UUID, admit bool) error {
+	return c.Send(AdmitGuestAction, gameId, map[string]interface{}{"user_id": userId, "admit": admit})
+}
+
+// ExportResults requests results of the ended game, sent back with export-results.
+// It requires the exports entitlement.
+func (c *Client) ExportResults(gameId uuid.UUID) error {
+	return c.Send(ExportResultsAction, gameId, nil)
+}
+
+// decodeEvent decodes a message and its payload.
+func decodeEvent(data []byte) (Event, error) {
+	var event Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		return event, err
+	}
+	event.Data, event.DecodeErr = event.decodePayload()
+	return event, nil
+}
@@ -0,0 +1,183 @@
+package client
+
+import (
+	"GameService/repository/models"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// Event is a message received from the server.
+type Event struct {
+	Action string    `json:"action"`
+	Target uuid.UUID `json:"target"`
+	Sender *User     `json:"sender"`
+	Time   time.Time `json:"time"`
+	// Payload is the raw payload, see Data for the decoded one.
+	Payload json.RawMessage `json:"payload"`
+	// Data is the payload decoded by action:
+	//   - join-success, join-game: *Game
+	//   - select-topic, round-end: []Topic
+	//   - start-game: *GameStarted
+	//   - start-round: []UserQuestion
+	//   - start-stage: *UserQuestion
+	//   - rate-user: *Rating
+	//   - user-left, user-deleted, game-abort: uuid.UUID, Nil when the payload is empty
+	//   - game-end: *models.GetResultsResponse
+	//   - game-settings-changed: *SettingsChanged
+	//   - announcement: string
+	//   - set-role: *RoleChange
+	//   - join-request: *User
+	//   - guest-identity: *GuestIdentity
+	//   - export-results: *ResultsExport
+	//   - error: *ServerError
+	// Data is nil for other actions and payloads which cannot be decoded.
+	Data interface{} `json:"-"`
+	// DecodeErr is the error of decoding Data.
+	DecodeErr error `json:"-"`
+}
+
+// Decode unmarshalls the raw payload into v.
+func (e Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+// ServerError returns the error sent by the server or nil.
+func (e Event) ServerError() *ServerError {
+	err, _ := e.Data.(*ServerError)
+	return err
+}
+
+// Game is the state of a game sent on join and start.
+type Game struct {
+	Id           uuid.UUID           `json:"id"`
+	Name         string              `json:"name"`
+	MaxSize      int                 `json:"max_size"`
+	Status       string              `json:"status"`
+	Creator      uuid.UUID           `json:"creator_id"`
+	CoHosts      []uuid.UUID         `json:"co_hosts"`
+	JoinCode     string              `json:"join_code"`
+	Topics       []Topic             `json:"topics"`
+	Round        *Round              `json:"round"`
+	Users        []*User             `json:"users"`
+	Entitlements models.Entitlements `json:"entitlements"`
+}
+
+// GameStarted is sent to every player when the game starts. Token is the meeting
+// token of the player, the creator receives a host token.
+type GameStarted struct {
+	Game          *Game  `json:"game"`
+	MeetingNumber string `json:"meeting_number"`
+	Passcode      string `json:"passcode"`
+	Token         string `json:"token"`
+}
+
+// Rating is a rate of the answer of the user.
+type Rating struct {
+	Value  int         `json:"value"`
+	UserId uuid.UUID   `json:"user_id"`
+	Tags   []uuid.UUID `json:"tags"`
+}
+
+// RoleChange is sent when the role of a player changes.
+type RoleChange struct {
+	UserId uuid.UUID `json:"user_id"`
+	Role   Role      `json:"role"`
+}
+
+// SettingsChanged is sent when entitlements of the game change.
+type SettingsChanged struct {
+	MaxSize      int                 `json:"max_size"`
+	Entitlements models.Entitlements `json:"entitlements"`
+	Topics       []Topic             `json:"topics"`
+	// OverLimit lists players seated above MaxSize after a downgrade.
+	OverLimit []uuid.UUID `json:"over_limit,omitempty"`
+}
+
+// ResultsExport is the response of export-results. Data is CSV with columns
+// name, user_id, value and tags separated by semicolons.
+type ResultsExport struct {
+	Format string `json:"format"`
+	Data   string `json:"data"`
+}
+
+// ServerError is an error sent by the server in response to an action.
+type ServerError struct {
+	Code    int    `json:"code"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message"`
+	// Permission missing for the action when Reason is PermissionDenied.
+	Permission string `json:"permission,omitempty"`
+}
+
+func (e *ServerError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("error %d (%s): %s", e.Code, e.Reason, e.Message)
+	}
+	return fmt.Sprintf("error %d: %s", e.Code, e.Message)
+}
+
+func (e Event) decodePayload() (interface{}, error) {
+	if len(e.Payload) == 0 || string(e.Payload) == "null" {
+		switch e.Action {
+		case UserLeftAction, UserDeletedAction, GameAbortedAction:
+			return uuid.Nil, nil
+		}
+		return nil, nil
+	}
+	switch e.Action {
+	case UserJoinedAction, JoinGameAction:
+		return decode[Game](e.Payload)
+	case SelectTopicAction, RoundEndAction:
+		return decodeValue[[]Topic](e.Payload)
+	case StartGameAction:
+		return decode[GameStarted](e.Payload)
+	case StartRoundAction:
+		return decodeValue[[]UserQuestion](e.Payload)
+	case StartStageAction:
+		return decode[UserQuestion](e.Payload)
+	case RateAction:
+		return decode[Rating](e.Payload)
+	case UserLeftAction, UserDeletedAction, GameAbortedAction:
+		return decodeValue[uuid.UUID](e.Payload)
+	case GameEndedAction:
+		return decode[models.GetResultsResponse](e.Payload)
+	case GameSettingsChangedAction:
+		return decode[SettingsChanged](e.Payload)
+	case AnnouncementAction:
+		return decodeValue[string](e.Payload)
+	case SetRoleAction:
+		return decode[RoleChange](e.Payload)
+	case JoinRequestAction:
+		return decode[User](e.Payload)
+	case GuestIdentityAction:
+		return decode[GuestIdentity](e.Payload)
+	case ExportResultsAction:
+		return decode[ResultsExport](e.Payload)
+	case ErrorAction:
+		// Some errors are sent with a bare code.
+		var code int
+		if json.Unmarshal(e.Payload, &code) == nil {
+			return &ServerError{Code: code}, nil
+		}
+		return decode[ServerError](e.Payload)
+	}
+	return nil, nil
+}
+
+func decode[T any](payload json.RawMessage) (interface{}, error) {
+	v := new(T)
+	if err := json.Unmarshal(payload, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func decodeValue[T any](payload json.RawMessage) (interface{}, error) {
+	var v T
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Actions of the game protocol. Clients send actions with the methods of Client,
+// the server sends them back in Event.Action.
+const (
+	JoinGameAction            = "join-game"
+	LeaveGameAction           = "leave-game"
+	SendMessageAction         = "send-message"
+	SelectTopicAction         = "select-topic"
+	StartGameAction           = "start-game"
+	StartRoundAction          = "start-round"
+	StartStageAction          = "start-stage"
+	UserStartAnswerAction     = "start-answer"
+	UserEndAnswerAction       = "end-answer"
+	RateAction                = "rate-user"
+	EndGameAction             = "end-game"
+	DeleteUserAction          = "delete-user"
+	AddBotAction              = "add-bot"
+	SetRoleAction             = "set-role"
+	AdmitGuestAction          = "admit-guest"
+	UserJoinedAction          = "join-success"
+	RoundEndAction            = "round-end"
+	RateEndAction             = "rate-end"
+	GameEndedAction           = "game-end"
+	UserDeletedAction         = "user-deleted"
+	UserLeftAction            = "user-left"
+	GameAbortedAction         = "game-abort"
+	AnnouncementAction        = "announcement"
+	GameSettingsChangedAction = "game-settings-changed"
+	GuestIdentityAction       = "guest-identity"
+	WaitingRoomAction         = "waiting-room"
+	JoinRequestAction         = "join-request"
+	ExportResultsAction       = "export-results"
+	ErrorAction               = "error"
+)
+
+// Reasons of ServerError.
+const (
+	PermissionDenied = "permission-denied"
+	FeatureNotInPlan = "feature-not-in-plan"
+	RateLimited      = "rate-limited"
+	InvalidName      = "invalid-name"
+	DuplicateName    = "duplicate-name"
+	NotInvited       = "not-invited"
+	InvalidPasscode  = "invalid-passcode"
+	JoinDenied       = "join-denied"
+)
+
+// Role of a client in a game, see README for the permissions of roles.
+type Role string
+
+const (
+	RoleAdmin     Role = "admin"
+	RoleCreator   Role = "creator"
+	RoleCoHost    Role = "co-host"
+	RolePlayer    Role = "player"
+	RoleSpectator Role = "spectator"
+	RoleGuest     Role = "guest"
+)
+
+// User is a player of a game or the sender of a message.
+type User struct {
+	Id   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	Bot  bool      `json:"bot,omitempty"`
+}
+
+// GuestIdentity identifies a guest across reconnects until it expires.
+type GuestIdentity struct {
+	Id        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Topic of questions. Used is set when a round with the topic was played.
+type Topic struct {
+	Id        uuid.UUID  `json:"id"`
+	Used      bool       `json:"used"`
+	Title     string     `json:"title,omitempty"`
+	Questions []Question `json:"questions,omitempty"`
+}
+
+type Question struct {
+	Id      uuid.UUID `json:"id"`
+	TopicId uuid.UUID `json:"topic_id"`
+	Content string    `json:"content"`
+	Tags    []Tag     `json:"tags"`
+}
+
+type Tag struct {
+	Id   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+// UserQuestion is the question the user answers in a round.
+type UserQuestion struct {
+	Number   int      `json:"number"`
+	User     User     `json:"user"`
+	Question Question `json:"question"`
+	// Answer time limit of the stage in seconds, zero when the answer is not limited.
+	AnswerTime int `json:"answer_time,omitempty"`
+}
+
+// Round is the round in progress.
+type Round struct {
+	Topic          uuid.UUID       `json:"topic"`
+	UsersQuestions []*UserQuestion `json:"users-questions"`
+}
+
+// message is an action sent to the server.
+type message struct {
+	Action  string      `json:"action"`
+	Payload interface{} `json:"payload,omitempty"`
+	Target  uuid.UUID   `json:"target"`
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"GameService/contract"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"os"
+)
+
+// Runs the contract suite against recorded ConnectTeam and Zoom responses, or serves
+// them as a stand-in of ConnectTeam for local development with -serve.
+func main() {
+	serve := flag.String("serve", "", "serve recorded interactions on the address instead of running checks")
+	flag.Parse()
+
+	stub, err := contract.NewStub()
+	if err != nil {
+		logrus.Fatalf("cannot load fixtures: %s", err.Error())
+	}
+
+	if *serve != "" {
+		logrus.WithField("addr", *serve).Infof("serving %d recorded interactions, api key %q",
+			len(stub.Interactions()), contract.APIKey)
+		logrus.Fatal(http.ListenAndServe(*serve, stub))
+	}
+
+	logrus.SetLevel(logrus.FatalLevel)
+	failed := 0
+	for _, result := range contract.Run(context.Background(), stub) {
+		if result.Err != nil {
+			failed++
+			fmt.Printf("FAIL %s: %s\n", result.Name, result.Err)
+			continue
+		}
+		fmt.Printf("PASS %s\n", result.Name)
+	}
+	if failed > 0 {
+		fmt.Printf("%d checks failed\n", failed)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"GameService/client"
+	"GameService/consts/plan_types"
+	"GameService/e2e"
+	"GameService/game"
+	"fmt"
+	"github.com/google/uuid"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+)
+
+// simulation plays one game with simulated players.
+type simulation struct {
+	h       *e2e.Harness
+	stats   *stats
+	think   time.Duration
+	id      uuid.UUID
+	host    *e2e.Player
+	players []*e2e.Player
+	// lastStage is the respondent of the last start-stage received by the host.
+	lastStage *client.UserQuestion
+}
+
+// pause waits a random think time between a half and one and a half of think.
+func (s *simulation) pause() {
+	if s.think <= 0 {
+		return
+	}
+	time.Sleep(s.think/2 + time.Duration(rand.Int63n(int64(s.think)+1)))
+}
+
+// play connects players, plays every round and disconnects the players.
+func (s *simulation) play(players int) error {
+	defer func() {
+		for _, player := range s.players {
+			player.Close()
+			s.stats.disconnect()
+		}
+	}()
+
+	user := s.h.Repository.AddUser("Host", plan_types.Basic)
+	s.id = s.h.Repository.AddGame("loadtest", user.Id).Id
+	host, err := s.h.ConnectUser(user)
+	s.stats.connect(err)
+	if err != nil {
+		return err
+	}
+	s.host = host
+	s.players = append(s.players, host)
+	if err := s.join(host); err != nil {
+		return err
+	}
+	for i := 1; i < players; i++ {
+		player, err := s.h.ConnectGuest(fmt.Sprintf("Player %d", i))
+		s.stats.connect(err)
+		if err != nil {
+			return err
+		}
+		s.players = append(s.players, player)
+		if err := s.join(player); err != nil {
+			return err
+		}
+	}
+
+	s.pause()
+	sent := time.Now()
+	if err := s.host.SelectTopics(s.id); err != nil {
+		return err
+	}
+	selected, err := s.expect(s.host, game.SelectTopicAction)
+	if err != nil {
+		return err
+	}
+	s.stats.latency(game.SelectTopicAction, time.Since(sent))
+	topics, _ := selected.Data.([]client.Topic)
+	if len(topics) == 0 {
+		return fmt.Errorf("game %s: no topics selected", s.id)
+	}
+
+	if err := s.broadcast(s.host, game.StartGameAction, s.host.StartGame, game.StartGameAction); err != nil {
+		return err
+	}
+	for i, topic := range topics {
+		topicId := topic.Id
+		if err := s.broadcast(s.host, game.StartRoundAction, func(id uuid.UUID) error {
+			return s.host.StartRound(id, topicId)
+		}, game.StartRoundAction); err != nil {
+			return err
+		}
+		for range s.players {
+			if err := s.stage(); err != nil {
+				return err
+			}
+		}
+		last := i == len(topics)-1
+		expected := game.RoundEndAction
+		if last {
+			expected = game.GameEndedAction
+		}
+		// The last start-stage of a round is measured as round-end or game-end.
+		if err := s.broadcast(s.host, expected, s.host.StartStage, expected); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// join joins the last connected player to the game and waits until players
+// who joined before are notified.
+func (s *simulation) join(player *e2e.Player) error {
+	s.pause()
+	sent := time.Now()
+	if err := player.JoinGame(s.id); err != nil {
+		return err
+	}
+	if _, err := s.expect(player, game.UserJoinedAction); err != nil {
+		return err
+	}
+	s.stats.latency(game.JoinGameAction, time.Since(sent))
+	received, err := s.receive(s.players[:len(s.players)-1], sent, game.JoinGameAction)
+	if err != nil || len(received) == 0 {
+		return err
+	}
+	s.stats.fanOut(game.JoinGameAction, slices.Max(received))
+	return nil
+}
+
+// stage lets the next respondent answer and other players rate the answer.
+func (s *simulation) stage() error {
+	if err := s.broadcast(s.host, game.StartStageAction, s.host.StartStage, game.StartStageAction); err != nil {
+		return err
+	}
+	respondent := s.lastStage
+	if respondent == nil {
+		return fmt.Errorf("game %s: start-stage without respondent", s.id)
+	}
+	var answering *e2e.Player
+	for _, player := range s.players {
+		if player.User().Id == respondent.User.Id {
+			answering = player
+		}
+	}
+	if answering == nil {
+		return fmt.Errorf("game %s: respondent %s is not a player", s.id, respondent.User.Id)
+	}
+	if err := s.broadcast(answering, game.UserStartAnswerAction, answering.StartAnswer, game.UserStartAnswerAction); err != nil {
+		return err
+	}
+	if err := s.broadcast(answering, game.UserEndAnswerAction, answering.EndAnswer, game.UserEndAnswerAction); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(s.players))
+	for i, player := range s.players {
+		if player == answering {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, player *e2e.Player) {
+			defer wg.Done()
+			s.pause()
+			errs[i] = player.Rate(s.id, answering.User().Id, 1+rand.Intn(5))
+		}(i, player)
+	}
+	wg.Wait()
+	sent := time.Now()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	expected := make([]string, 0, len(s.players))
+	for range s.players[1:] {
+		expected = append(expected, game.RateAction)
+	}
+	return s.await(nil, game.RateAction, sent, append(expected, game.RateEndAction)...)
+}
+
+// broadcast waits a think time, sends the action and waits until every player
+// receives expected messages.
+func (s *simulation) broadcast(sender *e2e.Player, action string, send func(uuid.UUID) error, expected ...string) error {
+	s.pause()
+	sent := time.Now()
+	if err := send(s.id); err != nil {
+		return err
+	}
+	return s.await(sender, action, sent, expected...)
+}
+
+// await waits until every player receives expected messages and records the
+// latency of the sender, or of the first player without a sender, and the fan-out
+// to the last player.
+func (s *simulation) await(sender *e2e.Player, action string, sent time.Time, expected ...string) error {
+	received, err := s.receive(s.players, sent, expected...)
+	if err != nil {
+		return err
+	}
+	latency, last := received[0], received[0]
+	for i, d := range received {
+		if sender == nil {
+			latency = min(latency, d)
+		} else if s.players[i] == sender {
+			latency = d
+		}
+		last = max(last, d)
+	}
+	s.stats.latency(action, latency)
+	s.stats.fanOut(action, last)
+	return nil
+}
+
+// receive waits until every recipient receives expected messages and returns
+// time elapsed since sent for each of them.
+func (s *simulation) receive(recipients []*e2e.Player, sent time.Time, expected ...string) ([]time.Duration, error) {
+	var wg sync.WaitGroup
+	received := make([]time.Duration, len(recipients))
+	errs := make([]error, len(recipients))
+	for i, player := range recipients {
+		wg.Add(1)
+		go func(i int, player *e2e.Player) {
+			defer wg.Done()
+			for _, action := range expected {
+				event, err := s.expect(player, action)
+				if err != nil {
+					errs[i] = err
+					return
+				}
+				if player == s.host && action == game.StartStageAction {
+					s.lastStage, _ = event.Data.(*client.UserQuestion)
+				}
+			}
+			received[i] = time.Since(sent)
+		}(i, player)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return received, nil
+}
+
+// expect returns the next message of the player and records server errors.
+func (s *simulation) expect(player *e2e.Player, action string) (client.Event, error) {
+	event, err := player.Expect(action)
+	if serverError := event.ServerError(); serverError != nil {
+		s.stats.serverError(serverError.Code)
+	}
+	if err != nil {
+		return event, fmt.Errorf("game %s: %w", s.id, err)
+	}
+	return event, nil
+}
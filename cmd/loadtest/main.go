@@ -0,0 +1,78 @@
+package main
+
+import (
+	"GameService/consts/plan_types"
+	"GameService/e2e"
+	"GameService/repository/fake"
+	"flag"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"os"
+	"sync"
+	"time"
+)
+
+// upstreamCalls are repository calls slowed down with -upstream-latency.
+var upstreamCalls = []string{"GetGame", "GetGameAccess", "StartGame", "EndGame", "SaveResults", "GetResults", "GetUserById",
+	"GetCreatorPlan", "GetTopic", "GetRandTopicsWithLimit", "GetRandQuestionsWithLimit"}
+
+// Plays concurrent games against an in-memory game server backed by the fake
+// repository and reports connections, action latencies, broadcast fan-out and errors.
+func main() {
+	games := flag.Int("games", 10, "number of concurrent games")
+	players := flag.Int("players", 4, "number of players in a game including the host")
+	rounds := flag.Int("rounds", 1, "number of rounds in a game")
+	think := flag.Duration("think", 100*time.Millisecond, "mean think time of a player before an action")
+	ramp := flag.Duration("ramp", time.Second, "time over which games are started")
+	latency := flag.Duration("upstream-latency", 0, "latency of every ConnectTeam call")
+	timeout := flag.Duration("timeout", 30*time.Second, "max time to wait for a message")
+	verbose := flag.Bool("v", false, "print server logs")
+	flag.Parse()
+
+	if *games < 1 || *players < 2 || *rounds < 1 {
+		fmt.Fprintln(os.Stderr, "games and rounds must be positive, players must be at least 2")
+		os.Exit(2)
+	}
+	if !*verbose {
+		logrus.SetLevel(logrus.FatalLevel)
+	}
+	e2e.ExpectTimeout = *timeout
+
+	settings := e2e.DefaultSettings()
+	basic := settings.Plans[plan_types.Basic]
+	basic.MaxPlayers = *players
+	basic.TopicCount = *rounds
+	settings.Plans[plan_types.Basic] = basic
+	settings.WebSocket.ActionTimeout = *timeout
+	h := e2e.NewWithSettings(settings)
+	defer h.Close()
+	for i := 0; i < *rounds; i++ {
+		h.Repository.AddTopic(fmt.Sprintf("Topic %d", i+1), 2**players)
+	}
+	if *latency > 0 {
+		for _, call := range upstreamCalls {
+			h.Repository.Inject(call, fake.Fault{Latency: *latency})
+		}
+	}
+
+	s := newStats()
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < *games; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			simulation := &simulation{h: h, stats: s, think: *think}
+			s.game(simulation.play(*players))
+		}()
+		if *games > 1 {
+			time.Sleep(*ramp / time.Duration(*games-1))
+		}
+	}
+	wg.Wait()
+
+	s.report(os.Stdout, time.Since(start))
+	if s.failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxFailures is the number of game failures kept for the report.
+const maxFailures = 10
+
+// stats collects measurements of all simulated games.
+type stats struct {
+	mutex        sync.Mutex
+	latencies    map[string][]time.Duration
+	fanOuts      map[string][]time.Duration
+	serverErrors map[int]int
+	failures     []string
+	completed    int
+	failed       int
+
+	connections       atomic.Int64
+	connectionsFailed atomic.Int64
+	connected         atomic.Int64
+	peak              atomic.Int64
+}
+
+func newStats() *stats {
+	return &stats{
+		latencies:    make(map[string][]time.Duration),
+		fanOuts:      make(map[string][]time.Duration),
+		serverErrors: make(map[int]int),
+	}
+}
+
+// connect records a connection attempt.
+func (s *stats) connect(err error) {
+	if err != nil {
+		s.connectionsFailed.Add(1)
+		return
+	}
+	s.connections.Add(1)
+	connected := s.connected.Add(1)
+	for {
+		peak := s.peak.Load()
+		if connected <= peak || s.peak.CompareAndSwap(peak, connected) {
+			return
+		}
+	}
+}
+
+func (s *stats) disconnect() {
+	s.connected.Add(-1)
+}
+
+// latency records time from sending the action until the sender receives the response.
+func (s *stats) latency(action string, d time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.latencies[action] = append(s.latencies[action], d)
+}
+
+// fanOut records time from sending the action until every player receives the broadcast.
+func (s *stats) fanOut(action string, d time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.fanOuts[action] = append(s.fanOuts[action], d)
+}
+
+func (s *stats) serverError(code int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.serverErrors[code]++
+}
+
+// game records the outcome of a game.
+func (s *stats) game(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err == nil {
+		s.completed++
+		return
+	}
+	s.failed++
+	if len(s.failures) < maxFailures {
+		s.failures = append(s.failures, err.Error())
+	}
+}
+
+// report writes the summary of the run.
+func (s *stats) report(w io.Writer, elapsed time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	total := s.completed + s.failed
+	fmt.Fprintf(w, "games: %d completed, %d failed (%.1f%% errors) in %s\n",
+		s.completed, s.failed, percent(s.failed, total), elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "connections: %d opened, %d failed, %d peak concurrent\n",
+		s.connections.Load(), s.connectionsFailed.Load(), s.peak.Load())
+
+	actions := 0
+	for _, latencies := range s.latencies {
+		actions += len(latencies)
+	}
+	errors := 0
+	for _, count := range s.serverErrors {
+		errors += count
+	}
+	fmt.Fprintf(w, "actions: %d, %.1f/s, %d server errors (%.1f%%)\n",
+		actions, float64(actions)/elapsed.Seconds(), errors, percent(errors, actions))
+
+	writeTable(w, "action latency", s.latencies)
+	writeTable(w, "broadcast fan-out", s.fanOuts)
+	writeServerMetrics(w)
+
+	if len(s.serverErrors) > 0 {
+		codes := make([]int, 0, len(s.serverErrors))
+		for code := range s.serverErrors {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		fmt.Fprintln(w, "\nserver errors:")
+		for _, code := range codes {
+			fmt.Fprintf(w, "  code %d: %d\n", code, s.serverErrors[code])
+		}
+	}
+	if len(s.failures) > 0 {
+		fmt.Fprintln(w, "\nfailures:")
+		for _, failure := range s.failures {
+			fmt.Fprintf(w, "  %s\n", failure)
+		}
+	}
+}
+
+func writeTable(w io.Writer, title string, samples map[string][]time.Duration) {
+	actions := make([]string, 0, len(samples))
+	for action := range samples {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+
+	fmt.Fprintf(w, "\n%-24s %8s %10s %10s %10s %10s\n", title, "count", "p50", "p95", "p99", "max")
+	for _, action := range actions {
+		durations := samples[action]
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		fmt.Fprintf(w, "  %-22s %8d %10s %10s %10s %10s\n", action, len(durations),
+			quantile(durations, 0.5), quantile(durations, 0.95), quantile(durations, 0.99), quantile(durations, 1))
+	}
+}
+
+// writeServerMetrics writes averages of histograms observed by the game server.
+func writeServerMetrics(w io.Writer) {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(w, "\nserver metrics:")
+	for _, family := range families {
+		name := family.GetName()
+		if !strings.HasSuffix(name, "broadcast_duration_seconds") && !strings.HasSuffix(name, "broadcast_recipients") &&
+			!strings.HasSuffix(name, "send_queue_depth") {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			histogram := metric.GetHistogram()
+			if histogram == nil || histogram.GetSampleCount() == 0 {
+				continue
+			}
+			fmt.Fprintf(w, "  %-40s count %d, avg %.6g\n", name, histogram.GetSampleCount(),
+				histogram.GetSampleSum()/float64(histogram.GetSampleCount()))
+		}
+	}
+}
+
+func quantile(sorted []time.Duration, q float64) time.Duration {
+	return sorted[int(q*float64(len(sorted)-1))].Round(time.Microsecond)
+}
+
+func percent(part int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(part) / float64(total)
+}
@@ -1,36 +1,85 @@
 package main
 
 import (
+	"GameService/config"
 	"GameService/game"
+	"GameService/health"
+	"GameService/logging"
+	"GameService/metrics"
+	"GameService/repository/cache"
+	"GameService/repository/local"
 	"GameService/repository/requests"
+	"GameService/tracing"
+	"context"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
 	"net/http"
-	"os"
 	"sync"
+	"time"
 )
 
 func main() {
 
-	if err := initConfig(); err != nil {
-		logrus.Fatalf("error")
+	if err := godotenv.Load(); err != nil {
+		logrus.Warnf("cannot load .env: %s", err.Error())
 	}
 
-	if err := godotenv.Load(); err != nil {
-		logrus.Fatalf("error")
+	cfg, err := config.Load()
+	if err != nil {
+		logrus.Fatalf(err.Error())
 	}
 
-	logrus.Println("Starting...")
+	if err := logging.Setup(cfg.Log.Level, cfg.Log.Format); err != nil {
+		logrus.Fatalf("cannot setup logging: %s", err.Error())
+	}
 
-	zoomSDKKey := os.Getenv("ZOOM_SDK_KEY")
-	zoomSDKSecret := os.Getenv("ZOOM_SDK_SECRET")
-	httpService := requests.NewHTTPService(os.Getenv("HTTP_SERVICE_API_KEY"),
-		os.Getenv("ZOOM_API_ACCESS_TOKEN"),
-		os.Getenv("ZOOM_API_REFRESH_TOKEN"), zoomSDKKey, zoomSDKSecret)
-	generator := game.NewJWTGenerator(zoomSDKKey, zoomSDKSecret)
+	logrus.Info("Starting...")
+
+	shutdownTracing, err := tracing.Setup(context.Background(),
+		cfg.Tracing.Exporter, cfg.Tracing.Endpoint, "game-service")
+	if err != nil {
+		logrus.Fatalf("cannot setup tracing: %s", err.Error())
+	}
+	defer shutdownTracing(context.Background())
+
+	var httpService *requests.Repository
+	switch cfg.Repository.Mode {
+	case config.RepositoryModeLocal:
+		logrus.WithField("data_dir", cfg.Repository.DataDir).Warn("running offline with local repository")
+		httpService, err = local.NewRepository(cfg)
+		if err != nil {
+			logrus.Fatalf(err.Error())
+		}
+	default:
+		httpService = requests.NewHTTPService(cfg)
+		if cfg.Cache.Enabled {
+			httpService = cache.Wrap(httpService, cfg.Cache)
+		}
+	}
+	generator := game.NewJWTGenerator(cfg.Zoom.SDKKey, cfg.Zoom.SDKSecret)
+
+	wsServer := game.NewWebsocketServer(httpService, generator, gameSettings(cfg))
+	config.Watch(cfg, func(cfg *config.Config) {
+		wsServer.UpdateSettings(gameSettings(cfg))
+	})
+
+	metrics.RegisterGameStats(func() (int, map[string]int) {
+		stats := wsServer.Stats()
+		return stats.Clients, stats.Games
+	})
+
+	checker := health.NewChecker(5*time.Second, 10*time.Second, func() interface{} {
+		return wsServer.Stats()
+	})
+	checker.AddLivenessCheck("ws_server", wsServer.Ping)
+	if cfg.Repository.Mode == config.RepositoryModeHTTP {
+		checker.AddReadinessCheck("connect_team", health.HTTPCheck(cfg.Upstream.ConnectTeamURL))
+		checker.AddReadinessCheck("meeting_provider", health.HTTPCheck(cfg.Zoom.APIURL))
+	}
+
+	go wsServer.RevalidateEntitlements()
 
-	wsServer := game.NewWebsocketServer(httpService, generator)
 	var wg sync.WaitGroup
 	wg.Add(2)
 
@@ -44,22 +93,33 @@ func main() {
 		http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 			game.ServeWs(wsServer, w, r)
 		})
-		port := viper.GetString("port")
-		host := viper.GetString("host")
-		addr := host + ":" + port
+		http.Handle("/ws/ticket", game.NewTicketHandler(wsServer))
+		http.HandleFunc("/healthz", checker.LiveHandler)
+		http.HandleFunc("/readyz", checker.ReadyHandler)
+		http.Handle("/metrics", promhttp.Handler())
+		http.Handle("/admin/", game.NewAdminHandler(wsServer, cfg.Admin.APIKey))
+		http.Handle("/internal/plan-changed", game.NewPlanChangeHandler(wsServer, cfg.Upstream.WebhookKey))
+		addr := cfg.Server.Addr()
+		logrus.WithField("addr", addr).Info("ListenAndServe")
 		err := http.ListenAndServe(addr, nil)
 		if err != nil {
 			logrus.Fatalf(err.Error())
 		}
-		logrus.Println("ListenAndServe :8080")
 	}()
 
 	wg.Wait()
 
 }
 
-func initConfig() error {
-	viper.AddConfigPath("config")
-	viper.SetConfigName("config")
-	return viper.ReadInConfig()
+func gameSettings(cfg *config.Config) game.Settings {
+	return game.Settings{
+		WebSocket:                      cfg.WebSocket,
+		Plans:                          cfg.Plans,
+		EntitlementsSource:             cfg.Entitlements.Source,
+		EntitlementsRevalidateInterval: cfg.Entitlements.RevalidateInterval,
+		Features:                       cfg.Features,
+		Bots:                           cfg.Bots,
+		RateLimit:                      cfg.RateLimit,
+		Guests:                         cfg.Guests,
+	}
 }
@@ -0,0 +1,562 @@
+package config
+
+import (
+	"GameService/consts/plan_types"
+	"GameService/logging"
+	"GameService/repository/models"
+	"GameService/tracing"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	Server    Server    `mapstructure:"server"`
+	Upstream  Upstream  `mapstructure:"upstream"`
+	Zoom      Zoom      `mapstructure:"zoom"`
+	Auth      Auth      `mapstructure:"auth"`
+	Admin     Admin     `mapstructure:"admin"`
+	WebSocket WebSocket `mapstructure:"websocket"`
+	// Entitlements by plan type.
+	Plans        map[string]models.Entitlements `mapstructure:"plans"`
+	Entitlements Entitlements                   `mapstructure:"entitlements"`
+	Features     map[string]bool                `mapstructure:"features"`
+	Bots         Bots                           `mapstructure:"bots"`
+	RateLimit    RateLimit                      `mapstructure:"rate_limit"`
+	Guests       Guests                         `mapstructure:"guests"`
+	Log          Log                            `mapstructure:"log"`
+	Tracing      Tracing                        `mapstructure:"tracing"`
+	Cache        Cache                          `mapstructure:"cache"`
+	Repository   Repository                     `mapstructure:"repository"`
+}
+
+type Server struct {
+	Host string `mapstructure:"host"`
+	Port string `mapstructure:"port"`
+}
+
+// Addr returns listen address of the server.
+func (s Server) Addr() string {
+	return s.Host + ":" + s.Port
+}
+
+type Upstream struct {
+	// ConnectTeam HTTP server base URL.
+	ConnectTeamURL string `mapstructure:"connect_team_url"`
+	// ConnectTeam api key used by Game Service when sending requests.
+	APIKey string `mapstructure:"api_key"`
+	// Key ConnectTeam sends in X-API-Key header of webhooks, e.g. /internal/plan-changed.
+	// It must differ from APIKey, so the outbound key does not authorize inbound calls.
+	WebhookKey string `mapstructure:"webhook_key"`
+	// Timeout of a single request.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// Timeouts overriding Timeout by call name, e.g. SaveResults.
+	CallTimeouts map[string]time.Duration `mapstructure:"call_timeouts"`
+	// Number of retries of GET requests failed because upstream is down.
+	Retries      int           `mapstructure:"retries"`
+	RetryWait    time.Duration `mapstructure:"retry_wait"`
+	RetryMaxWait time.Duration `mapstructure:"retry_max_wait"`
+	// Number of consecutive failures opening circuit breaker of a call.
+	BreakerThreshold int `mapstructure:"breaker_threshold"`
+	// Time after which an open circuit breaker lets a trial request through.
+	BreakerOpenDuration time.Duration `mapstructure:"breaker_open_duration"`
+	MaxIdleConnsPerHost int           `mapstructure:"max_idle_conns_per_host"`
+}
+
+type Zoom struct {
+	APIURL       string `mapstructure:"api_url"`
+	OAuthURL     string `mapstructure:"oauth_url"`
+	SDKKey       string `mapstructure:"sdk_key"`
+	SDKSecret    string `mapstructure:"sdk_secret"`
+	AccessToken  string `mapstructure:"access_token"`
+	RefreshToken string `mapstructure:"refresh_token"`
+}
+
+type Auth struct {
+	// Key verifying HS256 user tokens issued by ConnectTeam. Empty rejects HS256 tokens.
+	SigningKey string `mapstructure:"signing_key"`
+	// JWKS document with keys verifying RS256 and ES256 tokens, either a file or a URL.
+	JWKSFile string `mapstructure:"jwks_file"`
+	JWKSURL  string `mapstructure:"jwks_url"`
+	// Interval of reloading the JWKS document to pick up rotated keys.
+	JWKSRefreshInterval time.Duration `mapstructure:"jwks_refresh_interval"`
+	// Expected iss and aud claims, not checked when empty.
+	Issuer   string `mapstructure:"issuer"`
+	Audience string `mapstructure:"audience"`
+	// Allowed clock skew when checking exp, nbf and iat claims.
+	Leeway time.Duration `mapstructure:"leeway"`
+}
+
+type Admin struct {
+	APIKey string `mapstructure:"api_key"`
+}
+
+type WebSocket struct {
+	// Max wait time when writing message to peer.
+	WriteWait time.Duration `mapstructure:"write_wait"`
+	// Max time till next pong from peer.
+	PongWait time.Duration `mapstructure:"pong_wait"`
+	// Send ping interval, must be less than pong wait time.
+	PingPeriod time.Duration `mapstructure:"ping_period"`
+	// Maximum message size allowed from peer.
+	MaxMessageSize  int64 `mapstructure:"max_message_size"`
+	ReadBufferSize  int   `mapstructure:"read_buffer_size"`
+	WriteBufferSize int   `mapstructure:"write_buffer_size"`
+	// Number of messages queued for a client.
+	SendBufferSize int `mapstructure:"send_buffer_size"`
+	// Deadline of handling a client action.
+	ActionTimeout time.Duration `mapstructure:"action_timeout"`
+	// Deadlines overriding ActionTimeout by action name.
+	ActionTimeouts map[string]time.Duration `mapstructure:"action_timeouts"`
+	// Origins allowed to connect, e.g. https://app.example.com, or "*" for any
+	// origin. When empty only requests without Origin or from the same host are allowed.
+	AllowedOrigins []string `mapstructure:"allowed_origins"`
+	// Lifetime of a connection ticket issued at /ws/ticket.
+	TicketTTL time.Duration `mapstructure:"ticket_ttl"`
+	// Accept tokens in the token URL query parameter, where they end up in proxy logs.
+	// Off by default, enable it only while clients migrate to other ways of connecting.
+	QueryToken bool `mapstructure:"query_token"`
+}
+
+// Bots configures bot players added to games when the bots feature is enabled.
+type Bots struct {
+	// Delay before a bot starts and ends answering.
+	AnswerDelay time.Duration `mapstructure:"answer_delay"`
+	// Delay before a bot rates an answer.
+	RateDelay time.Duration `mapstructure:"rate_delay"`
+	// Max number of bots in a game.
+	MaxPerGame int `mapstructure:"max_per_game"`
+}
+
+// Guests configures identities and names of guests connecting without a user token.
+type Guests struct {
+	// Key signing guest tokens. A random key is generated at start when empty,
+	// so guest identities do not survive restarts.
+	SigningKey string `mapstructure:"signing_key"`
+	// Lifetime of a guest identity.
+	TokenTTL time.Duration `mapstructure:"token_ttl"`
+	// Length of a guest name in characters.
+	NameMinLength int `mapstructure:"name_min_length"`
+	NameMaxLength int `mapstructure:"name_max_length"`
+	// Words not allowed in guest names, matched as whole words ignoring case.
+	BlockedWords []string `mapstructure:"blocked_words"`
+}
+
+// RateLimit limits messages of a client and connections from an IP address.
+type RateLimit struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Limit of all messages of a client.
+	Messages Limit `mapstructure:"messages"`
+	// Limits overriding Messages by action name, applied in addition to Messages.
+	Actions map[string]Limit `mapstructure:"actions"`
+	// Number of throttled messages answered with a warning before the client is muted.
+	Warnings int `mapstructure:"warnings"`
+	// Time messages of a muted client are dropped.
+	MuteDuration time.Duration `mapstructure:"mute_duration"`
+	// Number of mutes after which the next violation disconnects the client.
+	MutesBeforeDisconnect int `mapstructure:"mutes_before_disconnect"`
+	// Max number of connections from an IP address. Zero disables the limit.
+	ConnectionsPerIP int `mapstructure:"connections_per_ip"`
+	// Addresses or CIDR ranges of reverse proxies. The client address of a
+	// request from a trusted proxy is taken from X-Forwarded-For.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+}
+
+// ParseProxy parses an address or a CIDR range of RateLimit.TrustedProxies.
+func ParseProxy(proxy string) (netip.Prefix, error) {
+	if strings.Contains(proxy, "/") {
+		return netip.ParsePrefix(proxy)
+	}
+	addr, err := netip.ParseAddr(proxy)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Limit is a token bucket refilled with Rate tokens per second up to Burst tokens.
+type Limit struct {
+	Rate  float64 `mapstructure:"rate"`
+	Burst int     `mapstructure:"burst"`
+}
+
+func (l Limit) validate(key string) error {
+	var errs []error
+	if l.Rate <= 0 {
+		errs = append(errs, fmt.Errorf("%s.rate: must be positive", key))
+	}
+	return errors.Join(append(errs, positive(key+".burst", int64(l.Burst)))...)
+}
+
+// Cache configures caching of ConnectTeam responses.
+type Cache struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Topics by ID.
+	Topics CacheEntries `mapstructure:"topics"`
+	// Users by ID.
+	Users CacheEntries `mapstructure:"users"`
+	// Active plans of users by user ID.
+	Plans CacheEntries `mapstructure:"plans"`
+	// Entitlements by plan type.
+	Entitlements CacheEntries `mapstructure:"entitlements"`
+}
+
+type CacheEntries struct {
+	TTL  time.Duration `mapstructure:"ttl"`
+	Size int           `mapstructure:"size"`
+}
+
+const (
+	EntitlementsSourceConfig      = "config"
+	EntitlementsSourceConnectTeam = "connect_team"
+)
+
+type Entitlements struct {
+	// Source of plan entitlements. Entitlements from config are used
+	// when ConnectTeam is the source and does not respond.
+	Source string `mapstructure:"source"`
+	// Interval of recomputing entitlements of games in lobby.
+	RevalidateInterval time.Duration `mapstructure:"revalidate_interval"`
+}
+
+type Log struct {
+	Level  string `mapstructure:"level"`
+	Format string `mapstructure:"format"`
+}
+
+type Tracing struct {
+	Exporter string `mapstructure:"exporter"`
+	Endpoint string `mapstructure:"endpoint"`
+}
+
+// Repository selects where games, users, plans and topics come from.
+type Repository struct {
+	// Either RepositoryModeHTTP or RepositoryModeLocal.
+	Mode string `mapstructure:"mode"`
+	// Directory with games, users, plans and topics files used in local mode.
+	DataDir string `mapstructure:"data_dir"`
+	// Directory where results of games are written in local mode.
+	ResultsDir string `mapstructure:"results_dir"`
+}
+
+const (
+	// RepositoryModeHTTP uses ConnectTeam and Zoom.
+	RepositoryModeHTTP = "http"
+	// RepositoryModeLocal uses local files and works offline.
+	RepositoryModeLocal = "local"
+)
+
+// legacyEnv are environment variables supported before typed config was introduced.
+var legacyEnv = map[string]string{
+	"upstream.api_key":   "HTTP_SERVICE_API_KEY",
+	"zoom.sdk_key":       "ZOOM_SDK_KEY",
+	"zoom.sdk_secret":    "ZOOM_SDK_SECRET",
+	"zoom.access_token":  "ZOOM_API_ACCESS_TOKEN",
+	"zoom.refresh_token": "ZOOM_API_REFRESH_TOKEN",
+	"auth.signing_key":   "JWT_SIGNING_KEY",
+	"auth.jwks_url":      "JWT_JWKS_URL",
+	"admin.api_key":      "ADMIN_API_KEY",
+}
+
+func setDefaults() {
+	viper.SetDefault("server.host", "localhost")
+	viper.SetDefault("server.port", "8080")
+	viper.SetDefault("upstream.connect_team_url", "http://localhost:8001")
+	viper.SetDefault("upstream.api_key", "")
+	viper.SetDefault("upstream.webhook_key", "")
+	viper.SetDefault("upstream.timeout", 10*time.Second)
+	viper.SetDefault("upstream.retries", 2)
+	viper.SetDefault("upstream.retry_wait", 100*time.Millisecond)
+	viper.SetDefault("upstream.retry_max_wait", 2*time.Second)
+	viper.SetDefault("upstream.breaker_threshold", 5)
+	viper.SetDefault("upstream.breaker_open_duration", 30*time.Second)
+	viper.SetDefault("upstream.max_idle_conns_per_host", 32)
+	viper.SetDefault("zoom.api_url", "https://api.zoom.us/v2")
+	viper.SetDefault("zoom.oauth_url", "https://zoom.us")
+	viper.SetDefault("zoom.sdk_key", "")
+	viper.SetDefault("zoom.sdk_secret", "")
+	viper.SetDefault("zoom.access_token", "")
+	viper.SetDefault("zoom.refresh_token", "")
+	viper.SetDefault("auth.signing_key", "")
+	viper.SetDefault("auth.jwks_file", "")
+	viper.SetDefault("auth.jwks_url", "")
+	viper.SetDefault("auth.jwks_refresh_interval", 5*time.Minute)
+	viper.SetDefault("auth.issuer", "")
+	viper.SetDefault("auth.audience", "")
+	viper.SetDefault("auth.leeway", 30*time.Second)
+	viper.SetDefault("admin.api_key", "")
+	viper.SetDefault("websocket.write_wait", 10*time.Second)
+	viper.SetDefault("websocket.pong_wait", 60*time.Second)
+	viper.SetDefault("websocket.ping_period", 54*time.Second)
+	viper.SetDefault("websocket.max_message_size", 10000)
+	viper.SetDefault("websocket.read_buffer_size", 4096)
+	viper.SetDefault("websocket.write_buffer_size", 4096)
+	viper.SetDefault("websocket.send_buffer_size", 256)
+	viper.SetDefault("websocket.action_timeout", 15*time.Second)
+	viper.SetDefault("websocket.allowed_origins", []string{})
+	viper.SetDefault("websocket.ticket_ttl", 30*time.Second)
+	viper.SetDefault("websocket.query_token", false)
+	setPlanDefaults(plan_types.Basic, models.Entitlements{MaxPlayers: 3, TopicCount: 3, Meeting: true})
+	setPlanDefaults(plan_types.Advanced, models.Entitlements{MaxPlayers: 5, CustomTopics: true, Spectators: 2,
+		Timers: true, Exports: true, Meeting: true})
+	setPlanDefaults(plan_types.Premium, models.Entitlements{MaxPlayers: 10, CustomTopics: true, Spectators: 10,
+		Timers: true, Exports: true, Meeting: true})
+	viper.SetDefault("bots.answer_delay", 3*time.Second)
+	viper.SetDefault("bots.rate_delay", 2*time.Second)
+	viper.SetDefault("bots.max_per_game", 4)
+	viper.SetDefault("rate_limit.enabled", true)
+	viper.SetDefault("rate_limit.messages.rate", 10)
+	viper.SetDefault("rate_limit.messages.burst", 20)
+	viper.SetDefault("rate_limit.warnings", 3)
+	viper.SetDefault("rate_limit.mute_duration", 30*time.Second)
+	viper.SetDefault("rate_limit.mutes_before_disconnect", 2)
+	viper.SetDefault("rate_limit.connections_per_ip", 0)
+	viper.SetDefault("rate_limit.trusted_proxies", []string{})
+	viper.SetDefault("guests.signing_key", "")
+	viper.SetDefault("guests.token_ttl", 24*time.Hour)
+	viper.SetDefault("guests.name_min_length", 2)
+	viper.SetDefault("guests.name_max_length", 32)
+	viper.SetDefault("guests.blocked_words", []string{})
+	viper.SetDefault("entitlements.source", EntitlementsSourceConfig)
+	viper.SetDefault("entitlements.revalidate_interval", 5*time.Minute)
+	viper.SetDefault("log.level", logrus.InfoLevel.String())
+	viper.SetDefault("log.format", logging.FormatJSON)
+	viper.SetDefault("tracing.exporter", tracing.ExporterNone)
+	viper.SetDefault("tracing.endpoint", "")
+	viper.SetDefault("repository.mode", RepositoryModeHTTP)
+	viper.SetDefault("repository.data_dir", "data")
+	viper.SetDefault("repository.results_dir", "data/results")
+	viper.SetDefault("cache.enabled", true)
+	setCacheDefaults("topics", 10*time.Minute, 1000)
+	setCacheDefaults("users", 5*time.Minute, 10000)
+	setCacheDefaults("plans", time.Minute, 10000)
+	setCacheDefaults("entitlements", 5*time.Minute, 16)
+}
+
+func (e CacheEntries) validate(key string) error {
+	return errors.Join(positive(key+".ttl", int64(e.TTL)), positive(key+".size", int64(e.Size)))
+}
+
+func setCacheDefaults(name string, ttl time.Duration, size int) {
+	viper.SetDefault("cache."+name+".ttl", ttl)
+	viper.SetDefault("cache."+name+".size", size)
+}
+
+func setPlanDefaults(planType string, entitlements models.Entitlements) {
+	prefix := "plans." + planType + "."
+	viper.SetDefault(prefix+"max_players", entitlements.MaxPlayers)
+	viper.SetDefault(prefix+"topic_count", entitlements.TopicCount)
+	viper.SetDefault(prefix+"custom_topics", entitlements.CustomTopics)
+	viper.SetDefault(prefix+"spectators", entitlements.Spectators)
+	viper.SetDefault(prefix+"timers", entitlements.Timers)
+	viper.SetDefault(prefix+"exports", entitlements.Exports)
+	viper.SetDefault(prefix+"meeting", entitlements.Meeting)
+}
+
+// Load reads config/config.yml, applies environment variable overrides and validates the result.
+// Use Watch to reload settings when the file changes.
+// Every key can be overridden with an environment variable named after the key in upper case
+// with dots replaced by underscores, e.g. SERVER_PORT or WEBSOCKET_PONG_WAIT.
+func Load() (*Config, error) {
+	viper.AddConfigPath("config")
+	viper.SetConfigName("config")
+	setDefaults()
+
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
+	for key, env := range legacyEnv {
+		if err := viper.BindEnv(key, strings.ToUpper(strings.ReplaceAll(key, ".", "_")), env); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := viper.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("cannot read config: %w", err)
+		}
+	}
+
+	return current()
+}
+
+// current decodes and validates settings currently loaded into viper.
+func current() (*Config, error) {
+	var cfg Config
+	if err := viper.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("cannot decode config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// Validate reports every misconfigured setting.
+func (c *Config) Validate() error {
+	var errs []error
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port <= 0 || port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port: %q is not a valid port", c.Server.Port))
+	}
+	errs = append(errs,
+		validateURL("upstream.connect_team_url", c.Upstream.ConnectTeamURL),
+		validateURL("zoom.api_url", c.Zoom.APIURL),
+		validateURL("zoom.oauth_url", c.Zoom.OAuthURL),
+		positive("auth.jwks_refresh_interval", int64(c.Auth.JWKSRefreshInterval)),
+		positive("upstream.timeout", int64(c.Upstream.Timeout)),
+		positive("upstream.retry_wait", int64(c.Upstream.RetryWait)),
+		positive("upstream.retry_max_wait", int64(c.Upstream.RetryMaxWait)),
+		positive("upstream.breaker_threshold", int64(c.Upstream.BreakerThreshold)),
+		positive("upstream.breaker_open_duration", int64(c.Upstream.BreakerOpenDuration)),
+		positive("upstream.max_idle_conns_per_host", int64(c.Upstream.MaxIdleConnsPerHost)),
+		positive("websocket.write_wait", int64(c.WebSocket.WriteWait)),
+		positive("websocket.pong_wait", int64(c.WebSocket.PongWait)),
+		positive("websocket.ping_period", int64(c.WebSocket.PingPeriod)),
+		positive("websocket.max_message_size", c.WebSocket.MaxMessageSize),
+		positive("websocket.read_buffer_size", int64(c.WebSocket.ReadBufferSize)),
+		positive("websocket.write_buffer_size", int64(c.WebSocket.WriteBufferSize)),
+		positive("websocket.send_buffer_size", int64(c.WebSocket.SendBufferSize)),
+		positive("websocket.action_timeout", int64(c.WebSocket.ActionTimeout)),
+		positive("websocket.ticket_ttl", int64(c.WebSocket.TicketTTL)),
+		positive("bots.answer_delay", int64(c.Bots.AnswerDelay)),
+		positive("bots.rate_delay", int64(c.Bots.RateDelay)),
+		positive("bots.max_per_game", int64(c.Bots.MaxPerGame)),
+	)
+	switch c.Repository.Mode {
+	case RepositoryModeHTTP:
+		errs = append(errs,
+			required("upstream.api_key", c.Upstream.APIKey),
+			required("upstream.webhook_key", c.Upstream.WebhookKey))
+		if c.Upstream.WebhookKey != "" && c.Upstream.WebhookKey == c.Upstream.APIKey {
+			errs = append(errs, errors.New("upstream.webhook_key: must differ from upstream.api_key"))
+		}
+	case RepositoryModeLocal:
+		errs = append(errs,
+			required("repository.data_dir", c.Repository.DataDir),
+			required("repository.results_dir", c.Repository.ResultsDir))
+	default:
+		errs = append(errs, fmt.Errorf("repository.mode: %q is not one of %q, %q", c.Repository.Mode,
+			RepositoryModeHTTP, RepositoryModeLocal))
+	}
+	switch {
+	case c.Auth.SigningKey == "" && c.Auth.JWKSFile == "" && c.Auth.JWKSURL == "":
+		errs = append(errs, errors.New("auth: signing_key, jwks_file or jwks_url is required"))
+	case c.Auth.JWKSFile != "" && c.Auth.JWKSURL != "":
+		errs = append(errs, errors.New("auth: jwks_file and jwks_url are mutually exclusive"))
+	case c.Auth.JWKSURL != "":
+		errs = append(errs, validateURL("auth.jwks_url", c.Auth.JWKSURL))
+	}
+	if c.Auth.Leeway < 0 {
+		errs = append(errs, errors.New("auth.leeway: must not be negative"))
+	}
+	if c.Upstream.Retries < 0 {
+		errs = append(errs, errors.New("upstream.retries: must not be negative"))
+	}
+	for call, timeout := range c.Upstream.CallTimeouts {
+		errs = append(errs, positive("upstream.call_timeouts."+call, int64(timeout)))
+	}
+	for action, timeout := range c.WebSocket.ActionTimeouts {
+		errs = append(errs, positive("websocket.action_timeouts."+action, int64(timeout)))
+	}
+	for i, origin := range c.WebSocket.AllowedOrigins {
+		if origin != "*" {
+			errs = append(errs, validateURL(fmt.Sprintf("websocket.allowed_origins[%d]", i), origin))
+		}
+	}
+	if c.WebSocket.PingPeriod >= c.WebSocket.PongWait {
+		errs = append(errs, errors.New("websocket.ping_period: must be less than websocket.pong_wait"))
+	}
+	for _, planType := range []string{plan_types.Basic, plan_types.Advanced, plan_types.Premium} {
+		plan, ok := c.Plans[planType]
+		if !ok {
+			errs = append(errs, fmt.Errorf("plans.%s: is required", planType))
+			continue
+		}
+		if plan.MaxPlayers < 2 {
+			errs = append(errs, fmt.Errorf("plans.%s.max_players: must be at least 2", planType))
+		}
+		if !plan.CustomTopics && plan.TopicCount <= 0 {
+			errs = append(errs, fmt.Errorf("plans.%s.topic_count: must be positive without custom topics", planType))
+		}
+		if plan.TopicCount < 0 || plan.Spectators < 0 {
+			errs = append(errs, fmt.Errorf("plans.%s: limits must not be negative", planType))
+		}
+	}
+	if c.RateLimit.Enabled {
+		errs = append(errs,
+			c.RateLimit.Messages.validate("rate_limit.messages"),
+			positive("rate_limit.mute_duration", int64(c.RateLimit.MuteDuration)))
+		for action, limit := range c.RateLimit.Actions {
+			errs = append(errs, limit.validate("rate_limit.actions."+action))
+		}
+		if c.RateLimit.Warnings < 0 || c.RateLimit.MutesBeforeDisconnect < 0 || c.RateLimit.ConnectionsPerIP < 0 {
+			errs = append(errs, errors.New(
+				"rate_limit: warnings, mutes_before_disconnect and connections_per_ip must not be negative"))
+		}
+		for i, proxy := range c.RateLimit.TrustedProxies {
+			if _, err := ParseProxy(proxy); err != nil {
+				errs = append(errs, fmt.Errorf("rate_limit.trusted_proxies[%d]: %q is not an address or CIDR range", i, proxy))
+			}
+		}
+	}
+	errs = append(errs,
+		positive("guests.token_ttl", int64(c.Guests.TokenTTL)),
+		positive("guests.name_min_length", int64(c.Guests.NameMinLength)))
+	if c.Guests.NameMaxLength < c.Guests.NameMinLength {
+		errs = append(errs, errors.New("guests.name_max_length: must not be less than guests.name_min_length"))
+	}
+	if c.Cache.Enabled {
+		errs = append(errs,
+			c.Cache.Topics.validate("cache.topics"),
+			c.Cache.Users.validate("cache.users"),
+			c.Cache.Plans.validate("cache.plans"),
+			c.Cache.Entitlements.validate("cache.entitlements"))
+	}
+	errs = append(errs, positive("entitlements.revalidate_interval", int64(c.Entitlements.RevalidateInterval)))
+	switch c.Entitlements.Source {
+	case EntitlementsSourceConfig, EntitlementsSourceConnectTeam:
+	default:
+		errs = append(errs, fmt.Errorf("entitlements.source: %q is not one of %q, %q", c.Entitlements.Source,
+			EntitlementsSourceConfig, EntitlementsSourceConnectTeam))
+	}
+	if _, err := logrus.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, fmt.Errorf("log.level: %w", err))
+	}
+	if c.Log.Format != logging.FormatJSON && c.Log.Format != logging.FormatText {
+		errs = append(errs, fmt.Errorf("log.format: %q is not one of %q, %q", c.Log.Format, logging.FormatJSON, logging.FormatText))
+	}
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter: %q is not supported", c.Tracing.Exporter))
+	}
+	return errors.Join(errs...)
+}
+
+func validateURL(key string, value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return fmt.Errorf("%s: %q is not an absolute URL", key, value)
+	}
+	return nil
+}
+
+func required(key string, value string) error {
+	if value == "" {
+		return fmt.Errorf("%s: is required", key)
+	}
+	return nil
+}
+
+func positive(key string, value int64) error {
+	if value <= 0 {
+		return fmt.Errorf("%s: must be positive", key)
+	}
+	return nil
+}
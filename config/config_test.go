@@ -0,0 +1,113 @@
+package config
+
+import (
+	"GameService/consts/plan_types"
+	"github.com/spf13/viper"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig returns the default config with the settings required in http mode.
+func validConfig(t *testing.T) *Config {
+	t.Helper()
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	setDefaults()
+	viper.Set("upstream.api_key", "api-key")
+	viper.Set("upstream.webhook_key", "webhook-key")
+	viper.Set("auth.signing_key", "signing-key")
+	cfg, err := current()
+	if err != nil {
+		t.Fatalf("default config is invalid: %v", err)
+	}
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		errors []string
+	}{
+		{name: "defaults", modify: func(cfg *Config) {}},
+		{name: "port", modify: func(cfg *Config) { cfg.Server.Port = "http" },
+			errors: []string{`server.port: "http" is not a valid port`}},
+		{name: "relative url", modify: func(cfg *Config) { cfg.Upstream.ConnectTeamURL = "/api" },
+			errors: []string{`upstream.connect_team_url: "/api" is not an absolute URL`}},
+		{name: "durations", modify: func(cfg *Config) {
+			cfg.Upstream.Timeout = 0
+			cfg.WebSocket.TicketTTL = -time.Second
+		}, errors: []string{"upstream.timeout: must be positive", "websocket.ticket_ttl: must be positive"}},
+		{name: "ping period", modify: func(cfg *Config) { cfg.WebSocket.PingPeriod = cfg.WebSocket.PongWait },
+			errors: []string{"websocket.ping_period: must be less than websocket.pong_wait"}},
+		{name: "upstream keys", modify: func(cfg *Config) { cfg.Upstream.APIKey = "" },
+			errors: []string{"upstream.api_key: is required"}},
+		{name: "same upstream keys", modify: func(cfg *Config) { cfg.Upstream.WebhookKey = cfg.Upstream.APIKey },
+			errors: []string{"upstream.webhook_key: must differ from upstream.api_key"}},
+		{name: "upstream keys in local mode", modify: func(cfg *Config) {
+			cfg.Repository.Mode = RepositoryModeLocal
+			cfg.Upstream.APIKey, cfg.Upstream.WebhookKey = "", ""
+		}},
+		{name: "repository mode", modify: func(cfg *Config) { cfg.Repository.Mode = "sql" },
+			errors: []string{`repository.mode: "sql" is not one of`}},
+		{name: "no signing key", modify: func(cfg *Config) { cfg.Auth.SigningKey = "" },
+			errors: []string{"auth: signing_key, jwks_file or jwks_url is required"}},
+		{name: "jwks file and url", modify: func(cfg *Config) {
+			cfg.Auth.JWKSFile, cfg.Auth.JWKSURL = "jwks.json", "https://auth.example.com/jwks"
+		}, errors: []string{"auth: jwks_file and jwks_url are mutually exclusive"}},
+		{name: "allowed origin", modify: func(cfg *Config) { cfg.WebSocket.AllowedOrigins = []string{"*", "example.com"} },
+			errors: []string{`websocket.allowed_origins[1]: "example.com" is not an absolute URL`}},
+		{name: "action timeout", modify: func(cfg *Config) {
+			cfg.WebSocket.ActionTimeouts = map[string]time.Duration{"start-game": 0}
+		}, errors: []string{"websocket.action_timeouts.start-game: must be positive"}},
+		{name: "missing plan", modify: func(cfg *Config) { delete(cfg.Plans, plan_types.Premium) },
+			errors: []string{"plans.premium: is required"}},
+		{name: "plan limits", modify: func(cfg *Config) {
+			plan := cfg.Plans[plan_types.Basic]
+			plan.MaxPlayers, plan.TopicCount = 1, 0
+			cfg.Plans[plan_types.Basic] = plan
+		}, errors: []string{"plans.basic.max_players: must be at least 2",
+			"plans.basic.topic_count: must be positive without custom topics"}},
+		{name: "trusted proxy", modify: func(cfg *Config) { cfg.RateLimit.TrustedProxies = []string{"10.0.0.0/8", "proxy"} },
+			errors: []string{`rate_limit.trusted_proxies[1]: "proxy" is not an address or CIDR range`}},
+		{name: "rate limit disabled", modify: func(cfg *Config) {
+			cfg.RateLimit.Enabled = false
+			cfg.RateLimit.TrustedProxies = []string{"proxy"}
+		}},
+		{name: "guest names", modify: func(cfg *Config) { cfg.Guests.NameMaxLength = cfg.Guests.NameMinLength - 1 },
+			errors: []string{"guests.name_max_length: must not be less than guests.name_min_length"}},
+		{name: "cache size", modify: func(cfg *Config) { cfg.Cache.Users.Size = 0 },
+			errors: []string{"cache.users.size: must be positive"}},
+		{name: "log and tracing", modify: func(cfg *Config) {
+			cfg.Log.Level, cfg.Log.Format, cfg.Tracing.Exporter = "loud", "xml", "zipkin"
+		}, errors: []string{"log.level:", `log.format: "xml"`, `tracing.exporter: "zipkin" is not supported`}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig(t)
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if len(tt.errors) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected errors %q", tt.errors)
+			}
+			// Every misconfigured setting is reported on its own line.
+			lines := strings.Split(err.Error(), "\n")
+			if len(lines) != len(tt.errors) {
+				t.Fatalf("expected %d errors, got %q", len(tt.errors), lines)
+			}
+			for _, expected := range tt.errors {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("error %q does not report %q", err, expected)
+				}
+			}
+		})
+	}
+}
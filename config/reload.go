@@ -0,0 +1,188 @@
+package config
+
+import (
+	"GameService/logging"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// reloadableKeys are settings which are applied without restart.
+// Keys of nested settings are matched by prefix.
+var reloadableKeys = []string{
+	"plans",
+	"entitlements",
+	"features",
+	"bots",
+	"rate_limit",
+	"guests.token_ttl",
+	"guests.name_min_length",
+	"guests.name_max_length",
+	"guests.blocked_words",
+	"log.level",
+	"websocket.write_wait",
+	"websocket.pong_wait",
+	"websocket.ping_period",
+	"websocket.max_message_size",
+	"websocket.send_buffer_size",
+	"websocket.action_timeout",
+	"websocket.action_timeouts",
+	"websocket.allowed_origins",
+	"websocket.ticket_ttl",
+	"websocket.query_token",
+}
+
+// Change describes changed setting.
+type Change struct {
+	Key        string
+	Old        string
+	New        string
+	Reloadable bool
+}
+
+func (c Change) String() string {
+	if logging.IsSensitive(c.Key) {
+		return c.Key + " changed"
+	}
+	return fmt.Sprintf("%s: %s -> %s", c.Key, c.Old, c.New)
+}
+
+// Watch watches config file and calls apply with the current config after
+// reloadable settings change. Other settings keep values loaded at startup
+// until restart. Invalid config is reported and ignored.
+func Watch(cfg *Config, apply func(cfg *Config)) {
+	var mutex sync.Mutex
+	active := *cfg
+
+	viper.OnConfigChange(func(event fsnotify.Event) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		logger := logrus.WithField("file", event.Name)
+		loaded, err := current()
+		if err != nil {
+			logger.WithError(err).Error("config is not reloaded")
+			return
+		}
+
+		changes := Diff(&active, loaded)
+		reloaded := false
+		for _, change := range changes {
+			if change.Reloadable {
+				reloaded = true
+				logger.Infof("config reloaded: %s", change)
+			} else {
+				logger.Warnf("config change requires restart: %s", change)
+			}
+		}
+		if !reloaded {
+			return
+		}
+
+		active = merge(active, *loaded)
+		if level, err := logrus.ParseLevel(active.Log.Level); err == nil {
+			logrus.SetLevel(level)
+		}
+		apply(&active)
+	})
+	viper.WatchConfig()
+}
+
+// merge returns active config with reloadable settings taken from loaded.
+func merge(active Config, loaded Config) Config {
+	active.Plans = loaded.Plans
+	active.Entitlements = loaded.Entitlements
+	active.Features = loaded.Features
+	active.Bots = loaded.Bots
+	active.RateLimit = loaded.RateLimit
+	active.Guests.TokenTTL = loaded.Guests.TokenTTL
+	active.Guests.NameMinLength = loaded.Guests.NameMinLength
+	active.Guests.NameMaxLength = loaded.Guests.NameMaxLength
+	active.Guests.BlockedWords = loaded.Guests.BlockedWords
+	active.Log.Level = loaded.Log.Level
+	active.WebSocket.WriteWait = loaded.WebSocket.WriteWait
+	active.WebSocket.PongWait = loaded.WebSocket.PongWait
+	active.WebSocket.PingPeriod = loaded.WebSocket.PingPeriod
+	active.WebSocket.MaxMessageSize = loaded.WebSocket.MaxMessageSize
+	active.WebSocket.SendBufferSize = loaded.WebSocket.SendBufferSize
+	active.WebSocket.ActionTimeout = loaded.WebSocket.ActionTimeout
+	active.WebSocket.ActionTimeouts = loaded.WebSocket.ActionTimeouts
+	active.WebSocket.AllowedOrigins = loaded.WebSocket.AllowedOrigins
+	active.WebSocket.TicketTTL = loaded.WebSocket.TicketTTL
+	active.WebSocket.QueryToken = loaded.WebSocket.QueryToken
+	return active
+}
+
+// Diff returns changed settings sorted by key.
+func Diff(before *Config, after *Config) []Change {
+	oldValues := make(map[string]string)
+	newValues := make(map[string]string)
+	flatten("", reflect.ValueOf(*before), oldValues)
+	flatten("", reflect.ValueOf(*after), newValues)
+
+	keys := make(map[string]bool)
+	for key := range oldValues {
+		keys[key] = true
+	}
+	for key := range newValues {
+		keys[key] = true
+	}
+
+	changes := make([]Change, 0)
+	for key := range keys {
+		if oldValues[key] != newValues[key] {
+			changes = append(changes, Change{
+				Key:        key,
+				Old:        oldValues[key],
+				New:        newValues[key],
+				Reloadable: isReloadable(key),
+			})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+func isReloadable(key string) bool {
+	for _, reloadable := range reloadableKeys {
+		if key == reloadable || strings.HasPrefix(key, reloadable+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// flatten writes values of config fields by their dotted keys.
+func flatten(prefix string, value reflect.Value, out map[string]string) {
+	switch value.Kind() {
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			name := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			flatten(join(prefix, name), value.Field(i), out)
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			flatten(join(prefix, fmt.Sprint(key.Interface())), value.MapIndex(key), out)
+		}
+	default:
+		out[prefix] = fmt.Sprint(value.Interface())
+	}
+}
+
+func join(prefix string, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
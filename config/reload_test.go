@@ -0,0 +1,96 @@
+package config
+
+import (
+	"GameService/consts/plan_types"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	before := validConfig(t)
+	after := *before
+	after.Plans = copyPlans(before.Plans)
+	plan := after.Plans[plan_types.Basic]
+	plan.MaxPlayers = 4
+	after.Plans[plan_types.Basic] = plan
+	after.Server.Port = "9090"
+	after.WebSocket.PongWait = time.Minute + time.Second
+
+	expected := []Change{
+		{Key: "plans.basic.max_players", Old: "3", New: "4", Reloadable: true},
+		{Key: "server.port", Old: "8080", New: "9090", Reloadable: false},
+		{Key: "websocket.pong_wait", Old: "1m0s", New: "1m1s", Reloadable: true},
+	}
+	if changes := Diff(before, &after); !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("expected changes %+v, got %+v", expected, changes)
+	}
+	if changes := Diff(before, before); len(changes) != 0 {
+		t.Fatalf("expected no changes, got %+v", changes)
+	}
+}
+
+func TestDiffRedactsSensitiveKeys(t *testing.T) {
+	before := validConfig(t)
+	after := *before
+	after.Upstream.APIKey = "new-api-key"
+	after.Upstream.WebhookKey = "new-webhook-key"
+	after.Zoom.SDKSecret = "new-sdk-secret"
+	after.Zoom.AccessToken = "new-access-token"
+	after.Auth.SigningKey = "new-signing-key"
+	after.Guests.SigningKey = "new-guest-key"
+	after.Admin.APIKey = "new-admin-key"
+
+	secrets := []string{"api-key", "webhook-key", "signing-key", "new-sdk-secret", "new-access-token", "new-guest-key", "new-admin-key"}
+	changes := Diff(before, &after)
+	if len(changes) != 7 {
+		t.Fatalf("expected 7 changes, got %+v", changes)
+	}
+	for _, change := range changes {
+		logged := change.String()
+		if logged != change.Key+" changed" {
+			t.Errorf("%s is logged as %q", change.Key, logged)
+		}
+		for _, secret := range secrets {
+			if strings.Contains(logged, secret) {
+				t.Errorf("%s is logged with value %q", change.Key, secret)
+			}
+		}
+	}
+
+	change := Change{Key: "log.level", Old: "info", New: "debug", Reloadable: true}
+	if logged := change.String(); logged != "log.level: info -> debug" {
+		t.Errorf("log.level is logged as %q", logged)
+	}
+}
+
+func TestMergeTakesReloadableSettings(t *testing.T) {
+	active := validConfig(t)
+	loaded := *active
+	loaded.Server.Port = "9090"
+	loaded.Upstream.APIKey = "new-api-key"
+	loaded.Log.Level = "debug"
+	loaded.Guests.NameMaxLength = 10
+
+	merged := merge(*active, loaded)
+	if merged.Server.Port != active.Server.Port || merged.Upstream.APIKey != active.Upstream.APIKey {
+		t.Errorf("settings requiring restart are merged: %+v", merged)
+	}
+	if merged.Log.Level != "debug" || merged.Guests.NameMaxLength != 10 {
+		t.Errorf("reloadable settings are not merged: %+v", merged)
+	}
+	for _, change := range Diff(active, &merged) {
+		if !change.Reloadable {
+			t.Errorf("merged setting %s requires restart", change.Key)
+		}
+	}
+}
+
+func copyPlans[K comparable, V any](plans map[K]V) map[K]V {
+	copied := make(map[K]V, len(plans))
+	for key, value := range plans {
+		copied[key] = value
+	}
+	return copied
+}
@@ -0,0 +1,26 @@
+package contract
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"testing"
+)
+
+// TestContract runs Checks against the recorded interactions, one subtest per check.
+func TestContract(t *testing.T) {
+	if !testing.Verbose() {
+		logrus.SetLevel(logrus.FatalLevel)
+	}
+	stub, err := NewStub()
+	if err != nil {
+		t.Fatalf("cannot load fixtures: %s", err)
+	}
+	for _, result := range Run(context.Background(), stub) {
+		result := result
+		t.Run(result.Name, func(t *testing.T) {
+			if result.Err != nil {
+				t.Error(result.Err)
+			}
+		})
+	}
+}
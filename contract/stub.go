@@ -0,0 +1,203 @@
+// Package contract checks that repositories understand ConnectTeam and Zoom responses.
+// Responses recorded from the upstreams are kept in fixtures and served by Stub.
+package contract
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+// APIKey is the ConnectTeam API key accepted by Stub.
+const APIKey = "contract-api-key"
+
+// Interaction is a recorded request and the response to it.
+type Interaction struct {
+	Description string `json:"description"`
+	Request     struct {
+		Method string `json:"method"`
+		Path   string `json:"path"`
+		// Query parameters and headers which must be present in the request.
+		Query   map[string]string `json:"query"`
+		Headers map[string]string `json:"headers"`
+		// Example of the request body. The request body must have the same shape.
+		Body json.RawMessage `json:"body"`
+	} `json:"request"`
+	Response struct {
+		Status int             `json:"status"`
+		Body   json.RawMessage `json:"body"`
+		// Raw body sent instead of Body, e.g. a truncated response.
+		Raw string `json:"raw"`
+	} `json:"response"`
+	// File the interaction is recorded in.
+	File string `json:"-"`
+}
+
+// Stub replays recorded interactions. Requests to ConnectTeam must contain APIKey.
+type Stub struct {
+	interactions []Interaction
+	mutex        sync.Mutex
+	unmatched    []string
+}
+
+// NewStub loads interactions from fixtures.
+func NewStub() (*Stub, error) {
+	files, err := fixtures.ReadDir("fixtures")
+	if err != nil {
+		return nil, err
+	}
+	stub := &Stub{}
+	for _, file := range files {
+		data, err := fixtures.ReadFile(path.Join("fixtures", file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var interactions []Interaction
+		if err := json.Unmarshal(data, &interactions); err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Name(), err)
+		}
+		for i := range interactions {
+			interactions[i].File = file.Name()
+		}
+		stub.interactions = append(stub.interactions, interactions...)
+	}
+	return stub, nil
+}
+
+// Interactions returns all recorded interactions.
+func (s *Stub) Interactions() []Interaction {
+	return s.interactions
+}
+
+// Unmatched returns requests which did not match any interaction or
+// had a body of a different shape.
+func (s *Stub) Unmatched() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.unmatched...)
+}
+
+func (s *Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/api/") && r.Header.Get("X-API-Key") != APIKey {
+		s.reject(w, http.StatusUnauthorized, r.Method+" "+r.URL.Path+": missing api key")
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.reject(w, http.StatusBadRequest, r.Method+" "+r.URL.Path+": "+err.Error())
+		return
+	}
+
+	interaction := s.match(r)
+	if interaction == nil {
+		s.reject(w, http.StatusNotImplemented, r.Method+" "+r.URL.RequestURI()+": no recorded interaction")
+		return
+	}
+	if len(interaction.Request.Body) > 0 {
+		if err := sameShape(interaction.Request.Body, body); err != nil {
+			s.reject(w, http.StatusBadRequest, fmt.Sprintf("%s %q: request body %s", interaction.File,
+				interaction.Description, err))
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(interaction.Response.Status)
+	if interaction.Response.Raw != "" {
+		_, _ = w.Write([]byte(interaction.Response.Raw))
+		return
+	}
+	_, _ = w.Write(interaction.Response.Body)
+}
+
+func (s *Stub) match(r *http.Request) *Interaction {
+	for i := range s.interactions {
+		interaction := &s.interactions[i]
+		if interaction.Request.Method != r.Method || interaction.Request.Path != r.URL.Path {
+			continue
+		}
+		matches := true
+		for key, value := range interaction.Request.Query {
+			matches = matches && r.URL.Query().Get(key) == value
+		}
+		for key, value := range interaction.Request.Headers {
+			matches = matches && r.Header.Get(key) == value
+		}
+		if matches {
+			return interaction
+		}
+	}
+	return nil
+}
+
+func (s *Stub) reject(w http.ResponseWriter, status int, reason string) {
+	s.mutex.Lock()
+	s.unmatched = append(s.unmatched, reason)
+	s.mutex.Unlock()
+	http.Error(w, reason, status)
+}
+
+// sameShape reports whether actual JSON has every field of expected JSON with the same type.
+// Elements of arrays are compared with the first element of the expected array.
+func sameShape(expected []byte, actual []byte) error {
+	var e, a interface{}
+	if err := json.Unmarshal(expected, &e); err != nil {
+		return fmt.Errorf("example is not JSON: %w", err)
+	}
+	if err := json.Unmarshal(actual, &a); err != nil {
+		return fmt.Errorf("is not JSON: %w", err)
+	}
+	return compareShape("$", e, a)
+}
+
+func compareShape(at string, expected interface{}, actual interface{}) error {
+	switch expected := expected.(type) {
+	case map[string]interface{}:
+		actual, ok := actual.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected object", at)
+		}
+		keys := make([]string, 0, len(expected))
+		for key := range expected {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value, ok := actual[key]
+			if !ok {
+				return fmt.Errorf("%s.%s: missing", at, key)
+			}
+			if err := compareShape(at+"."+key, expected[key], value); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		actual, ok := actual.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected array", at)
+		}
+		if len(expected) == 0 {
+			return nil
+		}
+		for i := range actual {
+			if err := compareShape(fmt.Sprintf("%s[%d]", at, i), expected[0], actual[i]); err != nil {
+				return err
+			}
+		}
+	case nil:
+	default:
+		if fmt.Sprintf("%T", expected) != fmt.Sprintf("%T", actual) {
+			return fmt.Errorf("%s: expected %T, got %T", at, expected, actual)
+		}
+	}
+	return nil
+}
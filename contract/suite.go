@@ -0,0 +1,252 @@
+package contract
+
+import (
+	"GameService/config"
+	"GameService/repository/models"
+	"GameService/repository/requests"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http/httptest"
+	"time"
+)
+
+// IDs used in fixtures.
+var (
+	game        = uuid.MustParse("6f1c2a4e-5b7d-4c1a-9e3f-000000000001")
+	missingGame = uuid.MustParse("6f1c2a4e-5b7d-4c1a-9e3f-000000000002")
+	lockedGame  = uuid.MustParse("6f1c2a4e-5b7d-4c1a-9e3f-000000000003")
+
+	user          = uuid.MustParse("8a3e1f20-7c4b-4d2e-a1b5-000000000101")
+	forbiddenUser = uuid.MustParse("8a3e1f20-7c4b-4d2e-a1b5-000000000102")
+	malformedUser = uuid.MustParse("8a3e1f20-7c4b-4d2e-a1b5-000000000103")
+
+	topic     = uuid.MustParse("8a3e1f20-7c4b-4d2e-a1b5-000000000201")
+	downTopic = uuid.MustParse("8a3e1f20-7c4b-4d2e-a1b5-000000000202")
+	tag       = uuid.MustParse("8a3e1f20-7c4b-4d2e-a1b5-000000000301")
+)
+
+// Check exercises a repository method against Stub.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context, repository *requests.Repository) error
+}
+
+// Result of a check.
+type Result struct {
+	Name string
+	Err  error
+}
+
+// Checks cover every repository method with successful and failed responses.
+var Checks = []Check{
+	{"GetGame", func(ctx context.Context, r *requests.Repository) error {
+		got, err := r.GetGame(ctx, game)
+		return errors.Join(err, expect("game", got, models.Game{Id: game, Status: "not_started",
+			Name: "Recorded game", CreatorId: user, MaxSize: 5}))
+	}},
+	{"GetGame not found", func(ctx context.Context, r *requests.Repository) error {
+		_, err := r.GetGame(ctx, missingGame)
+		return expectError(err, requests.ErrNotFound)
+	}},
+	{"GetGameAccess", func(ctx context.Context, r *requests.Repository) error {
+		got, err := r.GetGameAccess(ctx, game)
+		if err != nil {
+			return err
+		}
+		if len(got.InvitedUsers) != 1 || len(got.InvitedDomains) != 1 {
+			return fmt.Errorf("got %d invited users and %d domains, expected 1 and 1",
+				len(got.InvitedUsers), len(got.InvitedDomains))
+		}
+		return errors.Join(
+			expect("invited_users", got.InvitedUsers[0], user),
+			expect("invited_domains", got.InvitedDomains[0], "example.com"),
+			expect("passcode", got.Passcode, "482913"),
+			expect("waiting_room", got.WaitingRoom, true),
+		)
+	}},
+	{"GetGameAccess not found", func(ctx context.Context, r *requests.Repository) error {
+		_, err := r.GetGameAccess(ctx, missingGame)
+		return expectError(err, requests.ErrNotFound)
+	}},
+	{"StartGame", func(ctx context.Context, r *requests.Repository) error {
+		return r.StartGame(ctx, game)
+	}},
+	{"StartGame rejected", func(ctx context.Context, r *requests.Repository) error {
+		return expectError(r.StartGame(ctx, lockedGame), requests.ErrRejected)
+	}},
+	{"EndGame", func(ctx context.Context, r *requests.Repository) error {
+		return r.EndGame(ctx, game)
+	}},
+	{"EndGame not found", func(ctx context.Context, r *requests.Repository) error {
+		return expectError(r.EndGame(ctx, missingGame), requests.ErrNotFound)
+	}},
+	{"SaveResults", func(ctx context.Context, r *requests.Repository) error {
+		return r.SaveResults(ctx, game, results())
+	}},
+	{"SaveResults rejected", func(ctx context.Context, r *requests.Repository) error {
+		return expectError(r.SaveResults(ctx, lockedGame, results()), requests.ErrRejected)
+	}},
+	{"GetResults", func(ctx context.Context, r *requests.Repository) error {
+		got, err := r.GetResults(ctx, game)
+		if err != nil {
+			return err
+		}
+		if len(got.Results) != 1 {
+			return fmt.Errorf("got %d results, expected 1", len(got.Results))
+		}
+		result := got.Results[0]
+		return errors.Join(
+			expect("value", result.Value, 7),
+			expect("user_id", result.UserId, user),
+			expect("user_temp_id", result.UserTemporaryId, user),
+			expect("name", result.Name, "Recorded User"),
+			expect("tags", len(result.Tags), 1),
+		)
+	}},
+	{"GetResults not found", func(ctx context.Context, r *requests.Repository) error {
+		_, err := r.GetResults(ctx, missingGame)
+		return expectError(err, requests.ErrNotFound)
+	}},
+	{"GetResults rate limited", func(ctx context.Context, r *requests.Repository) error {
+		_, err := r.GetResults(ctx, lockedGame)
+		return expectError(err, requests.ErrUpstreamDown)
+	}},
+	{"GetUserById", func(ctx context.Context, r *requests.Repository) error {
+		got, err := r.GetUserById(ctx, user)
+		return errors.Join(err,
+			expect("id", got.Id, user),
+			expect("first_name", got.FirstName, "Recorded"),
+			expect("second_name", got.SecondName, "User"),
+			expect("access", got.Access, "user"),
+		)
+	}},
+	{"GetUserById forbidden", func(ctx context.Context, r *requests.Repository) error {
+		_, err := r.GetUserById(ctx, forbiddenUser)
+		return expectError(err, requests.ErrUnauthorized)
+	}},
+	{"GetCreatorPlan", func(ctx context.Context, r *requests.Repository) error {
+		got, err := r.GetCreatorPlan(ctx, user)
+		return errors.Join(err, expect("plan_type", got.PlanType, "premium"))
+	}},
+	{"GetCreatorPlan malformed", func(ctx context.Context, r *requests.Repository) error {
+		_, err := r.GetCreatorPlan(ctx, malformedUser)
+		return expectError(err, requests.ErrDecode)
+	}},
+	{"GetEntitlements", func(ctx context.Context, r *requests.Repository) error {
+		got, err := r.GetEntitlements(ctx, "premium")
+		return errors.Join(err, expect("entitlements", got, models.Entitlements{MaxPlayers: 10,
+			CustomTopics: true, Spectators: 10, Timers: true, Exports: true, Meeting: true}))
+	}},
+	{"GetEntitlements not found", func(ctx context.Context, r *requests.Repository) error {
+		_, err := r.GetEntitlements(ctx, "unknown")
+		return expectError(err, requests.ErrNotFound)
+	}},
+	{"GetTopic", func(ctx context.Context, r *requests.Repository) error {
+		got, err := r.GetTopic(ctx, topic)
+		return errors.Join(err, expect("topic", got, models.Topic{Id: topic, Title: "Teamwork"}))
+	}},
+	{"GetTopic upstream down", func(ctx context.Context, r *requests.Repository) error {
+		_, err := r.GetTopic(ctx, downTopic)
+		return expectError(err, requests.ErrUpstreamDown)
+	}},
+	{"GetRandTopicsWithLimit", func(ctx context.Context, r *requests.Repository) error {
+		got, err := r.GetRandTopicsWithLimit(ctx, 2)
+		return errors.Join(err, expect("topics", len(got), 2))
+	}},
+	{"GetRandTopicsWithLimit rejected", func(ctx context.Context, r *requests.Repository) error {
+		_, err := r.GetRandTopicsWithLimit(ctx, 0)
+		return expectError(err, requests.ErrRejected)
+	}},
+	{"GetRandQuestionsWithLimit", func(ctx context.Context, r *requests.Repository) error {
+		got, err := r.GetRandQuestionsWithLimit(ctx, topic, 2)
+		if err != nil {
+			return err
+		}
+		if len(got) != 2 {
+			return fmt.Errorf("got %d questions, expected 2", len(got))
+		}
+		return errors.Join(
+			expect("topic_id", got[0].TopicId, topic),
+			expect("content", got[0].Content != "", true),
+			expect("tags", len(got[1].Tags), 2),
+			expect("tag id", got[0].Tags[0].Id, tag),
+		)
+	}},
+	{"GetRandQuestionsPerTopic", func(ctx context.Context, r *requests.Repository) error {
+		got, err := r.GetRandQuestionsPerTopic(ctx, []uuid.UUID{topic, downTopic}, 2)
+		return errors.Join(
+			expectError(err, requests.ErrNotFound),
+			expect("questions of available topic", len(got[topic]), 2),
+		)
+	}},
+	{"CreateMeeting refreshes expired token", func(ctx context.Context, r *requests.Repository) error {
+		meetingNumber, passcode, err := r.CreateMeeting(ctx)
+		return errors.Join(err,
+			expect("meeting number", meetingNumber, "85746065432"),
+			expect("passcode", passcode, "a1b2c3"),
+		)
+	}},
+}
+
+func results() []models.Rates {
+	return []models.Rates{{Value: 7, Tags: []uuid.UUID{tag}, UserId: user, UserTemporaryId: user, Name: "Recorded User"}}
+}
+
+// Run serves stub and runs checks against repositories sending requests to it.
+// Requests which do not match recorded interactions are reported as a failed check.
+func Run(ctx context.Context, stub *Stub) []Result {
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	repository := requests.NewHTTPService(Config(server.URL))
+	results := make([]Result, 0, len(Checks)+1)
+	for _, check := range Checks {
+		results = append(results, Result{Name: check.Name, Err: check.Run(ctx, repository)})
+	}
+	var err error
+	for _, unmatched := range stub.Unmatched() {
+		err = errors.Join(err, errors.New(unmatched))
+	}
+	return append(results, Result{Name: "all requests match recorded interactions", Err: err})
+}
+
+// Config returns config of repositories sending requests to stub at url.
+// Requests are not retried so that every status reaches the repository.
+func Config(url string) *config.Config {
+	return &config.Config{
+		Upstream: config.Upstream{
+			ConnectTeamURL:      url,
+			APIKey:              APIKey,
+			Timeout:             5 * time.Second,
+			RetryWait:           10 * time.Millisecond,
+			RetryMaxWait:        10 * time.Millisecond,
+			BreakerThreshold:    1000,
+			BreakerOpenDuration: time.Second,
+			MaxIdleConnsPerHost: 4,
+		},
+		Zoom: config.Zoom{
+			APIURL:       url,
+			OAuthURL:     url,
+			SDKKey:       "recorded-sdk-key",
+			SDKSecret:    "recorded-sdk-secret",
+			AccessToken:  "expired-access-token",
+			RefreshToken: "recorded-refresh-token",
+		},
+	}
+}
+
+func expect(field string, got interface{}, expected interface{}) error {
+	if got != expected {
+		return fmt.Errorf("%s is %v, expected %v", field, got, expected)
+	}
+	return nil
+}
+
+func expectError(err error, kind error) error {
+	if !errors.Is(err, kind) {
+		return fmt.Errorf("error is %v, expected %v", err, kind)
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+package e2e
+
+import (
+	"GameService/client"
+	"GameService/consts/game_status"
+	"GameService/consts/plan_types"
+	"GameService/game"
+	"GameService/repository/models"
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// coHost promotes a player who then runs the game in place of the creator.
+// Permissions of roles are checked by tests of package game.
+func coHost(h *Harness) error {
+	l, err := newLobby(h, "Guest")
+	if err != nil {
+		return err
+	}
+	user := h.Repository.AddUser("Cohost", plan_types.Basic)
+	cohost, err := h.ConnectUser(user)
+	if err != nil {
+		return err
+	}
+	if err := join(cohost, l.game.Id); err != nil {
+		return err
+	}
+	if err := expectAll(l.all(), game.JoinGameAction); err != nil {
+		return err
+	}
+	players := append(l.all(), cohost)
+
+	// Guests cannot be promoted.
+	if err := l.host.SetRole(l.game.Id, l.players[0].Id, client.RoleCoHost); err != nil {
+		return err
+	}
+	if _, err := l.host.ExpectError(9); err != nil {
+		return err
+	}
+	if err := l.host.SetRole(l.game.Id, cohost.Id, client.RoleCoHost); err != nil {
+		return err
+	}
+	if err := expectAll(players, game.SetRoleAction); err != nil {
+		return err
+	}
+
+	if err := cohost.SelectTopics(l.game.Id); err != nil {
+		return err
+	}
+	if _, err := cohost.Expect(game.SelectTopicAction); err != nil {
+		return err
+	}
+	if err := cohost.StartGame(l.game.Id); err != nil {
+		return err
+	}
+	if err := expectAll(players, game.StartGameAction); err != nil {
+		return err
+	}
+	if err := cohost.EndGame(l.game.Id); err != nil {
+		return err
+	}
+	if err := expectDenied(cohost, game.PermissionEndGame); err != nil {
+		return err
+	}
+	return expectStatus(h, l.game.Id, game_status.GameInProgress)
+}
+
+// adminModerates joins a game in progress with an admin token and ends it.
+func adminModerates(h *Harness) error {
+	l, err := newLobby(h, "Guest")
+	if err != nil {
+		return err
+	}
+	if err := l.start(); err != nil {
+		return err
+	}
+	admin, err := h.ConnectAdmin(h.Repository.AddUser("Moderator", plan_types.Basic))
+	if err != nil {
+		return err
+	}
+	if err := join(admin, l.game.Id); err != nil {
+		return err
+	}
+	// Moderators do not take a seat, so players are not notified.
+	if err := l.host.ExpectSilence(100 * time.Millisecond); err != nil {
+		return err
+	}
+	if err := admin.Rate(l.game.Id, l.host.Id, 5); err != nil {
+		return err
+	}
+	if err := expectDenied(admin, game.PermissionRate); err != nil {
+		return err
+	}
+	if err := admin.EndGame(l.game.Id); err != nil {
+		return err
+	}
+	if err := expectAll(append(l.all(), admin), game.GameAbortedAction); err != nil {
+		return err
+	}
+	return expectStatus(h, l.game.Id, game_status.GameEnded)
+}
+
+// spectators watch a game up to the limit of the plan and can only chat.
+func spectators(h *Harness) error {
+	user, gameInfo := h.NewGame(plan_types.Advanced)
+	host, _, err := h.HostGame(user, gameInfo)
+	if err != nil {
+		return err
+	}
+	var watching []*Player
+	for _, name := range []string{"Alice", "Bob", "Carol"} {
+		spectator, err := h.ConnectGuest(name)
+		if err != nil {
+			return err
+		}
+		if err := spectator.SpectateGame(gameInfo.Id); err != nil {
+			return err
+		}
+		if len(watching) == 2 {
+			if _, err := spectator.ExpectError(13); err != nil {
+				return err
+			}
+			break
+		}
+		if _, err := spectator.Expect(game.UserJoinedAction); err != nil {
+			return err
+		}
+		watching = append(watching, spectator)
+	}
+
+	if err := watching[0].StartAnswer(gameInfo.Id); err != nil {
+		return err
+	}
+	if err := expectDenied(watching[0], game.PermissionAnswer); err != nil {
+		return err
+	}
+	if err := watching[0].SendMessage(gameInfo.Id, "hello"); err != nil {
+		return err
+	}
+	return expectAll(append([]*Player{host}, watching...), game.SendMessageAction)
+}
+
+// hostWithAccess connects the host of a new game with the access policy who joins the game.
+func hostWithAccess(h *Harness, access models.GameAccess) (*Player, *client.Game, error) {
+	user, gameInfo := h.NewGame(plan_types.Basic)
+	h.Repository.SetAccess(gameInfo.Id, access)
+	return h.HostGame(user, gameInfo)
+}
+
+// expectAccessError expects join-game to be rejected with the reason.
+func expectAccessError(player *Player, reason string) error {
+	rejected, err := player.ExpectError(17)
+	if err != nil {
+		return err
+	}
+	if rejected.Reason != reason {
+		return fmt.Errorf("%s: expected reason %s, got %q", player.Name, reason, rejected.Reason)
+	}
+	return nil
+}
+
+// inviteOnly lets in invited users and users of invited domains only.
+func inviteOnly(h *Harness) error {
+	invited := h.Repository.AddUser("Invited", plan_types.Basic)
+	colleague := h.Repository.AddUser("Colleague", plan_types.Basic)
+	h.Repository.SetEmail(colleague.Id, "colleague@Partner.example")
+	stranger := h.Repository.AddUser("Stranger", plan_types.Basic)
+	h.Repository.SetEmail(stranger.Id, "stranger@example.com")
+
+	host, state, err := hostWithAccess(h, models.GameAccess{
+		InvitedUsers:   []uuid.UUID{invited.Id},
+		InvitedDomains: []string{"partner.example"},
+	})
+	if err != nil {
+		return err
+	}
+	players := []*Player{host}
+	for _, user := range []models.User{invited, colleague} {
+		player, err := h.ConnectUser(user)
+		if err != nil {
+			return err
+		}
+		if err := join(player, state.Id); err != nil {
+			return err
+		}
+		if err := expectAll(players, game.JoinGameAction); err != nil {
+			return err
+		}
+		players = append(players, player)
+	}
+
+	player, err := h.ConnectUser(stranger)
+	if err != nil {
+		return err
+	}
+	if err := player.JoinGame(state.Id); err != nil {
+		return err
+	}
+	if err := expectAccessError(player, game.NotInvited); err != nil {
+		return err
+	}
+	guest, err := h.ConnectGuest("Guest")
+	if err != nil {
+		return err
+	}
+	if err := guest.JoinGame(state.Id); err != nil {
+		return err
+	}
+	if err := expectAccessError(guest, game.NotInvited); err != nil {
+		return err
+	}
+	return host.ExpectSilence(100 * time.Millisecond)
+}
+
+// passcodeAndJoinCode joins a game by its join code with a passcode.
+func passcodeAndJoinCode(h *Harness) error {
+	host, state, err := hostWithAccess(h, models.GameAccess{Passcode: "4821"})
+	if err != nil {
+		return err
+	}
+	if len(state.JoinCode) != game.JoinCodeLength {
+		return fmt.Errorf("join code is %q", state.JoinCode)
+	}
+	guest, err := h.ConnectGuest("Guest")
+	if err != nil {
+		return err
+	}
+	if err := guest.JoinGameByCode("XXXXXX", "4821"); err != nil {
+		return err
+	}
+	if _, err := guest.ExpectError(2); err != nil {
+		return err
+	}
+	for _, passcode := range []string{"", "1234"} {
+		if err := guest.JoinGameByCode(state.JoinCode, passcode); err != nil {
+			return err
+		}
+		if err := expectAccessError(guest, game.InvalidPasscode); err != nil {
+			return err
+		}
+	}
+	// Codes are typed in any case and with separators.
+	typed := strings.ToLower(state.JoinCode[:3] + "-" + state.JoinCode[3:])
+	if err := guest.JoinGameByCode(typed, "4821"); err != nil {
+		return err
+	}
+	if _, err := guest.Expect(game.UserJoinedAction); err != nil {
+		return err
+	}
+	_, err = host.Expect(game.JoinGameAction)
+	return err
+}
+
+// waitingRoom keeps guests waiting until the host admits or denies them.
+func waitingRoom(h *Harness) error {
+	host, state, err := hostWithAccess(h, models.GameAccess{WaitingRoom: true})
+	if err != nil {
+		return err
+	}
+	var guests []*Player
+	for _, name := range []string{"Alice", "Bob"} {
+		guest, err := h.ConnectGuest(name)
+		if err != nil {
+			return err
+		}
+		if err := guest.JoinGame(state.Id); err != nil {
+			return err
+		}
+		if _, err := guest.Expect(game.WaitingRoomAction); err != nil {
+			return err
+		}
+		request, err := host.Expect(game.JoinRequestAction)
+		if err != nil {
+			return err
+		}
+		if waiting, ok := request.Data.(*client.User); !ok || waiting.Id != guest.Id {
+			return fmt.Errorf("join-request payload is %s, expected %s", request.Payload, guest.Id)
+		}
+		guests = append(guests, guest)
+	}
+
+	// Players cannot admit guests.
+	if err := guests[0].AdmitGuest(state.Id, guests[1].Id, true); err != nil {
+		return err
+	}
+	if err := expectDenied(guests[0], game.PermissionAdmitGuests); err != nil {
+		return err
+	}
+	if err := host.AdmitGuest(state.Id, guests[0].Id, true); err != nil {
+		return err
+	}
+	if _, err := guests[0].Expect(game.UserJoinedAction); err != nil {
+		return err
+	}
+	if _, err := host.Expect(game.JoinGameAction); err != nil {
+		return err
+	}
+	if err := host.AdmitGuest(state.Id, guests[1].Id, false); err != nil {
+		return err
+	}
+	if err := expectAccessError(guests[1], game.JoinDenied); err != nil {
+		return err
+	}
+
+	// A guest who left the waiting room by disconnecting cannot be admitted.
+	gone, err := h.ConnectGuest("Carol")
+	if err != nil {
+		return err
+	}
+	if err := gone.JoinGame(state.Id); err != nil {
+		return err
+	}
+	if _, err := host.Expect(game.JoinRequestAction); err != nil {
+		return err
+	}
+	gone.Close()
+	time.Sleep(50 * time.Millisecond)
+	if err := host.AdmitGuest(state.Id, gone.Id, true); err != nil {
+		return err
+	}
+	if _, err := host.ExpectError(9); err != nil {
+		return err
+	}
+
+	// An admitted guest rejoins without waiting.
+	reconnected, err := h.ReconnectGuest(guests[0])
+	if err != nil {
+		return err
+	}
+	return join(reconnected, state.Id)
+}
@@ -0,0 +1,421 @@
+package e2e
+
+import (
+	"GameService/auth"
+	"GameService/client"
+	"GameService/config"
+	"GameService/consts/plan_types"
+	"GameService/game"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// limitRates limits chat messages to a burst of two and escalates to
+// disconnect on the second mute.
+func limitRates(settings *game.Settings) {
+	settings.RateLimit = config.RateLimit{
+		Enabled:  true,
+		Messages: config.Limit{Rate: 100, Burst: 100},
+		Actions: map[string]config.Limit{
+			game.SendMessageAction: {Rate: 0.001, Burst: 2},
+		},
+		Warnings:              1,
+		MuteDuration:          200 * time.Millisecond,
+		MutesBeforeDisconnect: 1,
+		ConnectionsPerIP:      2,
+	}
+}
+
+// rateLimitEscalation floods chat and expects a warning, a mute and then a disconnect.
+func rateLimitEscalation(h *Harness) error {
+	h.Configure(limitRates)
+	l, err := newLobby(h)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < 5; i++ {
+		if err := l.host.SendMessage(l.game.Id, fmt.Sprintf("spam %d", i)); err != nil {
+			return err
+		}
+	}
+	if err := l.host.ExpectSequence(game.SendMessageAction, game.SendMessageAction); err != nil {
+		return err
+	}
+	warning, err := l.host.ExpectError(15)
+	if err != nil {
+		return err
+	}
+	if warning.Reason != game.RateLimited {
+		return fmt.Errorf("expected reason %s, got %q", game.RateLimited, warning.Reason)
+	}
+	if _, err := l.host.ExpectError(15); err != nil {
+		return err
+	}
+	// The fifth message is dropped while the host is muted.
+	if err := l.host.ExpectSilence(100 * time.Millisecond); err != nil {
+		return err
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		if err := l.host.SendMessage(l.game.Id, "spam again"); err != nil {
+			return err
+		}
+	}
+	if _, err := l.host.ExpectError(15); err != nil {
+		return err
+	}
+	if _, ok := <-l.host.Events(); ok {
+		return errors.New("host is not disconnected after the second mute")
+	}
+	var closeError *websocket.CloseError
+	if !errors.As(l.host.Err(), &closeError) || closeError.Code != websocket.ClosePolicyViolation {
+		return fmt.Errorf("expected policy violation close, got %v", l.host.Err())
+	}
+	return nil
+}
+
+// connectionsPerIP connects more clients from one address than allowed.
+func connectionsPerIP(h *Harness) error {
+	h.Configure(limitRates)
+	for _, name := range []string{"Alice", "Bob"} {
+		if _, err := h.ConnectGuest(name); err != nil {
+			return err
+		}
+	}
+	if _, err := h.ConnectGuest("Carol"); err == nil {
+		return errors.New("third connection from the address is accepted")
+	} else if !strings.Contains(err.Error(), "status 429") {
+		return fmt.Errorf("expected status 429, got %w", err)
+	}
+	return nil
+}
+
+// forwardedFor limits connections by the client address named by a trusted proxy.
+// Addresses the client prepends to X-Forwarded-For are ignored.
+func forwardedFor(h *Harness) error {
+	h.Configure(func(settings *game.Settings) {
+		limitRates(settings)
+		settings.RateLimit.ConnectionsPerIP = 1
+		settings.RateLimit.TrustedProxies = []string{"127.0.0.1", "::1"}
+	})
+
+	for _, step := range []struct {
+		forwardedFor string
+		status       int
+	}{
+		{"203.0.113.7", http.StatusSwitchingProtocols},
+		{"198.51.100.1, 203.0.113.7", http.StatusTooManyRequests},
+		{"203.0.113.8", http.StatusSwitchingProtocols},
+	} {
+		conn, response, err := websocket.DefaultDialer.Dial(wsURL(h)+"/ws?name=Guest",
+			http.Header{"X-Forwarded-For": {step.forwardedFor}})
+		if conn != nil {
+			defer conn.Close()
+		}
+		if response == nil {
+			return err
+		}
+		if response.StatusCode != step.status {
+			return fmt.Errorf("connection forwarded for %q: status %d, expected %d",
+				step.forwardedFor, response.StatusCode, step.status)
+		}
+	}
+	return nil
+}
+
+// ticketConnect connects a user with a ticket and checks the ticket cannot be reused.
+func ticketConnect(h *Harness) error {
+	user, gameInfo := h.NewGame(plan_types.Basic)
+	token := h.Repository.Token(user.Id, "user")
+	ticket, err := client.IssueTicket(context.Background(), h.URL(), token)
+	if err != nil {
+		return err
+	}
+	player, err := h.connect(client.Credentials{Ticket: ticket}, user.FirstName)
+	if err != nil {
+		return err
+	}
+	if err := join(player, gameInfo.Id); err != nil {
+		return err
+	}
+	if player.User().Id != user.Id {
+		return fmt.Errorf("joined as %s, expected %s", player.User().Id, user.Id)
+	}
+	if _, err := h.connect(client.Credentials{Ticket: ticket}, user.FirstName); err == nil {
+		return errors.New("ticket is accepted twice")
+	} else if !strings.Contains(err.Error(), "status 401") {
+		return fmt.Errorf("expected status 401, got %w", err)
+	}
+	if _, err := client.IssueTicket(context.Background(), h.URL(), h.Repository.Token(user.Id, "service")); err == nil {
+		return errors.New("ticket is issued for token without user or admin access")
+	}
+	return nil
+}
+
+// subprotocolToken connects a user as a browser does, with the token in Sec-WebSocket-Protocol.
+func subprotocolToken(h *Harness) error {
+	user := h.Repository.AddUser("Host", plan_types.Basic)
+	dialer := websocket.Dialer{
+		Subprotocols: []string{game.Subprotocol, game.BearerProtocolPrefix + h.Repository.Token(user.Id, "user")},
+	}
+	conn, _, err := dialer.Dial(wsURL(h)+"/ws", nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if conn.Subprotocol() != game.Subprotocol {
+		return fmt.Errorf("expected subprotocol %s, got %q", game.Subprotocol, conn.Subprotocol())
+	}
+	return nil
+}
+
+// rejectedUpgrades expects HTTP errors for connection requests which are not allowed.
+func rejectedUpgrades(h *Harness) error {
+	user := h.Repository.AddUser("Host", plan_types.Basic)
+	token := h.Repository.Token(user.Id, "user")
+	requests := []struct {
+		name   string
+		path   string
+		header http.Header
+		status int
+	}{
+		{"no credentials", "/ws", nil, http.StatusBadRequest},
+		{"token in query", "/ws?token=" + token, nil, http.StatusBadRequest},
+		{"invalid token", "/ws", http.Header{"Authorization": {"Bearer invalid"}}, http.StatusUnauthorized},
+		{"service token", "/ws", http.Header{"Authorization": {"Bearer " + h.Repository.Token(user.Id, "service")}},
+			http.StatusForbidden},
+		{"unknown ticket", "/ws?ticket=unknown", nil, http.StatusUnauthorized},
+		{"guest token in query", "/ws?guest=token", nil, http.StatusBadRequest},
+		{"invalid guest token", "/ws", http.Header{game.GuestTokenHeader: {"invalid"}}, http.StatusUnauthorized},
+		{"foreign origin", "/ws?name=Guest", http.Header{"Origin": {"https://evil.example.com"}}, http.StatusForbidden},
+	}
+	for _, request := range requests {
+		_, response, err := websocket.DefaultDialer.Dial(wsURL(h)+request.path, request.header)
+		if err == nil {
+			return fmt.Errorf("%s: connection is accepted", request.name)
+		}
+		if response == nil || response.StatusCode != request.status {
+			return fmt.Errorf("%s: expected status %d, got %v", request.name, request.status, err)
+		}
+	}
+	return nil
+}
+
+// expectRejected expects the connection request to be rejected with status and message.
+func expectRejected(h *Harness, path string, status int, message string) error {
+	conn, response, err := websocket.DefaultDialer.Dial(wsURL(h)+path, nil)
+	if err == nil {
+		conn.Close()
+		return errors.New("connection is accepted")
+	}
+	if response == nil {
+		return err
+	}
+	var body game.ErrorMessage
+	_ = json.NewDecoder(response.Body).Decode(&body)
+	if response.StatusCode != status || body.Message != message {
+		return fmt.Errorf("expected %d %q, got %d %q", status, message, response.StatusCode, body.Message)
+	}
+	return nil
+}
+
+// wsURL returns the WebSocket address of the harness server.
+func wsURL(h *Harness) string {
+	return "ws" + strings.TrimPrefix(h.URL(), "http")
+}
+
+// jwksRotation connects users with tokens verified by keys of a JWKS endpoint,
+// returns reasons of rejected tokens and picks up rotated keys. Claims and
+// algorithms are checked by tests of package auth.
+func jwksRotation(h *Harness) error {
+	reloadInterval := auth.MinReloadInterval
+	auth.MinReloadInterval = 0
+	defer func() { auth.MinReloadInterval = reloadInterval }()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	rotatedKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	keys := map[string]interface{}{"rsa-1": &rsaKey.PublicKey, "ec-1": &ecKey.PublicKey}
+	var mutex sync.Mutex
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		// Keys of types the service does not support are published next to its keys.
+		document := struct {
+			Keys []json.RawMessage `json:"keys"`
+		}{Keys: []json.RawMessage{json.RawMessage(`{"kty":"OKP","crv":"Ed25519","kid":"ed-1","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}`)}}
+		for id, key := range keys {
+			encoded, _ := auth.JWK(id, key)
+			document.Keys = append(document.Keys, encoded)
+		}
+		_ = json.NewEncoder(w).Encode(document)
+	}))
+	defer jwks.Close()
+	h.Repository.SetVerifier(auth.NewVerifier(config.Auth{
+		JWKSURL:             jwks.URL,
+		JWKSRefreshInterval: time.Hour,
+		Issuer:              "connect-team",
+		Audience:            "game-service",
+		Leeway:              time.Second,
+	}))
+
+	user := h.Repository.AddUser("Host", plan_types.Basic)
+	sign := func(method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(method, jwt.MapClaims{
+			"user_id": user.Id.String(),
+			"access":  "user",
+			"iss":     "connect-team",
+			"aud":     []string{"game-service"},
+			"exp":     time.Now().Add(time.Minute).Unix(),
+		})
+		for name, value := range claims {
+			token.Claims.(jwt.MapClaims)[name] = value
+		}
+		token.Header["kid"] = kid
+		signed, _ := token.SignedString(key)
+		return signed
+	}
+	checks := []struct {
+		name   string
+		token  string
+		reason string
+	}{
+		{"RS256", sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, nil), ""},
+		{"ES256", sign(jwt.SigningMethodES256, "ec-1", ecKey, nil), ""},
+		{"audience", sign(jwt.SigningMethodES256, "ec-1", ecKey, jwt.MapClaims{"aud": "other-service"}), "audience"},
+		{"unknown kid", sign(jwt.SigningMethodRS256, "rsa-2", rotatedKey, nil), "unknown_key"},
+	}
+	for _, check := range checks {
+		if err := expectConnection(h, check.token, check.reason); err != nil {
+			return fmt.Errorf("%s: %w", check.name, err)
+		}
+	}
+
+	// ConnectTeam rotates rsa-1 to rsa-2.
+	mutex.Lock()
+	delete(keys, "rsa-1")
+	keys["rsa-2"] = &rotatedKey.PublicKey
+	mutex.Unlock()
+	if err := expectConnection(h, sign(jwt.SigningMethodRS256, "rsa-2", rotatedKey, nil), ""); err != nil {
+		return fmt.Errorf("rotated key: %w", err)
+	}
+	if err := expectConnection(h, sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, nil), "unknown_key"); err != nil {
+		return fmt.Errorf("retired key: %w", err)
+	}
+	return nil
+}
+
+// expectConnection connects with the token and expects the connection to be
+// accepted when reason is empty or rejected with the reason otherwise.
+func expectConnection(h *Harness, token string, reason string) error {
+	conn, response, err := websocket.DefaultDialer.Dial(wsURL(h)+"/ws", http.Header{"Authorization": {"Bearer " + token}})
+	if err == nil {
+		conn.Close()
+		if reason != "" {
+			return fmt.Errorf("connection is accepted, expected %s", reason)
+		}
+		return nil
+	}
+	if response == nil {
+		return err
+	}
+	var body game.ErrorMessage
+	_ = json.NewDecoder(response.Body).Decode(&body)
+	if reason == "" {
+		return fmt.Errorf("connection is rejected: %d %s", response.StatusCode, body.Message)
+	}
+	if response.StatusCode != http.StatusUnauthorized || !strings.HasSuffix(body.Message, ": "+reason) {
+		return fmt.Errorf("expected 401 with reason %s, got %d %q", reason, response.StatusCode, body.Message)
+	}
+	return nil
+}
+
+// guestReconnect restores the identity of a guest who reconnects to the game in progress.
+func guestReconnect(h *Harness) error {
+	l, err := newLobby(h, "Guest")
+	if err != nil {
+		return err
+	}
+	if err := l.start(); err != nil {
+		return err
+	}
+	guest := l.players[0]
+	guest.Close()
+
+	// Browsers offer the guest token as a subprotocol as they cannot set headers.
+	dialer := websocket.Dialer{
+		Subprotocols: []string{game.Subprotocol, game.GuestProtocolPrefix + guest.Guest().Token},
+	}
+	conn, _, err := dialer.Dial(wsURL(h)+"/ws", nil)
+	if err != nil {
+		return fmt.Errorf("guest token subprotocol: %w", err)
+	}
+	conn.Close()
+	if conn.Subprotocol() != game.Subprotocol {
+		return fmt.Errorf("expected subprotocol %s, got %q", game.Subprotocol, conn.Subprotocol())
+	}
+
+	again, err := h.ReconnectGuest(guest)
+	if err != nil {
+		return err
+	}
+	if again.Id != guest.Id || again.User().Name != "Guest" {
+		return fmt.Errorf("reconnected as %s %q, expected %s", again.Id, again.User().Name, guest.Id)
+	}
+	if again.Guest().Token != guest.Guest().Token {
+		return errors.New("guest token changed on reconnect")
+	}
+	return join(again, l.game.Id)
+}
+
+// guestNames rejects connections of guests with invalid names and normalizes
+// names of connected guests. Name rules are checked by tests of package game.
+func guestNames(h *Harness) error {
+	h.Configure(func(settings *game.Settings) {
+		settings.Guests.NameMaxLength = 10
+		settings.Guests.BlockedWords = []string{"Darn"}
+	})
+
+	rejected := map[string]string{
+		"Bartholomew1": "name must be from 2 to 10 characters long",
+		"Oh-DARN":      "name is not allowed",
+	}
+	for name, message := range rejected {
+		if err := expectRejected(h, "/ws?name="+url.QueryEscape(name), http.StatusBadRequest, message); err != nil {
+			return fmt.Errorf("name %q: %w", name, err)
+		}
+	}
+
+	guest, err := h.ConnectGuest(" Ann \t Lee\u200b ")
+	if err != nil {
+		return err
+	}
+	if name := guest.User().Name; name != "Ann Lee" || guest.Guest().Name != "Ann Lee" {
+		return fmt.Errorf("name is normalized to %q, expected %q", name, "Ann Lee")
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package e2e
+
+import (
+	"github.com/sirupsen/logrus"
+	"testing"
+)
+
+// TestScenarios plays every scenario on a new server. Server logs are printed with -v.
+func TestScenarios(t *testing.T) {
+	if !testing.Verbose() {
+		logrus.SetLevel(logrus.FatalLevel)
+	}
+	for _, s := range scenarios {
+		s := s
+		t.Run(s.name, func(t *testing.T) {
+			h := New()
+			defer h.Close()
+			if err := s.run(h); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
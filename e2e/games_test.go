@@ -0,0 +1,364 @@
+package e2e
+
+import (
+	"GameService/client"
+	"GameService/config"
+	"GameService/consts/game_status"
+	"GameService/consts/plan_types"
+	"GameService/game"
+	"GameService/repository/fake"
+	"GameService/repository/requests"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// fullGame plays one round where every player answers and is rated by others.
+func fullGame(h *Harness) error {
+	l, err := newLobby(h, "Guest")
+	if err != nil {
+		return err
+	}
+	if err := l.start(); err != nil {
+		return err
+	}
+	if err := l.host.Send(game.StartRoundAction, l.game.Id, l.topic.Id.String()); err != nil {
+		return err
+	}
+	if err := expectAll(l.all(), game.StartRoundAction); err != nil {
+		return err
+	}
+
+	for range l.all() {
+		if err := l.host.Send(game.StartStageAction, l.game.Id, nil); err != nil {
+			return err
+		}
+		var respondent client.UserQuestion
+		for _, player := range l.all() {
+			message, err := player.Expect(game.StartStageAction)
+			if err != nil {
+				return err
+			}
+			if err := message.Decode(&respondent); err != nil {
+				return err
+			}
+		}
+		var answering *Player
+		for _, player := range l.all() {
+			if player.Id == respondent.User.Id {
+				answering = player
+			}
+		}
+		if answering == nil {
+			return fmt.Errorf("respondent %s is not a player", respondent.User.Id)
+		}
+		for _, action := range []string{game.UserStartAnswerAction, game.UserEndAnswerAction} {
+			if err := answering.Send(action, l.game.Id, nil); err != nil {
+				return err
+			}
+			if err := expectAll(l.all(), action); err != nil {
+				return err
+			}
+		}
+		for _, player := range l.all() {
+			if player == answering {
+				continue
+			}
+			if err := player.Send(game.RateAction, l.game.Id, map[string]interface{}{
+				"value":   5,
+				"user_id": answering.Id,
+			}); err != nil {
+				return err
+			}
+		}
+		if err := expectAll(l.all(), game.RateAction, game.RateEndAction); err != nil {
+			return err
+		}
+	}
+
+	if err := l.host.Send(game.StartStageAction, l.game.Id, nil); err != nil {
+		return err
+	}
+	if err := expectAll(l.all(), game.GameEndedAction); err != nil {
+		return err
+	}
+	results, ok := h.Repository.Results(l.game.Id)
+	if !ok || len(results) != len(l.all()) {
+		return fmt.Errorf("results of %d players are saved, expected %d", len(results), len(l.all()))
+	}
+	for _, result := range results {
+		if result.Value != 5 {
+			return fmt.Errorf("%s has %d points, expected 5", result.Name, result.Value)
+		}
+	}
+	return expectStatus(h, l.game.Id, game_status.GameEnded)
+}
+
+func creatorEndsGame(h *Harness) error {
+	l, err := newLobby(h, "Guest")
+	if err != nil {
+		return err
+	}
+	if err := l.start(); err != nil {
+		return err
+	}
+	if err := l.host.Send(game.EndGameAction, l.game.Id, nil); err != nil {
+		return err
+	}
+	if err := expectAll(l.all(), game.GameAbortedAction); err != nil {
+		return err
+	}
+	if _, ok := h.Repository.Results(l.game.Id); ok {
+		return errors.New("results of aborted game are saved")
+	}
+	return expectStatus(h, l.game.Id, game_status.GameEnded)
+}
+
+func playerCannotEndGame(h *Harness) error {
+	l, err := newLobby(h, "Guest")
+	if err != nil {
+		return err
+	}
+	if err := l.start(); err != nil {
+		return err
+	}
+	if err := l.players[0].Send(game.EndGameAction, l.game.Id, nil); err != nil {
+		return err
+	}
+	if err := expectDenied(l.players[0], game.PermissionEndGame); err != nil {
+		return err
+	}
+	if err := l.host.ExpectSilence(200 * time.Millisecond); err != nil {
+		return err
+	}
+	return expectStatus(h, l.game.Id, game_status.GameInProgress)
+}
+
+func creatorLeavesGame(h *Harness) error {
+	l, err := newLobby(h, "Guest")
+	if err != nil {
+		return err
+	}
+	if err := l.start(); err != nil {
+		return err
+	}
+	if err := l.host.Send(game.LeaveGameAction, l.game.Id, nil); err != nil {
+		return err
+	}
+	if err := l.players[0].ExpectSequence(game.UserLeftAction, game.GameAbortedAction); err != nil {
+		return err
+	}
+	return expectStatus(h, l.game.Id, game_status.GameEnded)
+}
+
+func kickPlayer(h *Harness) error {
+	l, err := newLobby(h, "Guest", "Spoiler")
+	if err != nil {
+		return err
+	}
+	kicked := l.players[1]
+	if err := l.host.Send(game.DeleteUserAction, l.game.Id, kicked.Id.String()); err != nil {
+		return err
+	}
+	for _, player := range l.all() {
+		message, err := player.Expect(game.UserDeletedAction)
+		if err != nil {
+			return err
+		}
+		var userId uuid.UUID
+		if err := message.Decode(&userId); err != nil || userId != kicked.Id {
+			return fmt.Errorf("%s: user-deleted payload is %s, expected %s", player.Name, message.Payload, kicked.Id)
+		}
+	}
+	if err := kicked.ExpectSilence(200 * time.Millisecond); err != nil {
+		return err
+	}
+
+	// The kicked player does not receive messages of the game anymore.
+	if err := l.host.Send(game.SendMessageAction, l.game.Id, "hello"); err != nil {
+		return err
+	}
+	if err := expectAll([]*Player{l.host, l.players[0]}, game.SendMessageAction); err != nil {
+		return err
+	}
+	return kicked.ExpectSilence(200 * time.Millisecond)
+}
+
+// questionsUpstreamDown checks that the game does not start without questions
+// and starts once ConnectTeam recovers.
+func questionsUpstreamDown(h *Harness) error {
+	l, err := newLobby(h, "Guest")
+	if err != nil {
+		return err
+	}
+	h.Repository.Inject("GetRandQuestionsWithLimit", fake.Fault{
+		Err: &requests.UpstreamError{Call: "GetRandQuestionsWithLimit", Status: 503, Kind: requests.ErrUpstreamDown},
+	})
+	if err := l.host.Send(game.SelectTopicAction, l.game.Id, nil); err != nil {
+		return err
+	}
+	if _, err := l.host.Expect(game.SelectTopicAction); err != nil {
+		return err
+	}
+	if err := l.host.Send(game.StartGameAction, l.game.Id, nil); err != nil {
+		return err
+	}
+	if _, err := l.host.ExpectError(4); err != nil {
+		return err
+	}
+	if err := expectStatus(h, l.game.Id, game_status.GameNotStarted); err != nil {
+		return err
+	}
+
+	h.Repository.Reset()
+	if err := l.host.Send(game.StartGameAction, l.game.Id, nil); err != nil {
+		return err
+	}
+	if err := expectAll(l.all(), game.StartGameAction); err != nil {
+		return err
+	}
+	return expectStatus(h, l.game.Id, game_status.GameInProgress)
+}
+
+// slowUpstreamDeadline checks that a slow upstream call is cancelled at the action deadline.
+func slowUpstreamDeadline(h *Harness) error {
+	h.Configure(func(settings *game.Settings) {
+		settings.WebSocket.ActionTimeouts = map[string]time.Duration{game.StartGameAction: 100 * time.Millisecond}
+	})
+
+	l, err := newLobby(h, "Guest")
+	if err != nil {
+		return err
+	}
+	h.Repository.Inject("StartGame", fake.Fault{Latency: time.Minute, Times: 1})
+	if err := l.host.Send(game.SelectTopicAction, l.game.Id, nil); err != nil {
+		return err
+	}
+	if _, err := l.host.Expect(game.SelectTopicAction); err != nil {
+		return err
+	}
+	started := time.Now()
+	if err := l.host.Send(game.StartGameAction, l.game.Id, nil); err != nil {
+		return err
+	}
+	if _, err := l.host.ExpectError(4); err != nil {
+		return err
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		return fmt.Errorf("start-game failed after %s, expected the action deadline", elapsed)
+	}
+	return expectStatus(h, l.game.Id, game_status.GameNotStarted)
+}
+
+// malformedUser checks that a user is not connected when ConnectTeam returns a malformed user.
+func malformedUser(h *Harness) error {
+	user := h.Repository.AddUser("Host", plan_types.Basic)
+	h.Repository.Inject("GetUserById", fake.Fault{Malformed: true})
+	if _, err := h.ConnectUser(user); err == nil {
+		return errors.New("user with malformed profile is connected")
+	}
+	h.Repository.Reset()
+	_, err := h.ConnectUser(user)
+	return err
+}
+
+func botsDisabled(h *Harness) error {
+	l, err := newLobby(h)
+	if err != nil {
+		return err
+	}
+	if err := l.host.AddBot(l.game.Id, ""); err != nil {
+		return err
+	}
+	_, err = l.host.ExpectError(14)
+	return err
+}
+
+// botFillsGame plays a game of the host and a bot which answers and rates by itself.
+func botFillsGame(h *Harness) error {
+	h.Configure(func(settings *game.Settings) {
+		settings.Features = map[string]bool{game.BotsFlag: true}
+		settings.Bots = config.Bots{AnswerDelay: 10 * time.Millisecond, RateDelay: 10 * time.Millisecond, MaxPerGame: 1}
+	})
+
+	l, err := newLobby(h)
+	if err != nil {
+		return err
+	}
+	if err := l.host.AddBot(l.game.Id, "Robo"); err != nil {
+		return err
+	}
+	message, err := l.host.Expect(game.JoinGameAction)
+	if err != nil {
+		return err
+	}
+	if message.Sender == nil || !message.Sender.Bot || message.Sender.Name != "Robo" {
+		return fmt.Errorf("joined %s, expected bot Robo", message.Payload)
+	}
+	bot := message.Sender.Id
+	if err := l.host.AddBot(l.game.Id, ""); err != nil {
+		return err
+	}
+	if _, err := l.host.ExpectError(14); err != nil {
+		return err
+	}
+	if err := l.start(); err != nil {
+		return err
+	}
+	if err := l.host.StartRound(l.game.Id, l.topic.Id); err != nil {
+		return err
+	}
+	if _, err := l.host.Expect(game.StartRoundAction); err != nil {
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := l.host.StartStage(l.game.Id); err != nil {
+			return err
+		}
+		message, err := l.host.Expect(game.StartStageAction)
+		if err != nil {
+			return err
+		}
+		respondent, _ := message.Data.(*client.UserQuestion)
+		if respondent == nil {
+			return fmt.Errorf("start-stage payload is %s", message.Payload)
+		}
+		if respondent.User.Id == bot {
+			if err := l.host.ExpectSequence(game.UserStartAnswerAction, game.UserEndAnswerAction); err != nil {
+				return err
+			}
+			if err := l.host.Rate(l.game.Id, bot, 5); err != nil {
+				return err
+			}
+		} else {
+			for _, send := range []func(uuid.UUID) error{l.host.StartAnswer, l.host.EndAnswer} {
+				if err := send(l.game.Id); err != nil {
+					return err
+				}
+			}
+			if err := l.host.ExpectSequence(game.UserStartAnswerAction, game.UserEndAnswerAction); err != nil {
+				return err
+			}
+		}
+		if err := l.host.ExpectSequence(game.RateAction, game.RateEndAction); err != nil {
+			return err
+		}
+	}
+
+	if err := l.host.StartStage(l.game.Id); err != nil {
+		return err
+	}
+	if _, err := l.host.Expect(game.GameEndedAction); err != nil {
+		return err
+	}
+	results, _ := h.Repository.Results(l.game.Id)
+	if len(results) != 1 || results[0].UserId != l.host.Id {
+		return fmt.Errorf("results %v are saved, expected results of the host only", results)
+	}
+	if results[0].Value != 0 {
+		return fmt.Errorf("host has %d points, expected rates of bots not to count", results[0].Value)
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+// Package e2e runs the game server against the in-memory fake repository and
+// drives it with scripted WebSocket clients. Scenarios are run by
+// go test ./e2e, and cmd/loadtest plays games with the same harness.
+package e2e
+
+import (
+	"GameService/client"
+	"GameService/config"
+	"GameService/consts/plan_types"
+	"GameService/game"
+	"GameService/repository/fake"
+	"GameService/repository/models"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// ExpectTimeout is max time to wait for a message.
+var ExpectTimeout = 2 * time.Second
+
+const signingKey = "e2e-signing-key"
+
+// Harness serves ServeWs of a game server backed by fake repository.
+type Harness struct {
+	Repository *fake.Repository
+	Server     *game.WsServer
+	http       *httptest.Server
+	mutex      sync.Mutex
+	players    []*Player
+}
+
+// DefaultSettings returns settings of the game server used by New.
+// Games of the basic plan have one random topic and no meeting.
+func DefaultSettings() game.Settings {
+	return game.Settings{
+		WebSocket: config.WebSocket{
+			WriteWait:       10 * time.Second,
+			PongWait:        60 * time.Second,
+			PingPeriod:      54 * time.Second,
+			MaxMessageSize:  10000,
+			ReadBufferSize:  4096,
+			WriteBufferSize: 4096,
+			SendBufferSize:  256,
+			ActionTimeout:   5 * time.Second,
+			TicketTTL:       time.Minute,
+		},
+		Plans: map[string]models.Entitlements{
+			plan_types.Basic:    {MaxPlayers: 3, TopicCount: 1},
+			plan_types.Advanced: {MaxPlayers: 5, CustomTopics: true, Spectators: 2, Timers: true, Exports: true},
+			plan_types.Premium:  {MaxPlayers: 10, CustomTopics: true, Spectators: 10, Timers: true, Exports: true},
+		},
+		EntitlementsSource:             config.EntitlementsSourceConfig,
+		EntitlementsRevalidateInterval: time.Hour,
+		Guests:                         config.Guests{TokenTTL: time.Hour, NameMinLength: 2, NameMaxLength: 32},
+	}
+}
+
+// New starts a game server with DefaultSettings.
+func New() *Harness {
+	return NewWithSettings(DefaultSettings())
+}
+
+// NewWithSettings starts a game server with settings.
+func NewWithSettings(settings game.Settings) *Harness {
+	repository := fake.New(signingKey)
+	server := game.NewWebsocketServer(repository.Repository(), game.NewJWTGenerator("key", "secret"), settings)
+	go server.Run()
+	h := &Harness{Repository: repository, Server: server}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		game.ServeWs(server, w, r)
+	})
+	mux.Handle("/ws/ticket", game.NewTicketHandler(server))
+	h.http = httptest.NewServer(mux)
+	return h
+}
+
+// Configure applies change to DefaultSettings and updates settings of the server.
+func (h *Harness) Configure(change func(settings *game.Settings)) {
+	settings := DefaultSettings()
+	change(&settings)
+	h.Server.UpdateSettings(settings)
+}
+
+// NewGame adds a user of the plan and a game created by the user.
+func (h *Harness) NewGame(planType string) (models.User, models.Game) {
+	user := h.Repository.AddUser("Host", planType)
+	return user, h.Repository.AddGame("e2e", user.Id)
+}
+
+// HostGame connects the creator of the game who joins it, and returns the game
+// sent on joining.
+func (h *Harness) HostGame(user models.User, gameInfo models.Game) (*Player, *client.Game, error) {
+	host, err := h.ConnectUser(user)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := host.JoinGame(gameInfo.Id); err != nil {
+		return nil, nil, err
+	}
+	joined, err := host.Expect(game.UserJoinedAction)
+	if err != nil {
+		return nil, nil, err
+	}
+	state, ok := joined.Data.(*client.Game)
+	if !ok {
+		return nil, nil, fmt.Errorf("cannot decode game %s", joined.Payload)
+	}
+	return host, state, nil
+}
+
+// URL returns the address of the HTTP server, e.g. http://127.0.0.1:1234.
+func (h *Harness) URL() string {
+	return h.http.URL
+}
+
+// Close disconnects players and stops the HTTP server.
+func (h *Harness) Close() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for _, player := range h.players {
+		player.Close()
+	}
+	h.http.Close()
+}
+
+// ConnectUser connects the user with a signed token.
+func (h *Harness) ConnectUser(user models.User) (*Player, error) {
+	player, err := h.connect(client.Credentials{Token: h.Repository.Token(user.Id, "user")}, user.FirstName)
+	if err != nil {
+		return nil, err
+	}
+	player.Id = user.Id
+	return player, nil
+}
+
+// ConnectAdmin connects the user with an admin token, see game.RoleAdmin.
+func (h *Harness) ConnectAdmin(user models.User) (*Player, error) {
+	player, err := h.connect(client.Credentials{Token: h.Repository.Token(user.Id, "admin")}, user.FirstName)
+	if err != nil {
+		return nil, err
+	}
+	player.Id = user.Id
+	return player, nil
+}
+
+// ConnectGuest connects a guest and receives the guest identity.
+func (h *Harness) ConnectGuest(name string) (*Player, error) {
+	return h.connectGuest(client.Credentials{Name: name}, name)
+}
+
+// ReconnectGuest connects the guest with the token of its identity.
+func (h *Harness) ReconnectGuest(guest *Player) (*Player, error) {
+	identity := guest.Guest()
+	if identity == nil {
+		return nil, fmt.Errorf("%s: guest identity is not received", guest.Name)
+	}
+	return h.connectGuest(client.Credentials{GuestToken: identity.Token}, guest.Name)
+}
+
+func (h *Harness) connectGuest(credentials client.Credentials, name string) (*Player, error) {
+	player, err := h.connect(credentials, name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := player.Expect(game.GuestIdentityAction); err != nil {
+		return nil, err
+	}
+	player.Id = player.User().Id
+	return player, nil
+}
+
+func (h *Harness) connect(credentials client.Credentials, name string) (*Player, error) {
+	c, err := client.Dial(context.Background(), h.http.URL, credentials)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	player := &Player{Name: name, Client: c}
+	h.mutex.Lock()
+	h.players = append(h.players, player)
+	h.mutex.Unlock()
+	return player, nil
+}
+
+// Received is a message received by a player.
+type Received = client.Event
+
+// Player is a scripted WebSocket client.
+type Player struct {
+	*client.Client
+	Id   uuid.UUID
+	Name string
+}
+
+// Expect returns the next message and fails if its action is not action.
+func (p *Player) Expect(action string) (Received, error) {
+	select {
+	case message, ok := <-p.Events():
+		if !ok {
+			return Received{}, fmt.Errorf("%s: connection closed, expected %s", p.Name, action)
+		}
+		if message.Action != action {
+			return message, fmt.Errorf("%s: expected %s, got %s %s", p.Name, action, message.Action, message.Payload)
+		}
+		if action == game.UserJoinedAction {
+			p.Id = p.User().Id
+		}
+		return message, nil
+	case <-time.After(ExpectTimeout):
+		return Received{}, fmt.Errorf("%s: timeout waiting for %s", p.Name, action)
+	}
+}
+
+// ExpectSequence expects messages with actions in order.
+func (p *Player) ExpectSequence(actions ...string) error {
+	for _, action := range actions {
+		if _, err := p.Expect(action); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExpectError expects an error message with code.
+func (p *Player) ExpectError(code int) (game.ErrorMessage, error) {
+	message, err := p.Expect(game.Error)
+	if err != nil {
+		return game.ErrorMessage{}, err
+	}
+	serverError := message.ServerError()
+	if serverError == nil {
+		return game.ErrorMessage{}, fmt.Errorf("%s: cannot decode error %s: %v", p.Name, message.Payload, message.DecodeErr)
+	}
+	payload := game.ErrorMessage(*serverError)
+	if payload.Code != code {
+		return payload, fmt.Errorf("%s: expected error %d, got %d %q", p.Name, code, payload.Code, payload.Message)
+	}
+	return payload, nil
+}
+
+// ExpectSilence fails if a message is received within d.
+func (p *Player) ExpectSilence(d time.Duration) error {
+	select {
+	case message, ok := <-p.Events():
+		if !ok {
+			return errors.New(p.Name + ": connection closed")
+		}
+		return fmt.Errorf("%s: unexpected %s %s", p.Name, message.Action, message.Payload)
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// Close closes connection of the player.
+func (p *Player) Close() {
+	_ = p.Client.Close()
+}
@@ -0,0 +1,136 @@
+package e2e
+
+import (
+	"GameService/consts/plan_types"
+	"GameService/game"
+	"GameService/repository/models"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+// scenario is a scripted game played on a new Harness.
+type scenario struct {
+	name string
+	run  func(h *Harness) error
+}
+
+// scenarios are all scenarios in the order they are run by TestScenarios. They
+// cover flows across packages over WebSocket; behavior of a single package, like
+// permissions of roles or name rules, is tested next to the package.
+var scenarios = []scenario{
+	{name: "full-game", run: fullGame},
+	{name: "creator-ends-game", run: creatorEndsGame},
+	{name: "player-cannot-end-game", run: playerCannotEndGame},
+	{name: "creator-leaves-game", run: creatorLeavesGame},
+	{name: "kick-player", run: kickPlayer},
+	{name: "questions-upstream-down", run: questionsUpstreamDown},
+	{name: "slow-upstream-deadline", run: slowUpstreamDeadline},
+	{name: "malformed-user", run: malformedUser},
+	{name: "bots-disabled", run: botsDisabled},
+	{name: "bot-fills-game", run: botFillsGame},
+	{name: "rate-limit-escalation", run: rateLimitEscalation},
+	{name: "connections-per-ip", run: connectionsPerIP},
+	{name: "forwarded-for", run: forwardedFor},
+	{name: "ticket-connect", run: ticketConnect},
+	{name: "subprotocol-token", run: subprotocolToken},
+	{name: "rejected-upgrades", run: rejectedUpgrades},
+	{name: "jwks-rotation", run: jwksRotation},
+	{name: "guest-reconnect", run: guestReconnect},
+	{name: "guest-names", run: guestNames},
+	{name: "co-host", run: coHost},
+	{name: "admin-moderates", run: adminModerates},
+	{name: "spectators", run: spectators},
+	{name: "invite-only", run: inviteOnly},
+	{name: "passcode-and-join-code", run: passcodeAndJoinCode},
+	{name: "waiting-room", run: waitingRoom},
+}
+
+// lobby is a game with a host and guests who joined it.
+type lobby struct {
+	game    models.Game
+	topic   models.Topic
+	host    *Player
+	players []*Player
+}
+
+// all returns the host and guests.
+func (l *lobby) all() []*Player {
+	return append([]*Player{l.host}, l.players...)
+}
+
+// newLobby creates a game of a basic plan host, connects guests and joins them one by one.
+func newLobby(h *Harness, guests ...string) (*lobby, error) {
+	user, gameInfo := h.NewGame(plan_types.Basic)
+	l := &lobby{game: gameInfo, topic: h.Repository.AddTopic("Teamwork", 10)}
+	var err error
+	if l.host, _, err = h.HostGame(user, gameInfo); err != nil {
+		return nil, err
+	}
+	for _, name := range guests {
+		player, err := h.ConnectGuest(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := join(player, l.game.Id); err != nil {
+			return nil, err
+		}
+		for _, joined := range l.all() {
+			if _, err := joined.Expect(game.JoinGameAction); err != nil {
+				return nil, err
+			}
+		}
+		l.players = append(l.players, player)
+	}
+	return l, nil
+}
+
+func join(player *Player, gameId uuid.UUID) error {
+	if err := player.Send(game.JoinGameAction, gameId, nil); err != nil {
+		return err
+	}
+	_, err := player.Expect(game.UserJoinedAction)
+	return err
+}
+
+// start selects random topics and starts the game.
+func (l *lobby) start() error {
+	if err := l.host.Send(game.SelectTopicAction, l.game.Id, nil); err != nil {
+		return err
+	}
+	if _, err := l.host.Expect(game.SelectTopicAction); err != nil {
+		return err
+	}
+	if err := l.host.Send(game.StartGameAction, l.game.Id, nil); err != nil {
+		return err
+	}
+	return expectAll(l.all(), game.StartGameAction)
+}
+
+func expectAll(players []*Player, actions ...string) error {
+	for _, player := range players {
+		if err := player.ExpectSequence(actions...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func expectStatus(h *Harness, gameId uuid.UUID, status string) error {
+	stored, _ := h.Repository.Game(gameId)
+	if stored.Status != status {
+		return fmt.Errorf("game status is %q, expected %q", stored.Status, status)
+	}
+	return nil
+}
+
+// expectDenied expects an error of the missing permission.
+func expectDenied(player *Player, permission game.Permission) error {
+	denied, err := player.ExpectError(8)
+	if err != nil {
+		return err
+	}
+	if denied.Reason != game.PermissionDenied || denied.Permission != string(permission) {
+		return fmt.Errorf("%s: expected denied %s, got %s %q", player.Name, permission, denied.Reason, denied.Permission)
+	}
+	return nil
+}
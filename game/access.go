@@ -0,0 +1,233 @@
+package game
+
+import (
+	"GameService/consts/game_status"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Actions of the waiting room of a game, see models.GameAccess.WaitingRoom.
+const (
+	// WaitingRoomAction is sent to a guest waiting for a host to admit them.
+	WaitingRoomAction = "waiting-room"
+	// JoinRequestAction is sent to hosts for every waiting guest. Payload is the guest.
+	JoinRequestAction = "join-request"
+	// AdmitGuestAction admits or denies a waiting guest. Payload is admission.
+	AdmitGuestAction = "admit-guest"
+)
+
+// Reasons of errors of join-game rejected by the access policy of the game.
+const (
+	NotInvited      = "not-invited"
+	InvalidPasscode = "invalid-passcode"
+	JoinDenied      = "join-denied"
+)
+
+// joinCodeAlphabet has no characters which are easy to confuse, like 0 and O or 1 and I.
+const joinCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// JoinCodeLength is the number of characters of join codes.
+const JoinCodeLength = 6
+
+// admission is the payload of admit-guest.
+type admission struct {
+	UserId uuid.UUID `json:"user_id"`
+	Admit  bool      `json:"admit"`
+}
+
+// waitingGuest is a guest in the waiting room of a game.
+type waitingGuest struct {
+	client    *Client
+	spectator bool
+}
+
+// newJoinCode returns a random join code.
+func newJoinCode() (string, error) {
+	code := make([]byte, JoinCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(joinCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = joinCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// normalizeJoinCode drops separators and spaces of a typed code and upper-cases it.
+func normalizeJoinCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(code))
+}
+
+// findGameByCode returns the loaded game with the join code, nil when it is unknown or the game ended.
+func (server *WsServer) findGameByCode(code string) *Game {
+	server.mutex.RLock()
+	game := server.joinCodes[normalizeJoinCode(code)]
+	server.mutex.RUnlock()
+	if game == nil || game.getStatus() == game_status.GameEnded {
+		return nil
+	}
+	return game
+}
+
+// invites reports whether the user is invited by ID or by the domain of their email.
+func (game *Game) invites(user *User) bool {
+	if !user.Authorized {
+		return false
+	}
+	for _, id := range game.access.InvitedUsers {
+		if id == user.Id {
+			return true
+		}
+	}
+	_, domain, ok := strings.Cut(user.Email, "@")
+	if !ok {
+		return false
+	}
+	for _, invited := range game.access.InvitedDomains {
+		if strings.EqualFold(domain, strings.TrimPrefix(invited, "@")) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAccess reports whether the client may join the game now. Otherwise the
+// client is notified about the rejection or is moved to the waiting room.
+// Creators, admins, players of the game and guests admitted before are let in.
+func (game *Game) checkAccess(client *Client, request joinPayload) bool {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	user := client.User
+	if user.Admin || user.Bot || user.Id == game.Creator || game.hasPlayer(user.Id) || game.admitted[user.Id] {
+		return true
+	}
+
+	if !game.invites(user) {
+		access := game.access
+		switch {
+		case access.Passcode != "":
+			if subtle.ConstantTimeCompare([]byte(request.Passcode), []byte(access.Passcode)) != 1 {
+				message := "passcode is invalid"
+				if request.Passcode == "" {
+					message = "passcode is required"
+				}
+				client.notifyClient(newAccessErrorMessage(game.ID, InvalidPasscode, message))
+				return false
+			}
+		case len(access.InvitedUsers) > 0 || len(access.InvitedDomains) > 0:
+			client.notifyClient(newAccessErrorMessage(game.ID, NotInvited, "the game is invite-only"))
+			return false
+		}
+	}
+
+	if !user.Authorized && game.access.WaitingRoom {
+		game.waiting[user.Id] = waitingGuest{client: client, spectator: request.Spectator}
+		client.notifyClient(NewMessage(WaitingRoomAction, nil, game.ID, nil, time.Now()))
+		for host := range game.Clients {
+			game.notifyJoinRequests(host, user)
+		}
+		return false
+	}
+	return true
+}
+
+// notifyJoinRequests sends join-request for the waiting guests, or for one of
+// them, to the client when its role permits admitting guests. game.mutex must be held.
+func (game *Game) notifyJoinRequests(client *Client, guests ...*User) {
+	if role, ok := game.role(client.User); !ok || !role.Can(PermissionAdmitGuests) {
+		return
+	}
+	if len(guests) == 0 {
+		for _, waiting := range game.waiting {
+			guests = append(guests, waiting.client.User)
+		}
+	}
+	for _, guest := range guests {
+		client.notifyClient(NewMessage(JoinRequestAction, guest, game.ID, nil, time.Now()))
+	}
+}
+
+// leaveWaitingRoom removes the disconnected client from the waiting room of the game.
+func (game *Game) leaveWaitingRoom(client *Client) {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	if guest, ok := game.waiting[client.User.Id]; ok && guest.client == client {
+		delete(game.waiting, client.User.Id)
+	}
+}
+
+func newAccessErrorMessage(gameId uuid.UUID, reason string, message string) *Message {
+	return NewMessage(Error, ErrorMessage{
+		Code:    17,
+		Reason:  reason,
+		Message: message,
+	}, gameId, nil, time.Now())
+}
+
+// handleAdmitGuestMessage lets a waiting guest join the game or denies joining.
+func (client *Client) handleAdmitGuestMessage(ctx context.Context, message Message) {
+	game := client.wsServer.findGame(ctx, message.Target)
+	if game == nil {
+		client.notifyClient(NewMessage(Error, ErrorMessage{
+			Code:    2,
+			Message: fmt.Sprintf("game %s is not found", message.Target),
+		}, message.Target, nil, time.Now()))
+		return
+	}
+	if !game.authorize(client, message.Action) {
+		return
+	}
+
+	var request admission
+	payload, err := json.Marshal(message.Payload)
+	if err == nil {
+		err = json.Unmarshal(payload, &request)
+	}
+	if err != nil {
+		client.notifyClient(NewMessage(Error, ErrorMessage{
+			Code:    9,
+			Message: "incorrect payload",
+		}, game.ID, nil, time.Now()))
+		return
+	}
+
+	game.mutex.Lock()
+	guest, ok := game.waiting[request.UserId]
+	delete(game.waiting, request.UserId)
+	// A guest who disconnected while being admitted is not waiting anymore.
+	ok = ok && guest.client.ctx.Err() == nil
+	if ok && request.Admit {
+		game.admitted[request.UserId] = true
+	}
+	game.mutex.Unlock()
+	if !ok {
+		client.notifyClient(NewMessage(Error, ErrorMessage{
+			Code:    9,
+			Message: fmt.Sprintf("user %s is not waiting", request.UserId),
+		}, game.ID, nil, time.Now()))
+		return
+	}
+
+	client.logger().WithField("guest_id", request.UserId).WithField("admit", request.Admit).Info("guest admission")
+	switch {
+	case !request.Admit:
+		guest.client.notifyClient(newAccessErrorMessage(game.ID, JoinDenied, "a host denied joining the game"))
+	case guest.spectator:
+		game.spectate <- guest.client
+	default:
+		game.register <- guest.client
+	}
+}
@@ -0,0 +1,277 @@
+package game
+
+import (
+	"GameService/consts/game_status"
+	"GameService/logging"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	PhaseLobby  = "lobby"
+	PhaseRound  = "round"
+	PhasePause  = "between-rounds"
+	PhaseEnded  = "ended"
+	adminPrefix = "/admin/"
+)
+
+// AdminHandler serves HTTP API for operators to inspect and control live games.
+type AdminHandler struct {
+	server *WsServer
+	apiKey string
+}
+
+// NewAdminHandler creates a new AdminHandler. Requests are authorized with apiKey
+// in X-API-Key header or with a user token with admin access in Authorization header.
+func NewAdminHandler(server *WsServer, apiKey string) *AdminHandler {
+	return &AdminHandler{server: server, apiKey: apiKey}
+}
+
+type gameSummary struct {
+	Id      uuid.UUID `json:"id"`
+	Name    string    `json:"name"`
+	Status  string    `json:"status"`
+	Phase   string    `json:"phase"`
+	Creator uuid.UUID `json:"creator_id"`
+	MaxSize int       `json:"max_size"`
+	Players []*User   `json:"players"`
+	Clients int       `json:"clients"`
+}
+
+type clientState struct {
+	Id   uuid.UUID `json:"id"`
+	User *User     `json:"user"`
+}
+
+type gameState struct {
+	*Game
+	Phase   string               `json:"phase"`
+	Clients []clientState        `json:"clients"`
+	Results map[uuid.UUID]*Rates `json:"results"`
+}
+
+type kickRequest struct {
+	UserId uuid.UUID `json:"user_id"`
+}
+
+type announcementRequest struct {
+	Message string    `json:"message"`
+	GameId  uuid.UUID `json:"game_id"`
+}
+
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch h.authorize(r) {
+	case http.StatusUnauthorized:
+		writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	case http.StatusForbidden:
+		writeJSONError(w, http.StatusForbidden, "admin access required")
+		return
+	}
+
+	ctx := logging.WithRequestID(r.Context(), r.Header.Get(logging.RequestIDHeader))
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, adminPrefix), "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "games" && r.Method == http.MethodGet:
+		h.listGames(w)
+	case len(parts) == 1 && parts[0] == "announcements" && r.Method == http.MethodPost:
+		h.announce(ctx, w, r)
+	case len(parts) == 2 && parts[0] == "cache" && parts[1] == "purge" && r.Method == http.MethodPost:
+		h.server.service.Purge()
+		logging.FromContext(ctx).Info("cache purged by admin")
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 3 && parts[0] == "cache" && parts[1] == "topics" && r.Method == http.MethodDelete:
+		topicId, err := uuid.Parse(parts[2])
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "incorrect topic id")
+			return
+		}
+		h.server.service.InvalidateTopic(topicId)
+		logging.FromContext(ctx).WithField("topic_id", topicId).Info("cached topic dropped by admin")
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) >= 2 && parts[0] == "games":
+		gameId, err := uuid.Parse(parts[1])
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "incorrect game id")
+			return
+		}
+		game := h.server.findGameByID(gameId)
+		if game == nil {
+			writeJSONError(w, http.StatusNotFound, "game is not found")
+			return
+		}
+		switch {
+		case len(parts) == 2 && r.Method == http.MethodGet:
+			h.showGame(w, game)
+		case len(parts) == 3 && parts[2] == "end" && r.Method == http.MethodPost:
+			h.endGame(ctx, w, game, false)
+		case len(parts) == 3 && parts[2] == "abort" && r.Method == http.MethodPost:
+			h.endGame(ctx, w, game, true)
+		case len(parts) == 3 && parts[2] == "kick" && r.Method == http.MethodPost:
+			h.kick(ctx, w, r, game)
+		default:
+			writeJSONError(w, http.StatusNotFound, "not found")
+		}
+	default:
+		writeJSONError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// authorize returns http.StatusOK for an authorized request, http.StatusUnauthorized
+// for missing or invalid credentials and http.StatusForbidden for a user token without admin access.
+func (h *AdminHandler) authorize(r *http.Request) int {
+	if key := r.Header.Get("X-API-Key"); h.apiKey != "" && key != "" {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(h.apiKey)) != 1 {
+			return http.StatusUnauthorized
+		}
+		return http.StatusOK
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return http.StatusUnauthorized
+	}
+	_, access, err := h.server.service.ParseToken(token)
+	switch {
+	case err != nil:
+		return http.StatusUnauthorized
+	case access != "admin":
+		return http.StatusForbidden
+	}
+	return http.StatusOK
+}
+
+func (h *AdminHandler) listGames(w http.ResponseWriter) {
+	h.server.mutex.RLock()
+	games := make([]*Game, 0, len(h.server.games))
+	for game := range h.server.games {
+		games = append(games, game)
+	}
+	h.server.mutex.RUnlock()
+
+	summaries := make([]gameSummary, 0, len(games))
+	for _, game := range games {
+		game.mutex.Lock()
+		summaries = append(summaries, gameSummary{
+			Id:      game.ID,
+			Name:    game.Name,
+			Status:  game.Status,
+			Phase:   game.phase(),
+			Creator: game.Creator,
+			MaxSize: game.MaxSize,
+			Players: append([]*User(nil), game.Users...),
+			Clients: len(game.Clients),
+		})
+		game.mutex.Unlock()
+	}
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+func (h *AdminHandler) showGame(w http.ResponseWriter, game *Game) {
+	game.mutex.Lock()
+	state, err := json.Marshal(gameState{
+		Game:    game,
+		Phase:   game.phase(),
+		Clients: game.clientStates(),
+		Results: game.Results,
+	})
+	game.mutex.Unlock()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "failed to encode game")
+		return
+	}
+	writeJSON(w, http.StatusOK, json.RawMessage(state))
+}
+
+// clientStates lists connected clients. Caller must hold game.mutex.
+func (game *Game) clientStates() []clientState {
+	clients := make([]clientState, 0, len(game.Clients))
+	for client := range game.Clients {
+		clients = append(clients, clientState{Id: client.ID, User: client.User})
+	}
+	return clients
+}
+
+func (h *AdminHandler) endGame(ctx context.Context, w http.ResponseWriter, game *Game, abort bool) {
+	status := game.getStatus()
+	logger := logging.FromContext(ctx).WithField(logging.GameIdField, game.ID)
+	// finishGame and abortGame end the game only once, so of concurrent
+	// requests only the first one succeeds.
+	switch {
+	case (abort || status == game_status.GameNotStarted) && game.abortGame(ctx, h.server.service, uuid.Nil):
+		logger.Warn("game aborted by admin")
+	case !abort && status != game_status.GameNotStarted && game.finishGame(ctx, h.server.service):
+		logger.Warn("game ended by admin")
+	default:
+		writeJSONError(w, http.StatusConflict, "game is already ended")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) kick(ctx context.Context, w http.ResponseWriter, r *http.Request, game *Game) {
+	var request kickRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.UserId == uuid.Nil {
+		writeJSONError(w, http.StatusBadRequest, "incorrect body")
+		return
+	}
+	if !game.kickUser(request.UserId, nil) {
+		writeJSONError(w, http.StatusNotFound, "user is not connected to the game")
+		return
+	}
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		logging.GameIdField: game.ID,
+		logging.UserIdField: request.UserId,
+	}).Warn("user kicked by admin")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) announce(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var request announcementRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Message == "" {
+		writeJSONError(w, http.StatusBadRequest, "incorrect body")
+		return
+	}
+	message := NewMessage(AnnouncementAction, request.Message, request.GameId, nil, time.Now())
+	if request.GameId == uuid.Nil {
+		h.server.broadcastToClients(message.encode())
+	} else {
+		game := h.server.findGameByID(request.GameId)
+		if game == nil {
+			writeJSONError(w, http.StatusNotFound, "game is not found")
+			return
+		}
+		game.broadcast <- message
+	}
+	logging.FromContext(ctx).WithField(logging.GameIdField, request.GameId).Info("announcement sent by admin")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// phase describes what is going on in the game. Caller must hold game.mutex.
+func (game *Game) phase() string {
+	switch {
+	case game.Status == game_status.GameEnded:
+		return PhaseEnded
+	case game.Status != game_status.GameInProgress:
+		return PhaseLobby
+	case game.Round != nil && len(game.Round.UsersQuestions) > 0:
+		return PhaseRound
+	default:
+		return PhasePause
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeJSONError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, ErrorMessage{Code: code, Message: message})
+}
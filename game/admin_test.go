@@ -0,0 +1,240 @@
+package game
+
+import (
+	"GameService/consts/game_status"
+	"GameService/consts/plan_types"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAdminKey = "admin-key"
+
+// serveAdmin sends the request to the admin API authorized with the admin key.
+func serveAdmin(handler http.Handler, method string, path string, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("X-API-Key", testAdminKey)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func expectStatus(t *testing.T, recorder *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if recorder.Code != status {
+		t.Fatalf("status is %d, expected %d: %s", recorder.Code, status, recorder.Body)
+	}
+}
+
+func TestAdminAuthorization(t *testing.T) {
+	server, repository := newTestServer(testSettings())
+
+	tests := []struct {
+		name          string
+		apiKey        string
+		key           string
+		authorization string
+		status        int
+	}{
+		{name: "api key", apiKey: testAdminKey, key: testAdminKey, status: http.StatusOK},
+		{name: "incorrect api key", apiKey: testAdminKey, key: "wrong", status: http.StatusUnauthorized},
+		{name: "api key disabled", key: testAdminKey, status: http.StatusUnauthorized},
+		{name: "no credentials", apiKey: testAdminKey, status: http.StatusUnauthorized},
+		{name: "admin token", apiKey: testAdminKey, authorization: "Bearer " + repository.Token(uuid.New(), "admin"), status: http.StatusOK},
+		{name: "user token", apiKey: testAdminKey, authorization: "Bearer " + repository.Token(uuid.New(), "user"), status: http.StatusForbidden},
+		{name: "invalid token", apiKey: testAdminKey, authorization: "Bearer invalid", status: http.StatusUnauthorized},
+		{name: "not bearer", apiKey: testAdminKey, authorization: repository.Token(uuid.New(), "admin"), status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/admin/games", nil)
+			if tt.key != "" {
+				request.Header.Set("X-API-Key", tt.key)
+			}
+			if tt.authorization != "" {
+				request.Header.Set("Authorization", tt.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			NewAdminHandler(server, tt.apiKey).ServeHTTP(recorder, request)
+			expectStatus(t, recorder, tt.status)
+		})
+	}
+}
+
+func TestAdminShowsGames(t *testing.T) {
+	server, repository := newTestServer(testSettings())
+	handler := NewAdminHandler(server, testAdminKey)
+	game := newTestGame(t, server, repository, plan_types.Basic)
+	player := seatPlayers(server, game, 1)[0]
+
+	recorder := serveAdmin(handler, http.MethodGet, "/admin/games", "")
+	expectStatus(t, recorder, http.StatusOK)
+	var summaries []gameSummary
+	if err := json.Unmarshal(recorder.Body.Bytes(), &summaries); err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 || summaries[0].Id != game.ID || summaries[0].Phase != PhaseLobby ||
+		summaries[0].Clients != 1 || len(summaries[0].Players) != 1 {
+		t.Fatalf("games are %+v", summaries)
+	}
+
+	recorder = serveAdmin(handler, http.MethodGet, "/admin/games/"+game.ID.String(), "")
+	expectStatus(t, recorder, http.StatusOK)
+	var state struct {
+		Id      uuid.UUID     `json:"id"`
+		Phase   string        `json:"phase"`
+		Clients []clientState `json:"clients"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &state); err != nil {
+		t.Fatal(err)
+	}
+	if state.Id != game.ID || state.Phase != PhaseLobby || len(state.Clients) != 1 || state.Clients[0].User.Id != player.User.Id {
+		t.Fatalf("game is %+v", state)
+	}
+
+	expectStatus(t, serveAdmin(handler, http.MethodGet, "/admin/games/"+uuid.NewString(), ""), http.StatusNotFound)
+	expectStatus(t, serveAdmin(handler, http.MethodGet, "/admin/games/1", ""), http.StatusBadRequest)
+	expectStatus(t, serveAdmin(handler, http.MethodGet, "/admin/unknown", ""), http.StatusNotFound)
+}
+
+func TestAdminEndsGame(t *testing.T) {
+	server, repository := newTestServer(testSettings())
+	handler := NewAdminHandler(server, testAdminKey)
+
+	tests := []struct {
+		name    string
+		action  string
+		started bool
+		saved   bool
+		payload string
+	}{
+		{name: "end started game", action: "end", started: true, saved: true, payload: GameEndedAction},
+		{name: "end game in lobby", action: "end", payload: GameAbortedAction},
+		{name: "abort started game", action: "abort", started: true, payload: GameAbortedAction},
+		{name: "abort game in lobby", action: "abort", payload: GameAbortedAction},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			game := newTestGame(t, server, repository, plan_types.Basic)
+			player := seatPlayers(server, game, 1)[0]
+			if tt.started {
+				game.mutex.Lock()
+				game.Status = game_status.GameInProgress
+				game.mutex.Unlock()
+			}
+
+			path := "/admin/games/" + game.ID.String() + "/" + tt.action
+			expectStatus(t, serveAdmin(handler, http.MethodPost, path, ""), http.StatusNoContent)
+			if message := receive(t, player); message.Action != tt.payload {
+				t.Fatalf("received %s, expected %s", message.Action, tt.payload)
+			}
+			if game.getStatus() != game_status.GameEnded {
+				t.Fatalf("game status is %s", game.getStatus())
+			}
+			if _, saved := repository.Results(game.ID); saved != tt.saved {
+				t.Fatalf("results saved: %v", saved)
+			}
+
+			// The game is ended once, also when it is ended and aborted again.
+			expectStatus(t, serveAdmin(handler, http.MethodPost, path, ""), http.StatusConflict)
+			expectStatus(t, serveAdmin(handler, http.MethodPost, "/admin/games/"+game.ID.String()+"/abort", ""), http.StatusConflict)
+			expectStatus(t, serveAdmin(handler, http.MethodPost, "/admin/games/"+game.ID.String()+"/end", ""), http.StatusConflict)
+			expectNothing(t, player, 50*time.Millisecond)
+		})
+	}
+}
+
+func TestAdminKicksUser(t *testing.T) {
+	server, repository := newTestServer(testSettings())
+	handler := NewAdminHandler(server, testAdminKey)
+	game := newTestGame(t, server, repository, plan_types.Basic)
+	players := seatPlayers(server, game, 2)
+	path := "/admin/games/" + game.ID.String() + "/kick"
+
+	expectStatus(t, serveAdmin(handler, http.MethodPost, path, `{"user_id":"`+players[0].User.Id.String()+`"}`), http.StatusNoContent)
+	if message := receive(t, players[0]); message.Action != UserDeletedAction {
+		t.Fatalf("kicked user received %s", message.Action)
+	}
+	if message := receive(t, players[1]); message.Action != UserDeletedAction {
+		t.Fatalf("player received %s", message.Action)
+	}
+	eventually(t, func() bool {
+		game.mutex.Lock()
+		defer game.mutex.Unlock()
+		return !game.Clients[players[0]]
+	}, "kicked user is connected to the game")
+
+	expectStatus(t, serveAdmin(handler, http.MethodPost, path, `{"user_id":"`+uuid.NewString()+`"}`), http.StatusNotFound)
+	expectStatus(t, serveAdmin(handler, http.MethodPost, path, `{}`), http.StatusBadRequest)
+}
+
+func TestAdminAnnounces(t *testing.T) {
+	server, repository := newTestServer(testSettings())
+	handler := NewAdminHandler(server, testAdminKey)
+	game := newTestGame(t, server, repository, plan_types.Basic)
+	player := seatPlayers(server, game, 1)[0]
+	connected := newTestClient(server, User{Name: "Connected"})
+	server.register <- connected
+
+	body := `{"message":"maintenance","game_id":"` + game.ID.String() + `"}`
+	expectStatus(t, serveAdmin(handler, http.MethodPost, "/admin/announcements", body), http.StatusNoContent)
+	if message := receive(t, player); message.Action != AnnouncementAction || string(message.Payload) != `"maintenance"` {
+		t.Fatalf("player received %s %s", message.Action, message.Payload)
+	}
+	expectNothing(t, connected, 50*time.Millisecond)
+
+	expectStatus(t, serveAdmin(handler, http.MethodPost, "/admin/announcements", `{"message":"restart"}`), http.StatusNoContent)
+	if message := receive(t, connected); message.Action != AnnouncementAction || string(message.Payload) != `"restart"` {
+		t.Fatalf("connected client received %s %s", message.Action, message.Payload)
+	}
+
+	expectStatus(t, serveAdmin(handler, http.MethodPost, "/admin/announcements",
+		`{"message":"lost","game_id":"`+uuid.NewString()+`"}`), http.StatusNotFound)
+	expectStatus(t, serveAdmin(handler, http.MethodPost, "/admin/announcements", `{"message":""}`), http.StatusBadRequest)
+}
+
+func TestAdminDropsCache(t *testing.T) {
+	server, repository := newCachedTestServer(testSettings())
+	handler := NewAdminHandler(server, testAdminKey)
+	topic := repository.AddTopic("Go", 1)
+	user := repository.AddUser("Ann", plan_types.Basic)
+	ctx := context.Background()
+	load := func() {
+		t.Helper()
+		if _, err := server.service.GetTopic(ctx, topic.Id); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := server.service.GetUserById(ctx, user.Id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	load()
+	load()
+	if repository.Calls("GetTopic") != 1 || repository.Calls("GetUserById") != 1 {
+		t.Fatalf("topic loaded %d times, user %d times", repository.Calls("GetTopic"), repository.Calls("GetUserById"))
+	}
+
+	expectStatus(t, serveAdmin(handler, http.MethodDelete, "/admin/cache/topics/"+topic.Id.String(), ""), http.StatusNoContent)
+	load()
+	if repository.Calls("GetTopic") != 2 || repository.Calls("GetUserById") != 1 {
+		t.Fatalf("topic loaded %d times, user %d times after the topic was dropped",
+			repository.Calls("GetTopic"), repository.Calls("GetUserById"))
+	}
+
+	expectStatus(t, serveAdmin(handler, http.MethodPost, "/admin/cache/purge", ""), http.StatusNoContent)
+	load()
+	if repository.Calls("GetTopic") != 3 || repository.Calls("GetUserById") != 2 {
+		t.Fatalf("topic loaded %d times, user %d times after the cache was purged",
+			repository.Calls("GetTopic"), repository.Calls("GetUserById"))
+	}
+
+	expectStatus(t, serveAdmin(handler, http.MethodDelete, "/admin/cache/topics/1", ""), http.StatusBadRequest)
+}
@@ -0,0 +1,218 @@
+package game
+
+import (
+	"GameService/consts/game_status"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// AddBotAction adds a bot player to the game in lobby. Payload is an optional name of the bot.
+const AddBotAction = "add-bot"
+
+// BotsFlag is the feature flag enabling bot players.
+const BotsFlag = "bots"
+
+// botMessage is a message received by a bot.
+type botMessage struct {
+	Action  string          `json:"action"`
+	Payload json.RawMessage `json:"payload"`
+	Target  uuid.UUID       `json:"target"`
+}
+
+// newBot creates a client without connection played by the server.
+func newBot(wsServer *WsServer, name string) *Client {
+	return newClient(nil, wsServer, User{
+		Id:   uuid.New(),
+		Name: name,
+		Bot:  true,
+	})
+}
+
+func (client *Client) handleAddBotMessage(ctx context.Context, message Message) {
+	game := client.wsServer.findGame(ctx, message.Target)
+	if game == nil {
+		client.notifyClient(NewMessage(Error, ErrorMessage{
+			Code:    2,
+			Message: fmt.Sprintf("game %s is not found", message.Target),
+		}, message.Target, nil, time.Now()))
+		return
+	}
+	if !game.authorize(client, message.Action) {
+		return
+	}
+	settings := client.wsServer.getSettings()
+	if !settings.Features[BotsFlag] {
+		client.notifyClient(NewMessage(Error, ErrorMessage{
+			Code:    14,
+			Message: "bots are disabled",
+		}, game.ID, nil, time.Now()))
+		return
+	}
+
+	game.mutex.Lock()
+	status, players, bots := game.Status, len(game.Users), 0
+	for _, user := range game.Users {
+		if user.Bot {
+			bots++
+		}
+	}
+	game.mutex.Unlock()
+	if status != game_status.GameNotStarted {
+		client.notifyClient(NewMessage(Error, ErrorMessage{
+			Code:    5,
+			Message: "game is in progress or ended",
+		}, game.ID, nil, time.Now()))
+		return
+	}
+	if bots >= settings.Bots.MaxPerGame {
+		client.notifyClient(NewMessage(Error, ErrorMessage{
+			Code:    14,
+			Message: fmt.Sprintf("at most %d bots can play", settings.Bots.MaxPerGame),
+		}, game.ID, nil, time.Now()))
+		return
+	}
+	if players >= game.MaxSize {
+		client.notifyClient(NewMessage(Error, ErrorMessage{
+			Code:    1,
+			Message: "max number of participants",
+		}, game.ID, nil, time.Now()))
+		return
+	}
+
+	name, _ := message.Payload.(string)
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = fmt.Sprintf("Bot %d", bots+1)
+	}
+	name, err := normalizeName(name, settings.Guests)
+	if err != nil {
+		client.notifyClient(NewMessage(Error, ErrorMessage{
+			Code:    16,
+			Reason:  InvalidName,
+			Message: err.Error(),
+		}, game.ID, nil, time.Now()))
+		return
+	}
+	bot := newBot(client.wsServer, name)
+	client.logger().WithField("bot_id", bot.User.Id).Info("bot added")
+	go bot.playBot(game, settings)
+	game.register <- bot
+}
+
+// playBot reacts to messages of the game until the bot is deleted from the game
+// or the game ends. The bot answers its questions and rates answers of other
+// players; when the game ends it leaves the game.
+func (client *Client) playBot(game *Game, settings Settings) {
+	defer client.cancel()
+	var stage *UserQuestion
+	for {
+		select {
+		case data := <-client.send:
+			var message botMessage
+			if err := json.Unmarshal(data, &message); err != nil {
+				continue
+			}
+			switch message.Action {
+			case StartStageAction:
+				stage = &UserQuestion{}
+				if err := json.Unmarshal(message.Payload, stage); err != nil {
+					stage = nil
+					continue
+				}
+				if stage.User.Id == client.User.Id {
+					go client.botAct(game, settings.Bots.AnswerDelay, UserStartAnswerAction, UserEndAnswerAction)
+				}
+			case UserEndAnswerAction:
+				if stage == nil || stage.User.Id == client.User.Id {
+					continue
+				}
+				go client.botRate(game, settings.Bots.RateDelay, botRating(stage))
+			case UserDeletedAction:
+				var userId uuid.UUID
+				if json.Unmarshal(message.Payload, &userId) == nil && userId == client.User.Id {
+					return
+				}
+			}
+		case <-client.ctx.Done():
+			client.botLeave(game)
+			return
+		case <-game.ctx.Done():
+			client.botLeave(game)
+			return
+		}
+	}
+}
+
+// botLeave unregisters the bot from the game. Messages sent to the bot meanwhile
+// are dropped, so the game never blocks on the buffer of a bot which stopped playing.
+func (client *Client) botLeave(game *Game) {
+	for {
+		select {
+		case game.unregister <- client:
+			return
+		case <-client.send:
+		}
+	}
+}
+
+// botAct sends actions as the bot one by one, each after a random delay around delay.
+func (client *Client) botAct(game *Game, delay time.Duration, actions ...string) {
+	for _, action := range actions {
+		if !client.botWait(game, delay) {
+			return
+		}
+		client.botSend(game, action, nil)
+	}
+}
+
+// botRate rates the answer as the bot after a random delay around delay.
+func (client *Client) botRate(game *Game, delay time.Duration, rate ratePayload) {
+	if client.botWait(game, delay) {
+		client.botSend(game, RateAction, rate)
+	}
+}
+
+// botWait waits a random time between a half and one and a half of delay and
+// reports whether the bot still plays.
+func (client *Client) botWait(game *Game, delay time.Duration) bool {
+	timer := time.NewTimer(delay/2 + time.Duration(rand.Int63n(int64(delay)+1)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-game.ctx.Done():
+		return false
+	case <-client.ctx.Done():
+		return false
+	}
+}
+
+// botSend handles action as if the bot sent it. The message goes through JSON,
+// so handlers get the payload in the same shape as from a connection.
+func (client *Client) botSend(game *Game, action string, payload interface{}) {
+	data, err := json.Marshal(Message{Action: action, Target: game.ID, Payload: payload})
+	if err != nil {
+		return
+	}
+	var message Message
+	if err := json.Unmarshal(data, &message); err != nil {
+		return
+	}
+	client.handleNewMessage(message)
+}
+
+// botRating returns a plausible rate of the answer: a good value and some tags of the question.
+func botRating(stage *UserQuestion) ratePayload {
+	tags := make([]uuid.UUID, 0, len(stage.Question.Tags))
+	for _, tag := range stage.Question.Tags {
+		if rand.Intn(2) == 0 {
+			tags = append(tags, tag.Id)
+		}
+	}
+	return ratePayload{Value: 3 + rand.Intn(3), UserId: stage.User.Id, Tags: tags}
+}
@@ -0,0 +1,57 @@
+package game
+
+import (
+	"GameService/consts/plan_types"
+	"testing"
+	"time"
+)
+
+func TestBotLeavesEndedGame(t *testing.T) {
+	server, repository := newTestServer(testSettings())
+	game := newTestGame(t, server, repository, plan_types.Basic)
+
+	bot := newBot(server, "Bot 1")
+	stopped := make(chan struct{})
+	go func() {
+		bot.playBot(game, server.getSettings())
+		close(stopped)
+	}()
+	game.register <- bot
+	eventually(t, func() bool {
+		game.mutex.Lock()
+		defer game.mutex.Unlock()
+		return game.hasPlayer(bot.User.Id)
+	}, "bot has not joined the game")
+
+	game.mutex.Lock()
+	game.endGame()
+	game.mutex.Unlock()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("bot keeps playing after the game ended")
+	}
+	if bot.ctx.Err() == nil {
+		t.Error("context of the bot is not cancelled")
+	}
+	eventually(t, func() bool {
+		game.mutex.Lock()
+		defer game.mutex.Unlock()
+		return !game.Clients[bot]
+	}, "bot has not left the game")
+
+	// Broadcasts of the ended game must not block on the buffer of the stopped bot.
+	broadcasted := make(chan struct{})
+	go func() {
+		for i := 0; i <= 2*server.getSettings().WebSocket.SendBufferSize; i++ {
+			game.broadcast <- NewMessage(SendMessageAction, "bye", game.ID, nil, time.Now())
+		}
+		close(broadcasted)
+	}()
+	select {
+	case <-broadcasted:
+	case <-time.After(time.Second):
+		t.Fatal("broadcast blocks on the stopped bot")
+	}
+}
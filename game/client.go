@@ -1,33 +1,38 @@
 package game
 
 import (
+	"GameService/auth"
 	"GameService/consts/game_status"
-	"GameService/consts/plan_types"
+	"GameService/logging"
+	"GameService/metrics"
 	"GameService/repository/models"
+	"GameService/tracing"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/ledongthuc/goterators"
-	"log"
 	"net/http"
+	"sync/atomic"
 	"time"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
-	ReadBufferSize:  4096,
-	WriteBufferSize: 4096,
-}
-
 type User struct {
 	Id         uuid.UUID `json:"id"`
 	Name       string    `json:"name"`
 	Authorized bool      `json:"-"`
+	// Bot is set for bot players which are excluded from saved results.
+	Bot bool `json:"bot,omitempty"`
+	// Admin is set for users connected with an admin token, see RoleAdmin.
+	Admin bool `json:"-"`
+	// Email of an authorized user matched against invited domains of games.
+	Email string `json:"-"`
 }
 
 type Client struct {
@@ -37,20 +42,63 @@ type Client struct {
 	wsServer *WsServer
 	send     chan []byte
 	User     *User
+	// Number of error messages sent to the client, used to detect failed actions.
+	errorsSent atomic.Int64
+	// ctx is cancelled when the client disconnects to stop actions in progress.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// Remote IP address of the connection, empty for bots.
+	ip      string
+	limiter *limiter
+	// closeMessage is sent by writePump after the client disconnects. It is set
+	// by readPump before the context is cancelled.
+	closeMessage []byte
 }
 
 // newClient creates a new client.
 func newClient(conn *websocket.Conn, wsServer *WsServer, user User) *Client {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Client{
 		ID:       uuid.New(),
 		User:     &user,
 		conn:     conn,
 		wsServer: wsServer,
-		send:     make(chan []byte, 256),
+		send:     make(chan []byte, wsServer.getSettings().WebSocket.SendBufferSize),
+		ctx:      ctx,
+		cancel:   cancel,
+		limiter:  newLimiter(wsServer.getSettings().RateLimit),
 	}
 
 }
 
+// actionContext returns context for handling the message. It is cancelled
+// when the client disconnects, the target game ends or the action deadline expires.
+func (client *Client) actionContext(message Message) (context.Context, context.CancelFunc) {
+	settings := client.wsServer.getSettings().WebSocket
+	timeout, ok := settings.ActionTimeouts[message.Action]
+	if !ok {
+		timeout = settings.ActionTimeout
+	}
+	ctx, cancel := context.WithTimeout(client.ctx, timeout)
+	game := client.wsServer.findGameByID(message.Target)
+	if game == nil {
+		return ctx, cancel
+	}
+	stop := context.AfterFunc(game.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// logger returns a logger with client fields.
+func (client *Client) logger() *logrus.Entry {
+	return logrus.WithFields(logrus.Fields{
+		logging.UserIdField:   client.User.Id,
+		logging.ClientIdField: client.ID,
+	})
+}
+
 func (client *Client) GetName() string {
 	return client.User.Name
 }
@@ -63,70 +111,176 @@ func (client *Client) disconnect() {
 	client.wsServer.unregister <- client
 }
 
-// ServeWs handles websocket requests from Clients requests.
-func ServeWs(wsServer *WsServer, w http.ResponseWriter, r *http.Request) {
+// connectionError is a reason of rejecting a WebSocket connection request.
+type connectionError struct {
+	status  int
+	reason  string
+	message string
+}
 
-	token, ok := r.URL.Query()["token"]
+// authenticate returns the user of the connection request authorized with a ticket,
+// a token in Authorization header or Sec-WebSocket-Protocol, a token in the URL
+// query when enabled, a guest token in GuestTokenHeader or Sec-WebSocket-Protocol,
+// or a guest name. Identity is returned for guests.
+func (server *WsServer) authenticate(ctx context.Context, r *http.Request) (User, *GuestIdentity, *connectionError) {
+	logger := logging.FromContext(ctx).WithField("remote_addr", r.RemoteAddr)
+	query := r.URL.Query()
 
-	var userId uuid.UUID
-	var userName string
-	var client *Client
-	if !ok {
-		name, ok := r.URL.Query()["name"]
+	if ticket := query.Get("ticket"); ticket != "" {
+		issued, ok := server.tickets.redeem(ticket, time.Now())
 		if !ok {
-			logrus.Println("wrong URL query")
-			return
+			logger.Warn("invalid or expired ticket")
+			return User{}, nil, &connectionError{http.StatusUnauthorized, "unauthorized", "invalid or expired ticket"}
 		}
+		user, connErr := server.authorizedUser(ctx, issued.userId)
+		user.Admin = issued.admin
+		return user, nil, connErr
+	}
 
-		if len(name[0]) < 0 {
-			logrus.Println("wrong param 'name'")
-			return
+	token, ok := bearerToken(r)
+	if !ok {
+		token, ok = protocolToken(r)
+	}
+	if !ok && query.Has("token") {
+		if !server.getSettings().WebSocket.QueryToken {
+			logger.Warn("token in URL query is not accepted")
+			return User{}, nil, &connectionError{http.StatusBadRequest, "bad_request",
+				"token in URL is not accepted, use a ticket or Authorization header"}
 		}
-
-		userName = name[0]
-		userId = uuid.New()
-		conn, err := upgrader.Upgrade(w, r, nil)
+		token, ok = query.Get("token"), true
+	}
+	if ok {
+		id, access, err := server.service.ParseToken(token)
 		if err != nil {
-			logrus.Println(fmt.Sprintf("error when upgade: %s", err))
-			return
+			logger.WithError(err).Warn("cannot parse token")
+			return User{}, nil, &connectionError{http.StatusUnauthorized, "unauthorized", "invalid token: " + auth.Reason(err)}
+		}
+		if access != "user" && access != "admin" {
+			logger.WithField(logging.UserIdField, id).Warn("cannot connect to the server: permission denied")
+			return User{}, nil, &connectionError{http.StatusForbidden, "forbidden", "permission denied"}
 		}
+		// Admins join games as moderators, see RoleAdmin.
+		user, connErr := server.authorizedUser(ctx, id)
+		user.Admin = access == "admin"
+		return user, nil, connErr
+	}
 
-		client = newClient(conn, wsServer, User{
-			Id:         userId,
-			Name:       userName,
-			Authorized: false,
-		})
+	if query.Has("guest") {
+		logger.Warn("guest token in URL query is not accepted")
+		return User{}, nil, &connectionError{http.StatusBadRequest, "bad_request",
+			"guest token in URL is not accepted, use " + GuestTokenHeader + " header"}
+	}
 
-	} else if len(token[0]) > 0 {
-		id, access, err := wsServer.service.ParseToken(token[0])
+	var guest *GuestIdentity
+	var err error
+	restoreToken, restore := guestToken(r)
+	switch {
+	case restore:
+		guest, err = server.restoreGuest(restoreToken)
 		if err != nil {
-			logrus.Println(fmt.Sprintf("cannot parse token: %s", err.Error()))
-			return
+			logger.WithError(err).Warn("cannot parse guest token")
+			return User{}, nil, &connectionError{http.StatusUnauthorized, "unauthorized", "invalid guest token: " + auth.Reason(err)}
 		}
+	case query.Has("name"):
+		guest = &GuestIdentity{Name: query.Get("name")}
+	default:
+		logger.Warn("wrong URL query")
+		return User{}, nil, &connectionError{http.StatusBadRequest, "bad_request", "token, ticket or guest name is required"}
+	}
 
-		if access != "user" {
-			logrus.Println("cannot connect to the server: permission denied")
-			return
+	// Names of restored guests are checked again as the rules may have changed.
+	name, err := normalizeName(guest.Name, server.getSettings().Guests)
+	if err != nil {
+		logger.WithError(err).Warn("invalid guest name")
+		return User{}, nil, &connectionError{http.StatusBadRequest, "bad_request", err.Error()}
+	}
+	if guest.Token == "" {
+		if guest, err = server.guestIdentity(name); err != nil {
+			logger.WithError(err).Error("cannot issue guest token")
+			return User{}, nil, &connectionError{http.StatusInternalServerError, "guest_token", "cannot issue guest token"}
 		}
+	}
+	guest.Name = name
+	return User{Id: guest.Id, Name: name, Authorized: false}, guest, nil
+}
 
-		user, err := wsServer.service.GetUserById(id)
-		userId = user.Id
-		userName = user.FirstName + " " + user.SecondName
-		conn, err := upgrader.Upgrade(w, r, nil)
-		if err != nil {
-			logrus.Println(err)
-			return
+// authorizedUser returns the user with id from ConnectTeam.
+func (server *WsServer) authorizedUser(ctx context.Context, id uuid.UUID) (User, *connectionError) {
+	user, err := server.service.GetUserById(ctx, id)
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).WithField(logging.UserIdField, id).Error("cannot get user")
+		return User{}, &connectionError{http.StatusBadGateway, "upstream", "cannot get user"}
+	}
+	return User{Id: user.Id, Name: user.FirstName + " " + user.SecondName, Authorized: true, Email: user.Email}, nil
+}
+
+// ServeWs handles websocket requests from Clients requests. Rejected requests
+// are answered with an HTTP error before the upgrade.
+func ServeWs(wsServer *WsServer, w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), wsServer.getSettings().WebSocket.ActionTimeout)
+	defer cancel()
+	ctx = logging.WithRequestID(ctx, r.Header.Get(logging.RequestIDHeader))
+	ctx, span := tracing.Tracer().Start(ctx, "ServeWs", trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+	logger := logging.FromContext(ctx).WithField("remote_addr", r.RemoteAddr)
+
+	reject := func(connErr *connectionError) {
+		metrics.ConnectionsRejected.WithLabelValues(connErr.reason).Inc()
+		span.SetStatus(codes.Error, connErr.message)
+		writeJSONError(w, connErr.status, connErr.message)
+	}
+
+	if !wsServer.checkOrigin(r) {
+		logger.WithField("origin", r.Header.Get("Origin")).Warn("origin is not allowed")
+		reject(&connectionError{http.StatusForbidden, "origin", "origin is not allowed"})
+		return
+	}
+
+	ip := remoteIP(r, wsServer.getSettings().RateLimit.TrustedProxies)
+	if !wsServer.acquireConnection(ip) {
+		logger.Warn("too many connections from the address")
+		reject(&connectionError{http.StatusTooManyRequests, "ip_limit", "too many connections"})
+		return
+	}
+	accepted := false
+	defer func() {
+		if !accepted {
+			wsServer.releaseConnection(ip)
 		}
+	}()
 
-		client = newClient(conn, wsServer, User{
-			Id:         userId,
-			Name:       userName,
-			Authorized: true,
-		})
+	user, guest, connErr := wsServer.authenticate(ctx, r)
+	if connErr != nil {
+		reject(connErr)
+		return
+	}
 
+	conn, err := wsServer.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader responds with an HTTP error itself.
+		logger.WithError(err).Warn("error when upgrade")
+		metrics.ConnectionsRejected.WithLabelValues("upgrade").Inc()
+		return
+	}
+	client := newClient(conn, wsServer, user)
+	client.ip = ip
+	accepted = true
+
+	logger.WithFields(logrus.Fields{
+		logging.UserIdField:   user.Id,
+		logging.ClientIdField: client.ID,
+	}).Info("user successfully connected")
+	if client.User.Admin {
+		metrics.ConnectionsTotal.WithLabelValues("admin").Inc()
+	} else if client.User.Authorized {
+		metrics.ConnectionsTotal.WithLabelValues("user").Inc()
+	} else {
+		metrics.ConnectionsTotal.WithLabelValues("guest").Inc()
 	}
 
-	logrus.Println(fmt.Sprintf("user %s successfully connected", userId))
+	if guest != nil {
+		client.notifyClient(NewMessage(GuestIdentityAction, guest, uuid.Nil, nil, time.Now()))
+	}
 
 	go client.writePump()
 	go client.readPump()
@@ -134,40 +288,50 @@ func ServeWs(wsServer *WsServer, w http.ResponseWriter, r *http.Request) {
 	wsServer.register <- client
 }
 
-const (
-	// Max wait time when writing message to peer
-	writeWait = 10 * time.Second
-
-	// Max time till next pong from peer
-	pongWait = 60 * time.Hour
-
-	// Send ping interval, must be less than pong wait time
-	pingPeriod = (pongWait * 9) / 10
-
-	// Maximum message size allowed from peer.
-	maxMessageSize = 10000
-)
-
 func (client *Client) readPump() {
 	defer func() {
+		client.cancel()
 		client.disconnect()
+		client.wsServer.releaseConnection(client.ip)
 	}()
 
-	client.conn.SetReadLimit(maxMessageSize)
-	client.conn.SetReadDeadline(time.Now().Add(pongWait))
-	client.conn.SetPongHandler(func(string) error { client.conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
+	settings := client.wsServer.getSettings().WebSocket
+	client.conn.SetReadLimit(settings.MaxMessageSize)
+	client.conn.SetReadDeadline(time.Now().Add(settings.PongWait))
+	client.conn.SetPongHandler(func(string) error {
+		client.conn.SetReadDeadline(time.Now().Add(settings.PongWait))
+		return nil
+	})
 
 	// Start endless read loop, waiting for messages from client
 	for {
 		_, jsonMessage, err := client.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("unexpected close error: %v", err)
+				client.logger().WithError(err).Warn("unexpected close error")
 			}
 			break
 		}
 
-		client.handleNewMessage(jsonMessage)
+		// Malformed messages count against the limits with an empty action.
+		var message Message
+		parseErr := json.Unmarshal(jsonMessage, &message)
+		if client.limiter != nil {
+			handle, disconnect := client.throttle(message)
+			if disconnect {
+				client.closeMessage = websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "rate limit exceeded")
+				break
+			}
+			if !handle {
+				continue
+			}
+		}
+		if parseErr != nil {
+			client.logger().WithError(parseErr).Warn("handleNewMessage error on unmarshal JSON message")
+			metrics.ActionsTotal.WithLabelValues("", metrics.OutcomeInvalid).Inc()
+			continue
+		}
+		client.handleNewMessage(message)
 	}
 }
 
@@ -177,7 +341,8 @@ var (
 )
 
 func (client *Client) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+	settings := client.wsServer.getSettings().WebSocket
+	ticker := time.NewTicker(settings.PingPeriod)
 	defer func() {
 		ticker.Stop()
 		client.conn.Close()
@@ -185,7 +350,8 @@ func (client *Client) writePump() {
 	for {
 		select {
 		case message, ok := <-client.send:
-			client.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			metrics.SendQueueDepth.Observe(float64(len(client.send)))
+			client.conn.SetWriteDeadline(time.Now().Add(settings.WriteWait))
 			if !ok {
 				// The WsServer closed the channel.
 				client.conn.WriteMessage(websocket.CloseMessage, []byte{})
@@ -209,20 +375,62 @@ func (client *Client) writePump() {
 				return
 			}
 		case <-ticker.C:
-			client.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			client.conn.SetWriteDeadline(time.Now().Add(settings.WriteWait))
 			if err := client.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
+		case <-client.ctx.Done():
+			// The client disconnected or is disconnected by the server, e.g. for
+			// exceeding rate limits: flush queued messages and close the connection.
+			client.conn.SetWriteDeadline(time.Now().Add(settings.WriteWait))
+			for n := len(client.send); n > 0; n-- {
+				if err := client.conn.WriteMessage(websocket.TextMessage, <-client.send); err != nil {
+					return
+				}
+			}
+			if client.closeMessage != nil {
+				client.conn.WriteMessage(websocket.CloseMessage, client.closeMessage)
+			}
+			return
 		}
 	}
 }
-func (client *Client) handleNewMessage(jsonMessage []byte) {
 
-	var message Message
-	if err := json.Unmarshal(jsonMessage, &message); err != nil {
-		log.Printf("handleNewMessage error on unmarshal JSON message %s", err)
-		return
-	}
+// handleNewMessage handles the message read by readPump or sent by a bot.
+func (client *Client) handleNewMessage(message Message) {
+	ctx, cancel := client.actionContext(message)
+	defer cancel()
+	ctx = logging.WithRequestID(ctx, "")
+	ctx = logging.WithFields(ctx, logrus.Fields{
+		logging.GameIdField:   message.Target,
+		logging.UserIdField:   client.User.Id,
+		logging.ClientIdField: client.ID,
+		logging.ActionField:   message.Action,
+	})
+	ctx, span := tracing.Tracer().Start(ctx, "action "+message.Action,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("game.id", message.Target.String()),
+			attribute.String("user.id", client.User.Id.String()),
+			attribute.String("client.id", client.ID.String()),
+		))
+	defer span.End()
+
+	start := time.Now()
+	errorsSent := client.errorsSent.Load()
+	defer func() {
+		action := message.Action
+		outcome := metrics.OutcomeOk
+		if !isKnownAction(action) {
+			action = "unknown"
+			outcome = metrics.OutcomeUnknown
+		} else if client.errorsSent.Load() > errorsSent {
+			outcome = metrics.OutcomeError
+			span.SetStatus(codes.Error, "action failed")
+		}
+		metrics.ActionsTotal.WithLabelValues(action, outcome).Inc()
+		metrics.ActionDuration.WithLabelValues(action).Observe(time.Since(start).Seconds())
+	}()
 
 	// Attach the client object as the sender of the message.
 	message.Sender = client.User
@@ -230,37 +438,45 @@ func (client *Client) handleNewMessage(jsonMessage []byte) {
 	switch message.Action {
 
 	case SendMessageAction:
-		if game := client.wsServer.findGame(message.Target); game != nil {
+		if game := client.wsServer.findGame(ctx, message.Target); game != nil && game.authorize(client, message.Action) {
 			game.broadcast <- &message
 		}
 	case JoinGameAction:
-		client.handleJoinGameMessage(message)
+		client.handleJoinGameMessage(ctx, message)
 	case StartGameAction:
-		client.handleStartGameMessage(message)
+		client.handleStartGameMessage(ctx, message)
 	case LeaveGameAction:
-		client.handleLeaveGameMessage(message)
+		client.handleLeaveGameMessage(ctx, message)
 	case SelectTopicAction:
-		client.handleSelectTopicGameMessage(message)
+		client.handleSelectTopicGameMessage(ctx, message)
 	case StartRoundAction:
-		client.handleStartRoundMessage(message)
+		client.handleStartRoundMessage(ctx, message)
 	case UserStartAnswerAction:
-		client.handleUserStartAnswerMessage(message)
+		client.handleUserStartAnswerMessage(ctx, message)
 	case UserEndAnswerAction:
-		client.handleUserEndAnswerMessage(message)
+		client.handleUserEndAnswerMessage(ctx, message)
 	case RateAction:
-		client.handleRateMessage(message)
+		client.handleRateMessage(ctx, message)
 	case StartStageAction:
-		client.handleStartStageMessage(message)
+		client.handleStartStageMessage(ctx, message)
 	case EndGameAction:
-		client.handleEndGameMessage(message)
+		client.handleEndGameMessage(ctx, message)
 	case DeleteUserAction:
-		client.handleDeleteUserAction(message)
+		client.handleDeleteUserAction(ctx, message)
+	case AddBotAction:
+		client.handleAddBotMessage(ctx, message)
+	case SetRoleAction:
+		client.handleSetRoleMessage(ctx, message)
+	case AdmitGuestAction:
+		client.handleAdmitGuestMessage(ctx, message)
+	case ExportResultsAction:
+		client.handleExportResultsMessage(ctx, message)
 	}
 
 }
 
-func (client *Client) handleDeleteUserAction(message Message) {
-	game := client.wsServer.findGame(message.Target)
+func (client *Client) handleDeleteUserAction(ctx context.Context, message Message) {
+	game := client.wsServer.findGame(ctx, message.Target)
 	if game == nil {
 		message := &Message{
 			Action: Error,
@@ -274,7 +490,7 @@ func (client *Client) handleDeleteUserAction(message Message) {
 		client.notifyClient(message)
 		return
 	}
-	if !game.isCreator(client) {
+	if !game.authorize(client, message.Action) {
 		return
 	}
 	var userId uuid.UUID
@@ -289,14 +505,19 @@ func (client *Client) handleDeleteUserAction(message Message) {
 		}
 		userId = _uuid
 	}
-	for i, _ := range game.Clients {
-		if i.User.Id == userId {
-			i.notifyClient(NewMessage(UserDeletedAction, userId, game.ID, client.User, time.Now()))
-			game.unregister <- i
-			break
-		}
+	if userId == game.getCreator() {
+		client.notifyClient(NewMessage(Error, ErrorMessage{
+			Code:    9,
+			Message: "the creator cannot be removed",
+		}, game.ID, nil, time.Now()))
+		return
+	}
+	if !game.authorizeRemoval(client, userId) {
+		return
+	}
+	if !game.kickUser(userId, client.User) {
+		game.broadcast <- NewMessage(UserDeletedAction, userId, game.ID, client.User, time.Now())
 	}
-	game.broadcast <- NewMessage(UserDeletedAction, userId, game.ID, client.User, time.Now())
 }
 
 type ratePayload struct {
@@ -305,9 +526,9 @@ type ratePayload struct {
 	Tags   []uuid.UUID `json:"tags"`
 }
 
-func (client *Client) handleEndGameMessage(message Message) {
+func (client *Client) handleEndGameMessage(ctx context.Context, message Message) {
 	gameId := message.Target
-	game := client.wsServer.findGame(gameId)
+	game := client.wsServer.findGame(ctx, gameId)
 
 	if game == nil {
 		message := &Message{
@@ -323,21 +544,18 @@ func (client *Client) handleEndGameMessage(message Message) {
 		return
 	}
 
-	if game.isCreator(client) {
+	if !game.authorize(client, message.Action) {
 		return
 	}
-	game.endGame()
-	_ = client.wsServer.service.EndGame(game.ID)
-
-	game.broadcast <- NewMessage(GameAbortedAction, nil, game.ID, nil, time.Now())
+	game.abortGame(ctx, client.wsServer.service, uuid.Nil)
 	return
 
 }
 
-func (client *Client) handleStartStageMessage(message Message) {
+func (client *Client) handleStartStageMessage(ctx context.Context, message Message) {
 
 	gameId := message.Target
-	game := client.wsServer.findGame(gameId)
+	game := client.wsServer.findGame(ctx, gameId)
 	if game == nil {
 		message := &Message{
 			Action: Error,
@@ -351,18 +569,22 @@ func (client *Client) handleStartStageMessage(message Message) {
 		client.notifyClient(message)
 		return
 	}
-	if !game.isCreator(client) {
+	if !game.authorize(client, message.Action) {
+		return
+	}
+	answerTime, ok := client.answerTime(game, message.Payload)
+	if !ok {
 		return
 	}
 
-	game.startStage(client)
+	game.startStage(ctx, client, answerTime)
 }
 
-func (client *Client) handleRateMessage(message Message) {
+func (client *Client) handleRateMessage(ctx context.Context, message Message) {
 	gameId := message.Target
-	game := client.wsServer.findGame(gameId)
+	game := client.wsServer.findGame(ctx, gameId)
 
-	if game == nil || game.Round == nil {
+	if game == nil || !game.authorize(client, message.Action) || game.Round == nil {
 		return
 	}
 	game.mutex.Lock()
@@ -411,9 +633,9 @@ func (client *Client) handleRateMessage(message Message) {
 
 }
 
-func (client *Client) handleUserEndAnswerMessage(message Message) {
+func (client *Client) handleUserEndAnswerMessage(ctx context.Context, message Message) {
 	gameId := message.Target
-	game := client.wsServer.findGame(gameId)
+	game := client.wsServer.findGame(ctx, gameId)
 
 	if game == nil {
 		message := &Message{
@@ -428,15 +650,19 @@ func (client *Client) handleUserEndAnswerMessage(message Message) {
 		client.notifyClient(message)
 		return
 	}
+	if !game.authorize(client, message.Action) {
+		return
+	}
 	//game.initRates(client)
+	game.setAnswerTimer(nil, 0)
 	message.Time = time.Now()
 
 	game.broadcast <- &message
 }
 
-func (client *Client) handleUserStartAnswerMessage(message Message) {
+func (client *Client) handleUserStartAnswerMessage(ctx context.Context, message Message) {
 	gameId := message.Target
-	game := client.wsServer.findGame(gameId)
+	game := client.wsServer.findGame(ctx, gameId)
 	if game == nil {
 		message := &Message{
 			Action: Error,
@@ -450,13 +676,16 @@ func (client *Client) handleUserStartAnswerMessage(message Message) {
 		client.notifyClient(message)
 		return
 	}
+	if !game.authorize(client, message.Action) {
+		return
+	}
 	message.Time = time.Now()
 	game.broadcast <- &message
 }
 
-func (client *Client) handleStartRoundMessage(message Message) {
+func (client *Client) handleStartRoundMessage(ctx context.Context, message Message) {
 	gameId := message.Target
-	game := client.wsServer.findGame(gameId)
+	game := client.wsServer.findGame(ctx, gameId)
 	if game == nil {
 		message := &Message{
 			Action: Error,
@@ -470,7 +699,7 @@ func (client *Client) handleStartRoundMessage(message Message) {
 		client.notifyClient(message)
 		return
 	}
-	if !game.isCreator(client) {
+	if !game.authorize(client, message.Action) {
 		return
 	}
 
@@ -493,21 +722,21 @@ func (client *Client) handleStartRoundMessage(message Message) {
 		return
 	}
 
-	game.startRound(client, topicId)
+	game.startRound(ctx, client, topicId)
 
 }
 
 type startGameMessage struct {
-	Game          Game   `json:"game"`
+	Game          *Game  `json:"game"`
 	MeetingNumber string `json:"meeting_number"`
 	Passcode      string `json:"passcode"`
 	Token         string `json:"token"`
 }
 
-func (client *Client) handleStartGameMessage(message Message) {
+func (client *Client) handleStartGameMessage(ctx context.Context, message Message) {
 	gameId := message.Target
 
-	game := client.wsServer.findGame(gameId)
+	game := client.wsServer.findGame(ctx, gameId)
 	if game == nil {
 		message := &Message{
 			Action: Error,
@@ -527,12 +756,12 @@ func (client *Client) handleStartGameMessage(message Message) {
 			Message: "not enough players to start the game"}, game.ID, nil, time.Now()))
 		return
 	}
-	game.startGame(client)
+	game.startGame(ctx, client)
 }
 
-func (client *Client) handleSelectTopicGameMessage(message Message) {
+func (client *Client) handleSelectTopicGameMessage(ctx context.Context, message Message) {
 	gameId := message.Target
-	game := client.wsServer.findGame(gameId)
+	game := client.wsServer.findGame(ctx, gameId)
 
 	if game == nil {
 		message := &Message{
@@ -548,29 +777,30 @@ func (client *Client) handleSelectTopicGameMessage(message Message) {
 		return
 	}
 
-	if !game.isCreator(client) {
+	if !game.authorize(client, message.Action) {
 		return
 	}
 
-	userPlan, err := client.wsServer.service.GetCreatorPlan(game.getCreator())
-	if err != nil {
-		client.notifyClient(NewMessage(Error, ErrorMessage{
-			Code:    7,
-			Message: fmt.Sprintf("error to get topics from database: %s", err.Error()),
-		}, game.ID, nil, time.Now()))
-		return
-	}
+	entitlements := game.getEntitlements()
+	payloadData, isList := message.Payload.([]interface{})
 
-	switch userPlan.PlanType {
-	case plan_types.Basic:
-		topics, err := client.wsServer.service.GetRandTopicsWithLimit(3)
+	if !entitlements.CustomTopics {
+		if len(payloadData) > 0 {
+			client.notifyClient(newFeatureNotInPlanMessage(game.ID, FeatureCustomTopics,
+				"topics are selected randomly"))
+			return
+		}
+		topics, err := client.wsServer.service.GetRandTopicsWithLimit(ctx, entitlements.TopicCount)
 		if err != nil || topics == nil {
 			client.notifyClient(NewMessage(Error, ErrorMessage{
 				Code:    7,
-				Message: fmt.Sprintf("cannot get topics: %s", err.Error()),
+				Message: fmt.Sprintf("cannot get topics: %v", err),
 			}, game.ID, nil, time.Now()))
+			return
 		}
 		game.setTopics(topics)
+		topicIds, count := game.questionDemand()
+		game.prefetchQuestions(client.wsServer, topicIds, count)
 		client.notifyClient(NewMessage(
 			message.Action,
 			game.Topics,
@@ -578,68 +808,96 @@ func (client *Client) handleSelectTopicGameMessage(message Message) {
 			message.Sender,
 			time.Now()))
 		return
-	case plan_types.Advanced, plan_types.Premium:
-		var topics []models.Topic
-		if payloadData, ok := message.Payload.([]interface{}); ok {
-			for i := range payloadData {
-				uuidString, ok := payloadData[i].(string)
-				if !ok {
-					continue
-				}
-				topicUuid, err := uuid.Parse(uuidString)
-				if err != nil {
-					continue
-				}
-				topic, _ := client.wsServer.service.GetTopic(topicUuid)
-				topics = append(topics, topic)
-			}
-			game.setTopics(topics)
-			client.notifyClient(NewMessage(
-				message.Action,
-				game.Topics,
-				game.ID,
-				message.Sender,
-				time.Now()))
-			return
-		} else {
-			client.notifyClient(NewMessage(Error, ErrorMessage{
-				Code:    7,
-				Message: "incorrect payload",
-			}, game.ID, nil, time.Now()))
-			return
-		}
+	}
 
-	default:
+	if !isList {
 		client.notifyClient(NewMessage(Error, ErrorMessage{
 			Code:    7,
-			Message: "cannot get creator plan",
+			Message: "incorrect payload",
 		}, game.ID, nil, time.Now()))
 		return
 	}
+	if entitlements.TopicCount > 0 && len(payloadData) > entitlements.TopicCount {
+		client.notifyClient(newFeatureNotInPlanMessage(game.ID, FeatureTopicCount,
+			fmt.Sprintf("at most %d topics can be selected", entitlements.TopicCount)))
+		return
+	}
 
+	var topics []models.Topic
+	for i := range payloadData {
+		uuidString, ok := payloadData[i].(string)
+		if !ok {
+			continue
+		}
+		topicUuid, err := uuid.Parse(uuidString)
+		if err != nil {
+			continue
+		}
+		topic, _ := client.wsServer.service.GetTopic(ctx, topicUuid)
+		topics = append(topics, topic)
+	}
+	game.setTopics(topics)
+	topicIds, count := game.questionDemand()
+	game.prefetchQuestions(client.wsServer, topicIds, count)
+	client.notifyClient(NewMessage(
+		message.Action,
+		game.Topics,
+		game.ID,
+		message.Sender,
+		time.Now()))
 }
 
-func (client *Client) handleJoinGameMessage(message Message) {
-	gameId := message.Target
-	game := client.wsServer.findGame(gameId)
+func (client *Client) handleJoinGameMessage(ctx context.Context, message Message) {
+	var payload joinPayload
+	if data, err := json.Marshal(message.Payload); err == nil {
+		_ = json.Unmarshal(data, &payload)
+	}
+	var game *Game
+	target := message.Target.String()
+	if message.Target == uuid.Nil && payload.Code != "" {
+		game = client.wsServer.findGameByCode(payload.Code)
+		target = "with code " + payload.Code
+	} else {
+		game = client.wsServer.findGame(ctx, message.Target)
+	}
 	if game == nil {
 		message := &Message{
 			Action: Error,
 			Target: message.Target,
 			Payload: ErrorMessage{
 				Code:    2,
-				Message: fmt.Sprintf("user %s cannot join the game %s", client.User.Id, message.Target),
+				Message: fmt.Sprintf("user %s cannot join the game %s", client.User.Id, target),
 			},
 			Time: time.Now(),
 		}
 		client.notifyClient(message)
 		return
 	}
+	if !game.checkAccess(client, payload) {
+		return
+	}
+	if payload.Spectator {
+		game.spectate <- client
+		return
+	}
 	game.register <- client
 
 }
 
+// joinPayload is the optional payload of join-game.
+type joinPayload struct {
+	// Spectator joins the game to watch it, see RoleSpectator.
+	Spectator bool `json:"spectator"`
+	// Code is the join code of the game sent with Nil target instead of the game ID.
+	Code string `json:"code"`
+	// Passcode of the game, see models.GameAccess.
+	Passcode string `json:"passcode"`
+}
+
 func (client *Client) notifyClient(message *Message) {
+	if message.Action == Error {
+		client.errorsSent.Add(1)
+	}
 	client.send <- message.encode()
 }
 
@@ -653,8 +911,8 @@ func (client *Client) notifyClientJoined(game *Game) {
 	game.broadcastToClientsInGame(message.encode())
 }
 
-func (client *Client) handleLeaveGameMessage(message Message) {
-	game := client.wsServer.findGame(message.Target)
+func (client *Client) handleLeaveGameMessage(ctx context.Context, message Message) {
+	game := client.wsServer.findGame(ctx, message.Target)
 	if game == nil {
 		message := &Message{
 			Action: Error,
@@ -669,21 +927,12 @@ func (client *Client) handleLeaveGameMessage(message Message) {
 		return
 	}
 
-	if client.User.Id == game.Creator && game.Status == game_status.GameInProgress {
-		_ = client.wsServer.service.EndGame(game.ID)
-		game.endGame()
-		game.unregister <- client
-		game.broadcast <- NewMessage(UserLeftAction, client.User.Id, game.ID, nil, time.Now())
-		game.broadcast <- NewMessage(GameAbortedAction, client.User.Id, game.ID, nil, time.Now())
-		return
-
-	}
-
+	// The game is aborted when the creator leaves it or too few players are left;
+	// abortGame ends it only once, so concurrent end-game and admin abort are no-ops.
+	abort := client.User.Id == game.Creator || game.playersExcept(client.User.Id) < 2
 	game.unregister <- client
 	game.broadcast <- NewMessage(UserLeftAction, client.User.Id, game.ID, nil, time.Now())
-	if len(game.Users) < 2 && game.Status == game_status.GameInProgress {
-		_ = client.wsServer.service.EndGame(game.ID)
-		game.endGame()
-		game.broadcast <- NewMessage(GameAbortedAction, client.User.Id, game.ID, nil, time.Now())
+	if abort && game.getStatus() == game_status.GameInProgress {
+		game.abortGame(ctx, client.wsServer.service, client.User.Id)
 	}
 }
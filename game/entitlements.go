@@ -0,0 +1,62 @@
+package game
+
+import (
+	"GameService/config"
+	"GameService/logging"
+	"GameService/repository/models"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// FeatureNotInPlan is a reason of errors caused by the game creator's plan.
+const FeatureNotInPlan = "feature-not-in-plan"
+
+// Features checked against plan entitlements.
+const (
+	FeaturePlayers      = "players"
+	FeatureCustomTopics = "custom-topics"
+	FeatureTopicCount   = "topic-count"
+	FeatureSpectators   = "spectators"
+	FeatureTimers       = "timers"
+	FeatureExports      = "exports"
+	FeatureMeeting      = "meeting"
+)
+
+// entitlements returns entitlements of the plan from the configured source.
+func (server *WsServer) entitlements(ctx context.Context, planType string) (models.Entitlements, error) {
+	settings := server.getSettings()
+	if settings.EntitlementsSource == config.EntitlementsSourceConnectTeam {
+		entitlements, err := server.service.GetEntitlements(ctx, planType)
+		if err == nil {
+			return entitlements, nil
+		}
+		logging.FromContext(ctx).WithError(err).WithField("plan_type", planType).
+			Warn("cannot get entitlements from ConnectTeam, using config")
+	}
+
+	entitlements, ok := settings.Plans[planType]
+	if !ok {
+		return models.Entitlements{}, fmt.Errorf("unknown plan %q", planType)
+	}
+	return entitlements, nil
+}
+
+// creatorEntitlements returns entitlements of the active plan of the game creator.
+func (server *WsServer) creatorEntitlements(ctx context.Context, creatorId uuid.UUID) (models.Entitlements, error) {
+	plan, err := server.service.GetCreatorPlan(ctx, creatorId)
+	if err != nil {
+		return models.Entitlements{}, fmt.Errorf("cannot get creator plan: %w", err)
+	}
+	return server.entitlements(ctx, plan.PlanType)
+}
+
+// newFeatureNotInPlanMessage creates an error reporting that feature is not available in the creator's plan.
+func newFeatureNotInPlanMessage(gameId uuid.UUID, feature string, details string) *Message {
+	return NewMessage(Error, ErrorMessage{
+		Code:    13,
+		Reason:  FeatureNotInPlan,
+		Message: fmt.Sprintf("%s: %s is not available in the plan: %s", FeatureNotInPlan, feature, details),
+	}, gameId, nil, time.Now())
+}
@@ -0,0 +1,84 @@
+package game
+
+import (
+	"GameService/consts/game_status"
+	"GameService/repository/models"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"github.com/google/uuid"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportResultsAction exports results of the ended game. Results are sent back
+// to the client with the same action and resultsExport payload.
+const ExportResultsAction = "export-results"
+
+// resultsExport is the payload of export-results sent to the client.
+type resultsExport struct {
+	Format string `json:"format"`
+	Data   string `json:"data"`
+}
+
+func (client *Client) handleExportResultsMessage(ctx context.Context, message Message) {
+	game := client.wsServer.findGame(ctx, message.Target)
+	if game == nil {
+		client.notifyClient(NewMessage(Error, ErrorMessage{
+			Code:    2,
+			Message: fmt.Sprintf("game %s is not found", message.Target),
+		}, message.Target, nil, time.Now()))
+		return
+	}
+	if !game.authorize(client, message.Action) {
+		return
+	}
+	if !game.getEntitlements().Exports {
+		client.notifyClient(newFeatureNotInPlanMessage(game.ID, FeatureExports, "results cannot be exported"))
+		return
+	}
+	if game.getStatus() != game_status.GameEnded {
+		client.notifyClient(NewMessage(Error, ErrorMessage{
+			Code:    5,
+			Message: "results are exported after the game ends",
+		}, game.ID, nil, time.Now()))
+		return
+	}
+	results, err := client.wsServer.service.GetResults(ctx, game.ID)
+	if err != nil {
+		client.notifyClient(NewMessage(Error, ErrorMessage{
+			Code:    7,
+			Message: fmt.Sprintf("cannot get results: %v", err),
+		}, game.ID, nil, time.Now()))
+		return
+	}
+	data, err := resultsCSV(results.Results)
+	if err != nil {
+		client.logger().WithError(err).Error("cannot export results")
+		return
+	}
+	client.notifyClient(NewMessage(ExportResultsAction, resultsExport{Format: "csv", Data: data}, game.ID, nil, time.Now()))
+}
+
+// resultsCSV formats results as CSV with a header row. Tags are separated by
+// semicolons, user_id is empty for guests.
+func resultsCSV(results []models.Results) (string, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	_ = writer.Write([]string{"name", "user_id", "value", "tags"})
+	for _, result := range results {
+		userId := ""
+		if result.UserId != uuid.Nil {
+			userId = result.UserId.String()
+		}
+		tags := make([]string, 0, len(result.Tags))
+		for _, tag := range result.Tags {
+			tags = append(tags, tag.Name)
+		}
+		_ = writer.Write([]string{result.Name, userId, strconv.Itoa(result.Value), strings.Join(tags, ";")})
+	}
+	writer.Flush()
+	return buffer.String(), writer.Error()
+}
@@ -0,0 +1,82 @@
+package game
+
+import (
+	"GameService/consts/game_status"
+	"GameService/consts/plan_types"
+	"GameService/repository/models"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"testing"
+)
+
+func TestResultsCSV(t *testing.T) {
+	userId := uuid.MustParse("7c9e6679-7425-40de-944b-e07fc1f90ae7")
+	data, err := resultsCSV([]models.Results{
+		{Name: "Alice", UserId: userId, Value: 5, Tags: []models.Tag{{Name: "clear"}, {Name: "brief"}}},
+		{Name: "Guest, Bob", UserTemporaryId: uuid.New(), Value: 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "name,user_id,value,tags\n" +
+		"Alice,7c9e6679-7425-40de-944b-e07fc1f90ae7,5,clear;brief\n" +
+		"\"Guest, Bob\",,3,\n"
+	if data != want {
+		t.Fatalf("exported\n%s\nexpected\n%s", data, want)
+	}
+}
+
+func TestExportResults(t *testing.T) {
+	server, repository := newTestServer(testSettings())
+
+	tests := []struct {
+		name     string
+		planType string
+		ended    bool
+		player   bool
+		code     int
+	}{
+		{name: "exported", planType: plan_types.Advanced, ended: true},
+		{name: "not in plan", planType: plan_types.Basic, ended: true, code: 13},
+		{name: "game in progress", planType: plan_types.Advanced, code: 5},
+		{name: "player", planType: plan_types.Advanced, ended: true, player: true, code: 8},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			game := newTestGame(t, server, repository, tt.planType)
+			client := newTestClient(server, User{Id: game.Creator, Name: "Host", Authorized: true})
+			if tt.player {
+				client = newTestClient(server, User{Name: "Player", Authorized: true})
+				join(game, client)
+			}
+			ctx := context.Background()
+			if tt.ended {
+				_ = repository.SaveResults(ctx, game.ID, []models.Rates{{Name: "Player", Value: 4}})
+				game.mutex.Lock()
+				game.endGame()
+				game.mutex.Unlock()
+			}
+
+			client.handleExportResultsMessage(ctx, Message{Action: ExportResultsAction, Target: game.ID})
+			if tt.code != 0 {
+				if err := receiveError(t, client); err.Code != tt.code {
+					t.Fatalf("error code %d, expected %d: %s", err.Code, tt.code, err.Message)
+				}
+				return
+			}
+			message := receive(t, client)
+			var export resultsExport
+			if message.Action != ExportResultsAction || json.Unmarshal(message.Payload, &export) != nil {
+				t.Fatalf("received %s %s", message.Action, message.Payload)
+			}
+			if export.Format != "csv" || export.Data != "name,user_id,value,tags\nPlayer,,4,\n" {
+				t.Fatalf("export is %+v", export)
+			}
+			if game.getStatus() != game_status.GameEnded {
+				t.Fatal("game is not ended")
+			}
+		})
+	}
+}
@@ -2,7 +2,11 @@ package game
 
 import (
 	"GameService/consts/game_status"
+	"GameService/logging"
+	"GameService/metrics"
 	"GameService/repository/models"
+	service "GameService/repository/requests"
+	"context"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/ledongthuc/goterators"
@@ -11,28 +15,54 @@ import (
 )
 
 type Game struct {
-	Name       string           `json:"name,omitempty"`
-	Clients    map[*Client]bool `json:"-"`
-	MaxSize    int              `json:"max_size,omitempty"`
-	Status     string           `json:"status,omitempty"`
-	Creator    uuid.UUID        `json:"creator_id,omitempty"`
-	Topics     []Topic          `json:"topics,omitempty"`
-	Round      *Round           `json:"round,omitempty"`
+	Name    string           `json:"name,omitempty"`
+	Clients map[*Client]bool `json:"-"`
+	MaxSize int              `json:"max_size,omitempty"`
+	Status  string           `json:"status,omitempty"`
+	Creator uuid.UUID        `json:"creator_id,omitempty"`
+	// Players promoted to co-hosts by the creator.
+	CoHosts []uuid.UUID `json:"co_hosts,omitempty"`
+	// Short code joining the game instead of its ID, see WsServer.findGameByCode.
+	JoinCode   string  `json:"join_code,omitempty"`
+	Topics     []Topic `json:"topics,omitempty"`
+	Round      *Round  `json:"round,omitempty"`
 	register   chan *Client
+	spectate   chan *Client
 	unregister chan *Client
 	broadcast  chan *Message
 	ID         uuid.UUID            `json:"id"`
 	Users      []*User              `json:"users,omitempty"`
 	Results    map[uuid.UUID]*Rates `json:"-"`
-	mutex      sync.Mutex
+	// Entitlements of the creator's plan.
+	Entitlements models.Entitlements `json:"entitlements"`
+	mutex        sync.Mutex
+	// Users watching the game, see RoleSpectator.
+	spectators map[uuid.UUID]bool
+	// Access policy of the game from ConnectTeam.
+	access models.GameAccess
+	// Guests waiting for a host to admit them by ID.
+	waiting map[uuid.UUID]waitingGuest
+	// Guests admitted by a host who join again without waiting.
+	admitted map[uuid.UUID]bool
+	// Questions fetched for selected topics before the game starts.
+	questions questionPool
+	// answerTimer ends the answer of the current stage, see setAnswerTimer.
+	answerTimer *time.Timer
+	// answerStage identifies the stage of answerTimer, a timer of a previous stage does nothing.
+	answerStage int
+	// ctx is cancelled when the game ends to stop actions in progress.
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // UserQuestion Генерируются в начале раунда.
 type UserQuestion struct {
-	Number   int                  `json:"number"`
-	User     User                 `json:"user"`
-	Question Question             `json:"question"`
-	Rates    map[uuid.UUID]*Rates `json:"-"`
+	Number   int      `json:"number"`
+	User     User     `json:"user"`
+	Question Question `json:"question"`
+	// Answer time limit of the stage in seconds, zero when the answer is not limited.
+	AnswerTime int                  `json:"answer_time,omitempty"`
+	Rates      map[uuid.UUID]*Rates `json:"-"`
 }
 type Rates struct {
 	Value int                `json:"value"`
@@ -64,19 +94,27 @@ type Tag struct {
 }
 
 // NewGame creates a new game.
-func NewGame(name string, id uuid.UUID, creator uuid.UUID, status string, maxSize int) *Game {
+func NewGame(name string, id uuid.UUID, creator uuid.UUID, status string, entitlements models.Entitlements) *Game {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Game{
-		ID:         id,
-		Name:       name,
-		Topics:     make([]Topic, 0),
-		Creator:    creator,
-		Status:     status,
-		MaxSize:    maxSize,
-		Users:      make([]*User, 0),
-		Clients:    make(map[*Client]bool),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		broadcast:  make(chan *Message),
+		ID:           id,
+		Name:         name,
+		Topics:       make([]Topic, 0),
+		Creator:      creator,
+		Status:       status,
+		MaxSize:      entitlements.MaxPlayers,
+		Entitlements: entitlements,
+		Users:        make([]*User, 0),
+		Clients:      make(map[*Client]bool),
+		spectators:   make(map[uuid.UUID]bool),
+		waiting:      make(map[uuid.UUID]waitingGuest),
+		admitted:     make(map[uuid.UUID]bool),
+		register:     make(chan *Client),
+		spectate:     make(chan *Client),
+		unregister:   make(chan *Client),
+		broadcast:    make(chan *Message),
+		ctx:          ctx,
+		cancel:       cancel,
 	}
 }
 
@@ -88,6 +126,18 @@ func (game *Game) GetName() string {
 	return game.Name
 }
 
+func (game *Game) getEntitlements() models.Entitlements {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	return game.Entitlements
+}
+
+func (game *Game) getStatus() string {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	return game.Status
+}
+
 // RunGame run game, accepting various requests.
 func (game *Game) RunGame() {
 	for {
@@ -95,6 +145,9 @@ func (game *Game) RunGame() {
 		case client := <-game.register:
 			game.registerClientInGame(client)
 
+		case client := <-game.spectate:
+			game.registerSpectator(client)
+
 		case client := <-game.unregister:
 			game.unregisterClientInGame(client)
 
@@ -113,11 +166,20 @@ func (game *Game) registerClientInGame(client *Client) {
 			message := NewMessage(UserJoinedAction, game, game.ID, client.User, time.Now())
 			client.notifyClient(message)
 			game.Clients[client] = true
+			game.notifyJoinRequests(client)
 			return
 		}
 	}
 
-	if len(game.Users) == game.MaxSize {
+	if client.User.Admin {
+		// Moderators do not take a seat and can join games in progress.
+		game.Clients[client] = true
+		client.notifyClient(NewMessage(UserJoinedAction, game, game.ID, client.User, time.Now()))
+		game.notifyJoinRequests(client)
+		return
+	}
+
+	if len(game.Users) >= game.MaxSize {
 		message := NewMessage(Error, ErrorMessage{
 			Code:    1,
 			Message: "max number of participants",
@@ -136,23 +198,193 @@ func (game *Game) registerClientInGame(client *Client) {
 		return
 	}
 
+	if !client.User.Authorized && !client.User.Bot && game.hasNameOf(client.User) {
+		client.notifyClient(NewMessage(Error, ErrorMessage{
+			Code:    16,
+			Reason:  DuplicateName,
+			Message: fmt.Sprintf("name %q is taken in the game", client.User.Name),
+		}, game.ID, nil, time.Now()))
+		return
+	}
+
 	message := NewMessage(UserJoinedAction, game, game.ID, client.User, time.Now())
 
+	delete(game.spectators, client.User.Id)
 	game.Users = append(game.Users, client.User)
+	game.prefetchQuestions(client.wsServer, game.topicIds(), len(game.Users))
 	client.notifyClientJoined(game)
 	game.Clients[client] = true
 	client.notifyClient(message)
+	game.notifyJoinRequests(client)
 	return
 }
 
+// registerSpectator adds the client watching the game without playing. The
+// number of spectators is limited by the plan of the creator.
+func (game *Game) registerSpectator(client *Client) {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+
+	if client.User.Bot || client.User.Id == game.Creator || game.hasPlayer(client.User.Id) {
+		client.notifyClient(NewMessage(Error, ErrorMessage{
+			Code:    9,
+			Message: "players cannot join as spectators",
+		}, game.ID, nil, time.Now()))
+		return
+	}
+	if game.Status == game_status.GameEnded {
+		client.notifyClient(NewMessage(Error, ErrorMessage{
+			Code:    2,
+			Message: "game ended",
+		}, game.ID, nil, time.Now()))
+		return
+	}
+	if !game.spectators[client.User.Id] && len(game.spectators) >= game.Entitlements.Spectators {
+		client.notifyClient(newFeatureNotInPlanMessage(game.ID, FeatureSpectators,
+			fmt.Sprintf("at most %d spectators can watch", game.Entitlements.Spectators)))
+		return
+	}
+	game.spectators[client.User.Id] = true
+	game.Clients[client] = true
+	client.notifyClient(NewMessage(UserJoinedAction, game, game.ID, client.User, time.Now()))
+}
+
+func (game *Game) hasPlayer(userId uuid.UUID) bool {
+	for _, player := range game.Users {
+		if player.Id == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// playersExcept returns the number of players of the game other than the user.
+func (game *Game) playersExcept(userId uuid.UUID) int {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	count := 0
+	for _, player := range game.Users {
+		if player.Id != userId {
+			count++
+		}
+	}
+	return count
+}
+
+// endGame marks the game as ended, cancels actions in progress and stops bots.
+// The caller must hold game.mutex.
 func (game *Game) endGame() {
 	game.Status = game_status.GameEnded
+	game.cancel()
+	game.stopAnswerTimer()
+	for client := range game.Clients {
+		if client.User.Bot {
+			client.cancel()
+		}
+	}
+}
+
+// finishGame ends the game, saves results and broadcasts them to players.
+// Results are saved even if ctx is cancelled by the end of the game. It reports
+// false and does nothing when the game has already ended.
+func (game *Game) finishGame(ctx context.Context, service *service.Repository) bool {
+	ctx = context.WithoutCancel(ctx)
+	game.mutex.Lock()
+	if game.Status == game_status.GameEnded {
+		game.mutex.Unlock()
+		return false
+	}
+	game.endGame()
+	results := make([]models.Rates, 0)
+	for i := range game.Users {
+		if game.Users[i].Bot {
+			continue
+		}
+		userTempId := game.Users[i].Id
+		userId := uuid.Nil
+		if game.Users[i].Authorized {
+			userId = game.Users[i].Id
+		}
+		rates, ok := game.Results[game.Users[i].Id]
+		if !ok {
+			rates = &Rates{Tags: make(map[uuid.UUID]bool)}
+		}
+		var tags []uuid.UUID
+		for j := range rates.Tags {
+			tags = append(tags, j)
+		}
+		results = append(results, models.Rates{
+			Value:           rates.Value,
+			Tags:            tags,
+			UserId:          userId,
+			UserTemporaryId: userTempId,
+			Name:            game.Users[i].Name,
+		})
+	}
+	game.mutex.Unlock()
+
+	_ = service.SaveResults(ctx, game.ID, results)
+	_ = service.EndGame(ctx, game.ID)
+	payload, _ := service.GetResults(ctx, game.ID)
+	game.broadcast <- &Message{
+		Action:  GameEndedAction,
+		Payload: payload,
+		Target:  game.ID,
+	}
+	return true
+}
+
+// abortGame ends the game without saving results. The leaver is sent in game-abort
+// when the game is aborted because the player left, uuid.Nil otherwise. It reports
+// false and does nothing when the game has already ended.
+func (game *Game) abortGame(ctx context.Context, service *service.Repository, leaver uuid.UUID) bool {
+	ctx = context.WithoutCancel(ctx)
+	game.mutex.Lock()
+	if game.Status == game_status.GameEnded {
+		game.mutex.Unlock()
+		return false
+	}
+	game.endGame()
+	game.mutex.Unlock()
+
+	var payload interface{}
+	if leaver != uuid.Nil {
+		payload = leaver
+	}
+	_ = service.EndGame(ctx, game.ID)
+	game.broadcast <- NewMessage(GameAbortedAction, payload, game.ID, nil, time.Now())
+	return true
+}
+
+// kickUser removes user from the game and notifies players.
+func (game *Game) kickUser(userId uuid.UUID, sender *User) bool {
+	game.mutex.Lock()
+	var kicked *Client
+	for i := range game.Clients {
+		if i.User.Id == userId {
+			kicked = i
+			break
+		}
+	}
+	game.mutex.Unlock()
+	if kicked == nil {
+		return false
+	}
+	kicked.notifyClient(NewMessage(UserDeletedAction, userId, game.ID, sender, time.Now()))
+	game.unregister <- kicked
+	game.broadcast <- NewMessage(UserDeletedAction, userId, game.ID, sender, time.Now())
+	return true
 }
 
 func (game *Game) unregisterClientInGame(client *Client) {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+
 	if _, ok := game.Clients[client]; ok {
 		delete(game.Clients, client)
 	}
+	delete(game.spectators, client.User.Id)
+	delete(game.waiting, client.User.Id)
 
 	for i := range game.Users {
 		if game.Users[i].Id == client.User.Id {
@@ -172,50 +404,26 @@ func (game *Game) unregisterClientInGame(client *Client) {
 }
 
 func (game *Game) broadcastToClientsInGame(message []byte) {
+	start := time.Now()
 	for client := range game.Clients {
 		client.send <- message
 	}
+	metrics.BroadcastFanOut.Observe(float64(len(game.Clients)))
+	metrics.BroadcastDuration.Observe(time.Since(start).Seconds())
 }
 
 func (game *Game) getCreator() uuid.UUID {
 	return game.Creator
 }
 
-func (game *Game) startRound(client *Client, topicId uuid.UUID) {
+func (game *Game) startRound(ctx context.Context, client *Client, topicId uuid.UUID) {
 	if game.Status == game_status.GameEnded {
 		return
 	}
 	if len(goterators.Filter(game.Topics, func(item Topic) bool {
 		return item.Used == false
 	})) == 0 {
-		game.endGame()
-		results := make([]models.Rates, 0)
-		for i, _ := range game.Users {
-			userTempId := game.Users[i].Id
-			userId := uuid.Nil
-			if game.Users[i].Authorized {
-				userId = game.Users[i].Id
-			}
-			var tags []uuid.UUID
-			for j := range game.Results[game.Users[i].Id].Tags {
-				tags = append(tags, j)
-			}
-			results = append(results, models.Rates{
-				Value:           game.Results[game.Users[i].Id].Value,
-				Tags:            tags,
-				UserId:          userId,
-				UserTemporaryId: userTempId,
-				Name:            game.Users[i].Name,
-			})
-		}
-		_ = client.wsServer.service.SaveResults(game.ID, results)
-		_ = client.wsServer.service.EndGame(game.ID)
-		payload, _ := client.wsServer.service.GetResults(game.ID)
-		game.broadcast <- &Message{
-			Action:  GameEndedAction,
-			Payload: payload,
-			Target:  game.ID,
-		}
+		game.finishGame(ctx, client.wsServer.service)
 		return
 	}
 
@@ -281,19 +489,8 @@ func (game *Game) setTopics(topics []models.Topic) {
 	}
 }
 
-func (game *Game) isCreator(client *Client) bool {
-	if game.getCreator() != client.User.Id {
-		client.notifyClient(NewMessage(Error, ErrorMessage{
-			Code:    8,
-			Message: "permission denied",
-		}, game.ID, nil, time.Now()))
-		return false
-	}
-	return true
-}
-
-func (game *Game) startGame(client *Client) {
-	if !game.isCreator(client) {
+func (game *Game) startGame(ctx context.Context, client *Client) {
+	if !game.authorize(client, StartGameAction) {
 		return
 	}
 	if len(game.Topics) == 0 {
@@ -311,53 +508,44 @@ func (game *Game) startGame(client *Client) {
 		}, game.ID, nil, time.Now()))
 		return
 	}
+	if len(game.Users) > game.getEntitlements().MaxPlayers {
+		client.notifyClient(newFeatureNotInPlanMessage(game.ID, FeaturePlayers,
+			fmt.Sprintf("at most %d players can play", game.getEntitlements().MaxPlayers)))
+		return
+	}
 
-	meetingNumber, passcode, err := client.wsServer.service.Meeting.CreateMeeting()
+	questions, err := game.questions.fill(ctx, client.wsServer.service, game.topicIds(), len(game.Users))
 	if err != nil {
+		logging.FromContext(ctx).WithError(err).Warn("cannot get questions")
 		client.notifyClient(NewMessage(Error, ErrorMessage{
 			Code:    4,
-			Message: fmt.Sprintf("error to start game: %s", err.Error()),
+			Message: fmt.Sprintf("not enough questions to start game: %s", err.Error()),
 		}, game.ID, nil, time.Now()))
 		return
 	}
-	meetingJWT, _ := client.wsServer.generator.GenerateJWTForMeeting(meetingNumber, 0)
-
-	questions := make(map[uuid.UUID][]models.Question)
+	for i := range game.Topics {
+		topicQuestions := questions[game.Topics[i].Id]
+		game.Topics[i].Questions = make([]Question, len(topicQuestions))
+		for j := range topicQuestions {
+			game.Topics[i].Questions[j] = newQuestion(topicQuestions[j])
+		}
+	}
 
-	for i, _ := range game.Topics {
-		var err error
-		questions[game.Topics[i].Id], err = client.wsServer.service.GetRandQuestionsWithLimit(game.Topics[i].Id, len(game.Users))
+	var meetingNumber, passcode, meetingJWT, hostMeetingJWT string
+	if game.getEntitlements().Meeting {
+		meetingNumber, passcode, err = client.wsServer.service.Meeting.CreateMeeting(ctx)
 		if err != nil {
-			continue
-		}
-		if len(questions[game.Topics[i].Id]) != len(game.Users) {
 			client.notifyClient(NewMessage(Error, ErrorMessage{
 				Code:    4,
-				Message: fmt.Sprintf("not enough question to start game"),
+				Message: fmt.Sprintf("error to start game: %s", err.Error()),
 			}, game.ID, nil, time.Now()))
 			return
 		}
-		game.Topics[i].Questions = make([]Question, len(game.Users))
-		for j := 0; j < len(game.Users); j++ {
-			if questions[game.Topics[i].Id] != nil {
-				tags := make([]Tag, len(questions[game.Topics[i].Id][j].Tags))
-				for k := range questions[game.Topics[i].Id][j].Tags {
-					tags[k] = Tag{
-						Id:   questions[game.Topics[i].Id][j].Tags[k].Id,
-						Name: questions[game.Topics[i].Id][j].Tags[k].Name,
-					}
-				}
-				game.Topics[i].Questions[j] = Question{
-					Id:      questions[game.Topics[i].Id][j].Id,
-					TopicId: questions[game.Topics[i].Id][j].TopicId,
-					Content: questions[game.Topics[i].Id][j].Content,
-					Tags:    tags,
-				}
-			}
-		}
+		meetingJWT, _ = client.wsServer.generator.GenerateJWTForMeeting(meetingNumber, 0)
+		hostMeetingJWT, _ = client.wsServer.generator.GenerateJWTForMeeting(meetingNumber, 1)
 	}
 
-	err = client.wsServer.service.StartGame(game.ID)
+	err = client.wsServer.service.StartGame(ctx, game.ID)
 	if err != nil {
 		client.notifyClient(NewMessage(Error, ErrorMessage{
 			Code:    4,
@@ -367,62 +555,35 @@ func (game *Game) startGame(client *Client) {
 	}
 
 	var payload = &startGameMessage{
-		Game:          *game,
+		Game:          game,
 		MeetingNumber: meetingNumber,
 		Passcode:      passcode,
 		Token:         meetingJWT,
 	}
 
-	hostMeetingJWT, _ := client.wsServer.generator.GenerateJWTForMeeting(meetingNumber, 1)
-
 	game.Status = "in_progress"
 	for client := range game.Clients {
-		if client.User.Id == game.Creator {
-			payload.Token = hostMeetingJWT
+		clientPayload := *payload
+		if client.User.Id == game.Creator || client.User.Admin {
+			clientPayload.Token = hostMeetingJWT
 		}
-		client.notifyClient(NewMessage(StartGameAction, &payload, game.ID, client.User, time.Now()))
+		client.notifyClient(NewMessage(StartGameAction, &clientPayload, game.ID, client.User, time.Now()))
 	}
 }
 
-func (game *Game) startStage(client *Client) {
+func (game *Game) startStage(ctx context.Context, client *Client, answerTime time.Duration) {
 	if game.Status == game_status.GameEnded {
 		return
 	}
 	if len(goterators.Filter(game.Topics, func(item Topic) bool {
 		return item.Used == false
 	})) == 0 && len(game.Round.UsersQuestions) == 0 {
-		game.endGame()
-		results := make([]models.Rates, 0)
-		for i, _ := range game.Users {
-			userTempId := game.Users[i].Id
-			userId := uuid.Nil
-			if game.Users[i].Authorized {
-				userId = game.Users[i].Id
-			}
-			var tags []uuid.UUID
-			for j := range game.Results[game.Users[i].Id].Tags {
-				tags = append(tags, j)
-			}
-			results = append(results, models.Rates{
-				Value:           game.Results[game.Users[i].Id].Value,
-				Tags:            tags,
-				UserId:          userId,
-				UserTemporaryId: userTempId,
-				Name:            game.Users[i].Name,
-			})
-		}
-		_ = client.wsServer.service.SaveResults(game.ID, results)
-		_ = client.wsServer.service.EndGame(game.ID)
-		payload, _ := client.wsServer.service.GetResults(game.ID)
-		game.broadcast <- &Message{
-			Action:  GameEndedAction,
-			Payload: payload,
-			Target:  game.ID,
-		}
+		game.finishGame(ctx, client.wsServer.service)
 
 		return
 	}
 	if len(game.Round.UsersQuestions) == 0 {
+		game.setAnswerTimer(nil, 0)
 		game.broadcast <- &Message{
 			Action:  RoundEndAction,
 			Target:  game.ID,
@@ -436,6 +597,7 @@ func (game *Game) startStage(client *Client) {
 		respondent = game.Round.UsersQuestions[0]
 	}
 	payload := respondent
+	game.setAnswerTimer(respondent, answerTime)
 
 	game.broadcast <- &Message{
 		Action:  StartStageAction,
@@ -474,6 +636,8 @@ func (game *Game) initRates(client *Client) {
 	}
 }
 
+// updateResults records the rate of the answer of the user. Rates of bots end
+// the rating of the answer like rates of players but do not count in results.
 func (game *Game) updateResults(client *Client, user uuid.UUID, value int, tags []uuid.UUID) {
 	usersQuestions := goterators.Filter(game.Round.UsersQuestions, func(item *UserQuestion) bool {
 		return item.User.Id == user
@@ -488,6 +652,9 @@ func (game *Game) updateResults(client *Client, user uuid.UUID, value int, tags
 		for i := range tags {
 			usersQuestions.Rates[client.User.Id].Tags[tags[i]] = true
 		}
+		if client.User.Bot {
+			return
+		}
 		_, ok := game.Results[user]
 		if !ok {
 			game.Results[user] = &Rates{
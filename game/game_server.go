@@ -1,29 +1,85 @@
 package game
 
 import (
+	"GameService/auth"
+	"GameService/config"
+	"GameService/consts/game_status"
+	"GameService/logging"
+	"GameService/repository/models"
 	service "GameService/repository/requests"
+	"context"
+	"errors"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"sync"
+	"time"
 )
 
 type WsServer struct {
 	clients    map[*Client]bool
 	register   chan *Client
 	unregister chan *Client
+	heartbeat  chan chan struct{}
 	games      map[*Game]bool
 	service    *service.Repository
 	generator  *JWTGenerator
+	settings   Settings
+	upgrader   websocket.Upgrader
+	// Number of connections by remote IP address.
+	connectionsByIP map[string]int
+	// Connection tickets issued at /ws/ticket.
+	tickets *ticketStore
+	// Tokens restoring identities of guests.
+	guestTokens *auth.GuestTokens
+	// Loaded games by join code.
+	joinCodes map[string]*Game
+	mutex     sync.RWMutex
+}
+
+// Settings configure connections and games of the server.
+type Settings struct {
+	WebSocket config.WebSocket
+	// Entitlements by plan type.
+	Plans map[string]models.Entitlements
+	// Either config.EntitlementsSourceConfig or config.EntitlementsSourceConnectTeam.
+	EntitlementsSource string
+	// Interval of recomputing entitlements of games in lobby.
+	EntitlementsRevalidateInterval time.Duration
+	Features                       map[string]bool
+	Bots                           config.Bots
+	RateLimit                      config.RateLimit
+	Guests                         config.Guests
+}
+
+// Stats describes current load of the server.
+type Stats struct {
+	Clients int            `json:"clients"`
+	Games   map[string]int `json:"games"`
 }
 
 // NewWebsocketServer creates a new WsServer type
-func NewWebsocketServer(service *service.Repository, generator *JWTGenerator) *WsServer {
-	return &WsServer{
-		clients:    make(map[*Client]bool),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		games:      make(map[*Game]bool),
-		service:    service,
-		generator:  generator,
+func NewWebsocketServer(service *service.Repository, generator *JWTGenerator, settings Settings) *WsServer {
+	server := &WsServer{
+		clients:         make(map[*Client]bool),
+		register:        make(chan *Client),
+		unregister:      make(chan *Client),
+		heartbeat:       make(chan chan struct{}),
+		games:           make(map[*Game]bool),
+		connectionsByIP: make(map[string]int),
+		tickets:         newTicketStore(),
+		guestTokens:     auth.NewGuestTokens(settings.Guests.SigningKey),
+		joinCodes:       make(map[string]*Game),
+		service:         service,
+		generator:       generator,
+		settings:        settings,
+	}
+	server.upgrader = websocket.Upgrader{
+		CheckOrigin:     server.checkOrigin,
+		Subprotocols:    []string{Subprotocol},
+		ReadBufferSize:  settings.WebSocket.ReadBufferSize,
+		WriteBufferSize: settings.WebSocket.WriteBufferSize,
 	}
+	return server
 }
 
 // Run our websocket server, accepting various requests
@@ -34,58 +90,147 @@ func (server *WsServer) Run() {
 			server.registerClient(client)
 		case client := <-server.unregister:
 			server.unregisterClient(client)
+		case reply := <-server.heartbeat:
+			close(reply)
 		}
 
 	}
 }
 
-func (server *WsServer) findGame(id uuid.UUID) *Game {
-	var foundGame *Game
+// UpdateSettings replaces settings of the server. New settings are applied
+// to games created and clients connected after the update.
+func (server *WsServer) UpdateSettings(settings Settings) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.settings = settings
+}
+
+func (server *WsServer) getSettings() Settings {
+	server.mutex.RLock()
+	defer server.mutex.RUnlock()
+	return server.settings
+}
+
+// Ping checks that Run loop is responsive.
+func (server *WsServer) Ping(ctx context.Context) error {
+	reply := make(chan struct{})
+	select {
+	case server.heartbeat <- reply:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-reply:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns number of connected clients and active games by status.
+func (server *WsServer) Stats() Stats {
+	server.mutex.RLock()
+	defer server.mutex.RUnlock()
+
+	stats := Stats{
+		Clients: len(server.clients),
+		Games: map[string]int{
+			game_status.GameNotStarted: 0,
+			game_status.GameInProgress: 0,
+		},
+	}
 	for game := range server.games {
-		if game.GetId() == id {
-			foundGame = game
-			return foundGame
+		status := game.getStatus()
+		if status == game_status.GameEnded {
+			continue
 		}
+		stats.Games[status]++
 	}
+	return stats
+}
 
-	dbGame, err := server.service.GetGame(id)
+func (server *WsServer) findGame(ctx context.Context, id uuid.UUID) *Game {
+	if foundGame := server.findGameByID(id); foundGame != nil {
+		return foundGame
+	}
+
+	var foundGame *Game
+	dbGame, err := server.service.GetGame(ctx, id)
+	if err != nil && !errors.Is(err, service.ErrNotFound) {
+		logging.FromContext(ctx).WithError(err).Error("cannot get game")
+	}
 
 	if err != nil || dbGame.Status == "ended" || dbGame.Id == uuid.Nil {
 		return foundGame
 	}
-	var maxSize int
-	creatorPlan, _ := server.service.GetCreatorPlan(dbGame.CreatorId)
-	switch creatorPlan.PlanType {
-	case "basic":
-		maxSize = 3
-	case "advanced":
-		maxSize = 5
-	case "premium":
-		maxSize = 10
-	}
-	foundGame = NewGame(dbGame.Name, dbGame.Id, dbGame.CreatorId, dbGame.Status, maxSize)
+	entitlements, err := server.creatorEntitlements(ctx, dbGame.CreatorId)
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).Error("cannot get game entitlements")
+		return nil
+	}
+	// Games without access policy are open. The game is not loaded until its
+	// policy is known, so joining an invite-only game does not fail open.
+	access, err := server.service.GetGameAccess(ctx, id)
+	if err != nil && !errors.Is(err, service.ErrNotFound) {
+		logging.FromContext(ctx).WithError(err).Error("cannot get game access")
+		return nil
+	}
+	code, err := newJoinCode()
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).Error("cannot generate join code")
+		return nil
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	for game := range server.games {
+		if game.GetId() == id {
+			return game
+		}
+	}
+	foundGame = NewGame(dbGame.Name, dbGame.Id, dbGame.CreatorId, dbGame.Status, entitlements)
+	foundGame.access = access
+	for server.joinCodes[code] != nil {
+		if code, err = newJoinCode(); err != nil {
+			logging.FromContext(ctx).WithError(err).Error("cannot generate join code")
+			return nil
+		}
+	}
+	foundGame.JoinCode = code
+	server.joinCodes[code] = foundGame
 	go foundGame.RunGame()
 	server.games[foundGame] = true
 	return foundGame
 }
 
 func (server *WsServer) broadcastToClients(message []byte) {
+	server.mutex.RLock()
+	defer server.mutex.RUnlock()
 	for client := range server.clients {
 		client.send <- message
 	}
 }
 
 func (server *WsServer) registerClient(client *Client) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
 	server.clients[client] = true
 }
 
 func (server *WsServer) unregisterClient(client *Client) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
 	if _, ok := server.clients[client]; ok {
 		delete(server.clients, client)
 	}
+	for game := range server.games {
+		game.leaveWaitingRoom(client)
+	}
 }
 
 func (server *WsServer) findGameByID(ID uuid.UUID) *Game {
+	server.mutex.RLock()
+	defer server.mutex.RUnlock()
 	var foundGame *Game
 	for game := range server.games {
 		if game.GetId() == ID {
@@ -98,6 +243,8 @@ func (server *WsServer) findGameByID(ID uuid.UUID) *Game {
 }
 
 func (server *WsServer) findClientByID(ID string) *Client {
+	server.mutex.RLock()
+	defer server.mutex.RUnlock()
 	var foundClient *Client
 	for client := range server.clients {
 		if client.ID.String() == ID {
@@ -0,0 +1,96 @@
+package game
+
+import (
+	"GameService/config"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// GuestIdentityAction is sent to a guest after connecting. Payload is GuestIdentity
+// with a token which restores the identity when sent to /ws in GuestTokenHeader.
+const GuestIdentityAction = "guest-identity"
+
+// Reasons of errors caused by names of guests and bots.
+const (
+	InvalidName   = "invalid-name"
+	DuplicateName = "duplicate-name"
+)
+
+// GuestIdentity identifies a guest across reconnects until it expires.
+type GuestIdentity struct {
+	Id        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// normalizeName collapses whitespace and drops control characters of the name,
+// then checks its length and blocked words.
+func normalizeName(name string, settings config.Guests) (string, error) {
+	if !utf8.ValidString(name) {
+		return "", errors.New("name is not valid UTF-8")
+	}
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return ' '
+		}
+		return r
+	}, name)
+	name = strings.Join(strings.Fields(name), " ")
+
+	length := utf8.RuneCountInString(name)
+	if length < settings.NameMinLength || length > settings.NameMaxLength {
+		return "", fmt.Errorf("name must be from %d to %d characters long",
+			settings.NameMinLength, settings.NameMaxLength)
+	}
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		for _, blocked := range settings.BlockedWords {
+			if word == strings.ToLower(blocked) {
+				return "", errors.New("name is not allowed")
+			}
+		}
+	}
+	return name, nil
+}
+
+// guestIdentity returns the identity of a new guest named name.
+func (server *WsServer) guestIdentity(name string) (*GuestIdentity, error) {
+	id := uuid.New()
+	token, expires, err := server.guestTokens.Issue(id, name, server.getSettings().Guests.TokenTTL, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &GuestIdentity{Id: id, Name: name, Token: token, ExpiresAt: expires}, nil
+}
+
+// restoreGuest returns the identity of the guest token.
+func (server *WsServer) restoreGuest(token string) (*GuestIdentity, error) {
+	claims, err := server.guestTokens.Verify(token, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &GuestIdentity{
+		Id:        claims.GuestId,
+		Name:      claims.Name,
+		Token:     token,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	}, nil
+}
+
+// hasNameOf reports whether another player of the game has the name of user ignoring case.
+func (game *Game) hasNameOf(user *User) bool {
+	for _, player := range game.Users {
+		if player.Id != user.Id && strings.EqualFold(player.Name, user.Name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package game
+
+import (
+	"GameService/auth"
+	"GameService/config"
+	"GameService/consts/plan_types"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeName(t *testing.T) {
+	settings := config.Guests{NameMinLength: 2, NameMaxLength: 8, BlockedWords: []string{"Admin"}}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		err   string
+	}{
+		{name: "plain", input: "Ann", want: "Ann"},
+		{name: "whitespace", input: "  Ann \t Lee\n", want: "Ann Lee"},
+		{name: "control characters", input: "Ann\x00\x1bLee", want: "Ann Lee"},
+		{name: "format characters", input: "A​nn‮", want: "A nn"},
+		{name: "characters not bytes", input: "Ёжик Жук", want: "Ёжик Жук"},
+		{name: "empty", input: "", err: "from 2 to 8 characters"},
+		{name: "blank", input: " \t​ ", err: "from 2 to 8 characters"},
+		{name: "too short", input: "A", err: "from 2 to 8 characters"},
+		{name: "too long", input: "Annabella", err: "from 2 to 8 characters"},
+		{name: "too long after control characters", input: "Ann\x00Leeeee", err: "from 2 to 8 characters"},
+		{name: "invalid utf-8", input: "Ann\xff", err: "UTF-8"},
+		{name: "blocked word", input: "an ADMIN", err: "not allowed"},
+		{name: "blocked word in another word", input: "Admins", want: "Admins"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeName(tt.input, settings)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("name %q, error %v, expected %q", got, err, tt.err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("name %q, error %v, expected %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGuestIdentity(t *testing.T) {
+	server, _ := newTestServer(testSettings())
+	issued, err := server.guestIdentity("Ann")
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := server.restoreGuest(issued.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Id != issued.Id || restored.Name != "Ann" || !restored.ExpiresAt.Equal(issued.ExpiresAt.Truncate(time.Second)) {
+		t.Fatalf("restored %+v, issued %+v", restored, issued)
+	}
+
+	// Tokens are signed with a random key of the server unless a key is configured.
+	other, _ := newTestServer(testSettings())
+	if _, err := other.restoreGuest(issued.Token); !errors.Is(err, auth.ErrSignature) {
+		t.Fatalf("token of another server: %v", err)
+	}
+
+	settings := testSettings()
+	settings.Guests.TokenTTL = -time.Second
+	server.UpdateSettings(settings)
+	expired, err := server.guestIdentity("Ann")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.restoreGuest(expired.Token); !errors.Is(err, auth.ErrExpired) {
+		t.Fatalf("expired token: %v", err)
+	}
+}
+
+func TestDuplicateGuestNames(t *testing.T) {
+	server, repository := newTestServer(testSettings())
+	game := newTestGame(t, server, repository, plan_types.Premium)
+	first := newTestClient(server, User{Name: "Ann"})
+	game.registerClientInGame(first)
+	if message := receive(t, first); message.Action != UserJoinedAction {
+		t.Fatalf("guest received %s", message.Action)
+	}
+
+	tests := []struct {
+		name   string
+		user   User
+		joined bool
+	}{
+		{name: "guest with the name in another case", user: User{Name: "ANN"}},
+		{name: "guest with another name", user: User{Name: "Anna"}, joined: true},
+		{name: "user with the name", user: User{Name: "ann", Authorized: true}, joined: true},
+		{name: "bot with the name", user: User{Name: "Ann", Bot: true}, joined: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(server, tt.user)
+			game.registerClientInGame(client)
+			game.mutex.Lock()
+			joined := game.hasPlayer(client.User.Id)
+			game.mutex.Unlock()
+			if joined != tt.joined {
+				t.Fatalf("joined: %v", joined)
+			}
+			if !tt.joined {
+				if err := receiveError(t, client); err.Code != 16 || err.Reason != DuplicateName {
+					t.Fatalf("error is %+v", err)
+				}
+			}
+		})
+	}
+
+	// A guest rejoining keeps the seat with their name.
+	game.registerClientInGame(newTestClient(server, *first.User))
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	if len(game.Users) != 4 {
+		t.Fatalf("players are %d, expected 4", len(game.Users))
+	}
+}
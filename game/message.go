@@ -1,9 +1,10 @@
 package game
 
 import (
+	"GameService/logging"
 	"encoding/json"
 	"github.com/google/uuid"
-	"log"
+	"github.com/sirupsen/logrus"
 	"time"
 )
 
@@ -27,6 +28,18 @@ const DeleteUserAction = "delete-user"
 const UserDeletedAction = "user-deleted"
 const UserLeftAction = "user-left"
 const GameAbortedAction = "game-abort"
+const AnnouncementAction = "announcement"
+
+// isKnownAction reports whether action can be sent by a client.
+func isKnownAction(action string) bool {
+	switch action {
+	case SendMessageAction, JoinGameAction, StartGameAction, LeaveGameAction, SelectTopicAction,
+		StartRoundAction, UserStartAnswerAction, UserEndAnswerAction, RateAction, StartStageAction,
+		EndGameAction, DeleteUserAction, AddBotAction, SetRoleAction, AdmitGuestAction:
+		return true
+	}
+	return false
+}
 
 type Message struct {
 	Action  string      `json:"action"`
@@ -54,7 +67,7 @@ func NewMessage(action string,
 func (message *Message) encode() []byte {
 	messageJson, err := json.Marshal(message)
 	if err != nil {
-		log.Println(err)
+		logrus.WithError(err).WithField(logging.ActionField, message.Action).Error("cannot encode message")
 	}
 
 	return messageJson
@@ -62,5 +75,8 @@ func (message *Message) encode() []byte {
 
 type ErrorMessage struct {
 	Code    int    `json:"code"`
+	Reason  string `json:"reason,omitempty"`
 	Message string `json:"message"`
+	// Permission missing for the action when Reason is PermissionDenied.
+	Permission string `json:"permission,omitempty"`
 }
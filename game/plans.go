@@ -0,0 +1,169 @@
+package game
+
+import (
+	"GameService/consts/game_status"
+	"GameService/logging"
+	"GameService/repository/models"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+// GameSettingsChangedAction notifies players that entitlements of the game changed.
+const GameSettingsChangedAction = "game-settings-changed"
+
+type gameSettingsPayload struct {
+	MaxSize      int                 `json:"max_size"`
+	Entitlements models.Entitlements `json:"entitlements"`
+	Topics       []Topic             `json:"topics"`
+	// Players seated above MaxSize, the latest joined. The game cannot be started
+	// until the creator removes them or they leave.
+	OverLimit []uuid.UUID `json:"over_limit,omitempty"`
+}
+
+// planChangedRequest names the user whose plan changed, or the plan type whose
+// entitlements changed, or both.
+type planChangedRequest struct {
+	UserId   uuid.UUID `json:"user_id"`
+	PlanType string    `json:"plan_type"`
+}
+
+// PlanChangeHandler receives notifications from ConnectTeam about changed plans of users.
+type PlanChangeHandler struct {
+	server *WsServer
+	apiKey string
+}
+
+// NewPlanChangeHandler creates a new PlanChangeHandler. Requests are authorized with apiKey in X-API-Key header.
+func NewPlanChangeHandler(server *WsServer, apiKey string) *PlanChangeHandler {
+	return &PlanChangeHandler{server: server, apiKey: apiKey}
+}
+
+func (h *PlanChangeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	key := r.Header.Get("X-API-Key")
+	if h.apiKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(h.apiKey)) != 1 {
+		writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	var request planChangedRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || (request.UserId == uuid.Nil && request.PlanType == "") {
+		writeJSONError(w, http.StatusBadRequest, "incorrect body")
+		return
+	}
+
+	ctx := logging.WithRequestID(r.Context(), r.Header.Get(logging.RequestIDHeader))
+	if request.PlanType != "" {
+		// Creators of games are not known by plan type, so every game in lobby is revalidated.
+		ctx := logging.WithFields(ctx, logrus.Fields{"plan_type": request.PlanType})
+		h.server.service.InvalidatePlan(request.PlanType)
+		h.server.revalidateEntitlements(ctx, func(*Game) bool { return true })
+	}
+	if request.UserId != uuid.Nil {
+		ctx := logging.WithFields(ctx, logrus.Fields{logging.UserIdField: request.UserId})
+		h.server.service.InvalidateUser(request.UserId)
+		h.server.revalidateEntitlements(ctx, func(game *Game) bool {
+			return game.getCreator() == request.UserId
+		})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevalidateEntitlements periodically recomputes entitlements of games in lobby.
+// Interval is read from settings before every run.
+func (server *WsServer) RevalidateEntitlements() {
+	for {
+		interval := server.getSettings().EntitlementsRevalidateInterval
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		time.Sleep(interval)
+
+		ctx := logging.WithRequestID(context.Background(), "")
+		server.revalidateEntitlements(ctx, func(*Game) bool { return true })
+	}
+}
+
+// revalidateEntitlements recomputes entitlements of games in lobby matching filter
+// and notifies players of games which entitlements changed. Plans are read bypassing
+// the cache, so a downgrade is applied within one interval rather than after the cache TTL.
+func (server *WsServer) revalidateEntitlements(ctx context.Context, filter func(game *Game) bool) {
+	server.mutex.RLock()
+	games := make([]*Game, 0)
+	for game := range server.games {
+		if game.getStatus() == game_status.GameNotStarted && filter(game) {
+			games = append(games, game)
+		}
+	}
+	server.mutex.RUnlock()
+
+	entitlementsByCreator := make(map[uuid.UUID]models.Entitlements)
+	for _, game := range games {
+		logger := logging.FromContext(ctx).WithField(logging.GameIdField, game.ID)
+		entitlements, ok := entitlementsByCreator[game.getCreator()]
+		if !ok {
+			var err error
+			entitlements, err = server.freshCreatorEntitlements(ctx, game.getCreator())
+			if err != nil {
+				logger.WithError(err).Warn("cannot revalidate game entitlements")
+				continue
+			}
+			entitlementsByCreator[game.getCreator()] = entitlements
+		}
+		if payload, changed := game.updateEntitlements(entitlements); changed {
+			logger.WithFields(logrus.Fields{"entitlements": entitlements, "over_limit": len(payload.OverLimit)}).
+				Info("game entitlements changed")
+			game.broadcast <- NewMessage(GameSettingsChangedAction, payload, game.ID, nil, time.Now())
+		}
+	}
+}
+
+// updateEntitlements applies entitlements to the game. Selected topics are reset
+// when they are not allowed by new entitlements.
+func (game *Game) updateEntitlements(entitlements models.Entitlements) (*gameSettingsPayload, bool) {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+
+	if game.Status != game_status.GameNotStarted || game.Entitlements == entitlements {
+		return nil, false
+	}
+	previous := game.Entitlements
+	game.Entitlements = entitlements
+	game.MaxSize = entitlements.MaxPlayers
+
+	if previous.CustomTopics != entitlements.CustomTopics ||
+		(entitlements.TopicCount > 0 && len(game.Topics) > entitlements.TopicCount) {
+		game.Topics = make([]Topic, 0)
+	}
+
+	payload := &gameSettingsPayload{
+		MaxSize:      game.MaxSize,
+		Entitlements: game.Entitlements,
+		Topics:       game.Topics,
+	}
+	for i := game.MaxSize; i < len(game.Users); i++ {
+		payload.OverLimit = append(payload.OverLimit, game.Users[i].Id)
+	}
+	return payload, true
+}
+
+// freshCreatorEntitlements is creatorEntitlements reading the plan and its entitlements
+// from ConnectTeam. Cached responses are dropped, so the next cached reads see them too.
+func (server *WsServer) freshCreatorEntitlements(ctx context.Context, creatorId uuid.UUID) (models.Entitlements, error) {
+	server.service.InvalidateUser(creatorId)
+	plan, err := server.service.GetCreatorPlan(ctx, creatorId)
+	if err != nil {
+		return models.Entitlements{}, fmt.Errorf("cannot get creator plan: %w", err)
+	}
+	server.service.InvalidatePlan(plan.PlanType)
+	return server.entitlements(ctx, plan.PlanType)
+}
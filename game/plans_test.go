@@ -0,0 +1,168 @@
+package game
+
+import (
+	"GameService/config"
+	"GameService/consts/plan_types"
+	"GameService/repository/cache"
+	"GameService/repository/fake"
+	"GameService/repository/models"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testWebhookKey = "webhook-key"
+
+// newCachedTestServer is newTestServer reading ConnectTeam through the cache.
+func newCachedTestServer(settings Settings) (*WsServer, *fake.Repository) {
+	repository := fake.New(testSigningKey)
+	entries := config.CacheEntries{TTL: time.Hour, Size: 100}
+	service := cache.Wrap(repository.Repository(), config.Cache{
+		Enabled: true, Topics: entries, Users: entries, Plans: entries, Entitlements: entries,
+	})
+	server := NewWebsocketServer(service, NewJWTGenerator("key", "secret"), settings)
+	go server.Run()
+	return server, repository
+}
+
+func postPlanChanged(handler http.Handler, key string, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/internal/plan-changed", strings.NewReader(body))
+	if key != "" {
+		request.Header.Set("X-API-Key", key)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// seatPlayers seats count players in the game and returns their clients.
+func seatPlayers(server *WsServer, game *Game, count int) []*Client {
+	clients := make([]*Client, count)
+	for i := range clients {
+		clients[i] = newTestClient(server, User{Name: "Player", Authorized: true})
+		join(game, clients[i])
+	}
+	return clients
+}
+
+func receiveSettings(t *testing.T, client *Client) gameSettingsPayload {
+	t.Helper()
+	message := receive(t, client)
+	var payload gameSettingsPayload
+	if message.Action != GameSettingsChangedAction || json.Unmarshal(message.Payload, &payload) != nil {
+		t.Fatalf("received %s %s, expected changed settings", message.Action, message.Payload)
+	}
+	return payload
+}
+
+func TestPlanChangeHandlerRejectsRequests(t *testing.T) {
+	server, _ := newTestServer(testSettings())
+	userBody := `{"user_id":"` + uuid.NewString() + `"}`
+
+	tests := []struct {
+		name   string
+		apiKey string
+		method string
+		key    string
+		body   string
+		status int
+	}{
+		{name: "method", apiKey: testWebhookKey, method: http.MethodGet, key: testWebhookKey, status: http.StatusMethodNotAllowed},
+		{name: "missing key", apiKey: testWebhookKey, method: http.MethodPost, body: userBody, status: http.StatusUnauthorized},
+		{name: "incorrect key", apiKey: testWebhookKey, method: http.MethodPost, key: "wrong", body: userBody, status: http.StatusUnauthorized},
+		{name: "webhook disabled", method: http.MethodPost, key: "", body: userBody, status: http.StatusUnauthorized},
+		{name: "malformed body", apiKey: testWebhookKey, method: http.MethodPost, key: testWebhookKey, body: `{`, status: http.StatusBadRequest},
+		{name: "empty body", apiKey: testWebhookKey, method: http.MethodPost, key: testWebhookKey, body: `{}`, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, "/internal/plan-changed", strings.NewReader(tt.body))
+			if tt.key != "" {
+				request.Header.Set("X-API-Key", tt.key)
+			}
+			recorder := httptest.NewRecorder()
+			NewPlanChangeHandler(server, tt.apiKey).ServeHTTP(recorder, request)
+			if recorder.Code != tt.status {
+				t.Fatalf("status is %d, expected %d: %s", recorder.Code, tt.status, recorder.Body)
+			}
+		})
+	}
+}
+
+func TestPlanChangeHandlerDowngradesGame(t *testing.T) {
+	server, repository := newCachedTestServer(testSettings())
+	game := newTestGame(t, server, repository, plan_types.Premium)
+	players := seatPlayers(server, game, 4)
+
+	// The premium plan is cached by loading the game, the webhook drops it.
+	repository.SetPlan(game.Creator, plan_types.Basic)
+	recorder := postPlanChanged(NewPlanChangeHandler(server, testWebhookKey), testWebhookKey,
+		`{"user_id":"`+game.Creator.String()+`"}`)
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("status is %d: %s", recorder.Code, recorder.Body)
+	}
+
+	payload := receiveSettings(t, players[0])
+	if payload.MaxSize != 3 || payload.Entitlements.MaxPlayers != 3 {
+		t.Errorf("max size is %d, entitlements %+v", payload.MaxSize, payload.Entitlements)
+	}
+	if len(payload.OverLimit) != 1 || payload.OverLimit[0] != players[3].User.Id {
+		t.Errorf("players over the limit are %v, expected the latest joined %s", payload.OverLimit, players[3].User.Id)
+	}
+}
+
+func TestPlanChangeHandlerKeepsGameOfUnknownPlan(t *testing.T) {
+	server, repository := newCachedTestServer(testSettings())
+	game := newTestGame(t, server, repository, plan_types.Advanced)
+	players := seatPlayers(server, game, 1)
+
+	repository.SetPlan(game.Creator, "enterprise")
+	recorder := postPlanChanged(NewPlanChangeHandler(server, testWebhookKey), testWebhookKey,
+		`{"user_id":"`+game.Creator.String()+`"}`)
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("status is %d: %s", recorder.Code, recorder.Body)
+	}
+	expectNothing(t, players[0], 50*time.Millisecond)
+	if game.getEntitlements() != testSettings().Plans[plan_types.Advanced] {
+		t.Fatalf("entitlements changed to %+v", game.getEntitlements())
+	}
+}
+
+func TestPlanChangeHandlerInvalidatesPlanType(t *testing.T) {
+	settings := testSettings()
+	settings.EntitlementsSource = config.EntitlementsSourceConnectTeam
+	server, repository := newCachedTestServer(settings)
+	repository.SetEntitlements(plan_types.Advanced, models.Entitlements{MaxPlayers: 5})
+	game := newTestGame(t, server, repository, plan_types.Advanced)
+	players := seatPlayers(server, game, 1)
+
+	changed := models.Entitlements{MaxPlayers: 4, Spectators: 1}
+	repository.SetEntitlements(plan_types.Advanced, changed)
+	recorder := postPlanChanged(NewPlanChangeHandler(server, testWebhookKey), testWebhookKey,
+		`{"plan_type":"`+plan_types.Advanced+`"}`)
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("status is %d: %s", recorder.Code, recorder.Body)
+	}
+	if payload := receiveSettings(t, players[0]); payload.Entitlements != changed {
+		t.Fatalf("entitlements are %+v, expected %+v", payload.Entitlements, changed)
+	}
+}
+
+func TestRevalidationBypassesCache(t *testing.T) {
+	server, repository := newCachedTestServer(testSettings())
+	game := newTestGame(t, server, repository, plan_types.Premium)
+	players := seatPlayers(server, game, 1)
+
+	// No webhook is received, the cached premium plan must not hide the downgrade.
+	repository.SetPlan(game.Creator, plan_types.Advanced)
+	server.revalidateEntitlements(context.Background(), func(*Game) bool { return true })
+	if payload := receiveSettings(t, players[0]); payload.MaxSize != 5 || len(payload.OverLimit) != 0 {
+		t.Fatalf("settings are %+v", payload)
+	}
+}
@@ -0,0 +1,145 @@
+package game
+
+import (
+	"GameService/logging"
+	"GameService/repository/models"
+	service "GameService/repository/requests"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"sync"
+)
+
+// questionPool holds random questions fetched for selected topics before the game starts.
+type questionPool struct {
+	mutex     sync.Mutex
+	questions map[uuid.UUID][]models.Question
+}
+
+// fill fetches questions of topics having less than count questions and returns count
+// questions of every topic. Errors of all topics are combined. The pool is not locked
+// while questions are fetched, so concurrent fills of the game do not wait for ConnectTeam.
+func (pool *questionPool) fill(ctx context.Context, repo service.Topic, topicIds []uuid.UUID, count int) (map[uuid.UUID][]models.Question, error) {
+	var errs []error
+	if missing := pool.missing(topicIds, count); len(missing) > 0 {
+		fetched, err := repo.GetRandQuestionsPerTopic(ctx, missing, count)
+		pool.merge(fetched)
+		errs = append(errs, err)
+	}
+
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	result := make(map[uuid.UUID][]models.Question, len(topicIds))
+	for _, topicId := range topicIds {
+		questions := pool.questions[topicId]
+		if len(questions) < count {
+			errs = append(errs, fmt.Errorf("topic %s: %d of %d questions", topicId, len(questions), count))
+			continue
+		}
+		result[topicId] = questions[:count]
+	}
+	return result, errors.Join(errs...)
+}
+
+// missing drops questions of topics which are not selected anymore and returns
+// selected topics having less than count questions.
+func (pool *questionPool) missing(topicIds []uuid.UUID, count int) []uuid.UUID {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	selected := make(map[uuid.UUID]bool, len(topicIds))
+	for _, topicId := range topicIds {
+		selected[topicId] = true
+	}
+	if pool.questions == nil {
+		pool.questions = make(map[uuid.UUID][]models.Question)
+	}
+	for topicId := range pool.questions {
+		if !selected[topicId] {
+			delete(pool.questions, topicId)
+		}
+	}
+
+	var missing []uuid.UUID
+	for _, topicId := range topicIds {
+		if len(pool.questions[topicId]) < count {
+			missing = append(missing, topicId)
+		}
+	}
+	return missing
+}
+
+// merge adds fetched questions to the pool.
+func (pool *questionPool) merge(fetched map[uuid.UUID][]models.Question) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	for topicId, questions := range fetched {
+		pool.questions[topicId] = mergeQuestions(pool.questions[topicId], questions)
+	}
+}
+
+// mergeQuestions appends questions which are not present yet.
+func mergeQuestions(questions []models.Question, fetched []models.Question) []models.Question {
+	present := make(map[uuid.UUID]bool, len(questions))
+	for i := range questions {
+		present[questions[i].Id] = true
+	}
+	for i := range fetched {
+		if !present[fetched[i].Id] {
+			present[fetched[i].Id] = true
+			questions = append(questions, fetched[i])
+		}
+	}
+	return questions
+}
+
+// prefetchQuestions fills question pool of the game in background so that
+// starting the game does not wait for ConnectTeam.
+func (game *Game) prefetchQuestions(server *WsServer, topicIds []uuid.UUID, count int) {
+	if len(topicIds) == 0 || count == 0 {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(game.ctx, server.getSettings().WebSocket.ActionTimeout)
+		defer cancel()
+		ctx = logging.WithRequestID(ctx, "")
+		ctx = logging.WithFields(ctx, logrus.Fields{logging.GameIdField: game.ID})
+		if _, err := game.questions.fill(ctx, server.service, topicIds, count); err != nil {
+			logging.FromContext(ctx).WithError(err).Warn("cannot prefetch questions")
+		}
+	}()
+}
+
+// topicIds returns IDs of selected topics. It requires the game mutex to be held.
+func (game *Game) topicIds() []uuid.UUID {
+	topicIds := make([]uuid.UUID, len(game.Topics))
+	for i := range game.Topics {
+		topicIds[i] = game.Topics[i].Id
+	}
+	return topicIds
+}
+
+// questionDemand returns selected topics and number of questions needed per topic.
+func (game *Game) questionDemand() ([]uuid.UUID, int) {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	return game.topicIds(), len(game.Users)
+}
+
+func newQuestion(question models.Question) Question {
+	tags := make([]Tag, len(question.Tags))
+	for i := range question.Tags {
+		tags[i] = Tag{
+			Id:   question.Tags[i].Id,
+			Name: question.Tags[i].Name,
+		}
+	}
+	return Question{
+		Id:      question.Id,
+		TopicId: question.TopicId,
+		Content: question.Content,
+		Tags:    tags,
+	}
+}
@@ -0,0 +1,108 @@
+package game
+
+import (
+	"GameService/repository/models"
+	service "GameService/repository/requests"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+// blockingTopics returns questions of fetched topics after release is closed.
+type blockingTopics struct {
+	service.Topic
+	fetching chan []uuid.UUID
+	release  chan struct{}
+}
+
+func (r *blockingTopics) GetRandQuestionsPerTopic(ctx context.Context, topicIds []uuid.UUID, limit int) (map[uuid.UUID][]models.Question, error) {
+	r.fetching <- topicIds
+	<-r.release
+	questions := make(map[uuid.UUID][]models.Question, len(topicIds))
+	for _, topicId := range topicIds {
+		for i := 0; i < limit; i++ {
+			questions[topicId] = append(questions[topicId], models.Question{Id: uuid.New(), TopicId: topicId})
+		}
+	}
+	return questions, nil
+}
+
+func TestQuestionPoolIsNotLockedWhileFetching(t *testing.T) {
+	repo := &blockingTopics{fetching: make(chan []uuid.UUID, 1), release: make(chan struct{})}
+	pool := &questionPool{}
+	topic := uuid.New()
+
+	filled := make(chan error)
+	go func() {
+		_, err := pool.fill(context.Background(), repo, []uuid.UUID{topic}, 2)
+		filled <- err
+	}()
+	if fetched := <-repo.fetching; len(fetched) != 1 || fetched[0] != topic {
+		t.Fatalf("fetched topics %v, expected %s", fetched, topic)
+	}
+	if !pool.mutex.TryLock() {
+		t.Fatal("pool is locked while questions are fetched")
+	}
+	pool.mutex.Unlock()
+
+	close(repo.release)
+	select {
+	case err := <-filled:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("fill has not returned")
+	}
+}
+
+func TestQuestionPoolFill(t *testing.T) {
+	repo := &blockingTopics{fetching: make(chan []uuid.UUID, 2), release: make(chan struct{})}
+	close(repo.release)
+	pool := &questionPool{}
+	first, second := uuid.New(), uuid.New()
+	ctx := context.Background()
+
+	questions, err := pool.fill(ctx, repo, []uuid.UUID{first}, 2)
+	if err != nil || len(questions[first]) != 2 {
+		t.Fatalf("filled %d questions: %v", len(questions[first]), err)
+	}
+	<-repo.fetching
+
+	// Questions of the first topic are kept, only the second topic is fetched.
+	questions, err = pool.fill(ctx, repo, []uuid.UUID{first, second}, 2)
+	if err != nil || len(questions[first]) != 2 || len(questions[second]) != 2 {
+		t.Fatalf("filled %d and %d questions: %v", len(questions[first]), len(questions[second]), err)
+	}
+	if fetched := <-repo.fetching; len(fetched) != 1 || fetched[0] != second {
+		t.Fatalf("fetched topics %v, expected %s", fetched, second)
+	}
+
+	// Deselected topics are dropped.
+	if _, err = pool.fill(ctx, repo, []uuid.UUID{second}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pool.questions[first]; ok {
+		t.Fatal("questions of a deselected topic are kept")
+	}
+}
+
+// failingTopics fails to fetch questions.
+type failingTopics struct {
+	service.Topic
+}
+
+func (failingTopics) GetRandQuestionsPerTopic(context.Context, []uuid.UUID, int) (map[uuid.UUID][]models.Question, error) {
+	return nil, service.ErrNotFound
+}
+
+func TestQuestionPoolFillReportsMissingQuestions(t *testing.T) {
+	pool := &questionPool{}
+	topic := uuid.New()
+	questions, err := pool.fill(context.Background(), failingTopics{}, []uuid.UUID{topic}, 1)
+	if !errors.Is(err, service.ErrNotFound) || len(questions) != 0 {
+		t.Fatalf("filled %v: %v", questions, err)
+	}
+}
@@ -0,0 +1,171 @@
+package game
+
+import (
+	"GameService/config"
+	"GameService/metrics"
+	"fmt"
+	"golang.org/x/time/rate"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// RateLimited is a reason of errors sent to clients exceeding rate limits.
+const RateLimited = "rate-limited"
+
+// limiter applies rate limits to messages of a client and escalates penalties of
+// a client exceeding them from warnings to mutes to disconnect. It is used by
+// the read loop of the client only.
+type limiter struct {
+	settings   config.RateLimit
+	messages   *rate.Limiter
+	actions    map[string]*rate.Limiter
+	violations int
+	mutes      int
+	mutedUntil time.Time
+}
+
+func newLimiter(settings config.RateLimit) *limiter {
+	if !settings.Enabled {
+		return nil
+	}
+	l := &limiter{
+		settings: settings,
+		messages: rate.NewLimiter(rate.Limit(settings.Messages.Rate), settings.Messages.Burst),
+		actions:  make(map[string]*rate.Limiter, len(settings.Actions)),
+	}
+	for action, limit := range settings.Actions {
+		l.actions[action] = rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst)
+	}
+	return l
+}
+
+// allow reports whether the message with action is handled. Otherwise it
+// returns the penalty of the client, one of metrics.Penalty constants.
+func (l *limiter) allow(action string, now time.Time) (bool, string) {
+	if l == nil {
+		return true, ""
+	}
+	if now.Before(l.mutedUntil) {
+		return false, metrics.PenaltyMuted
+	}
+	allowed := l.messages.AllowN(now, 1)
+	if actionLimiter, ok := l.actions[action]; ok && allowed {
+		allowed = actionLimiter.AllowN(now, 1)
+	}
+	if allowed {
+		return true, ""
+	}
+
+	l.violations++
+	if l.violations <= l.settings.Warnings {
+		return false, metrics.PenaltyWarning
+	}
+	l.violations = 0
+	l.mutes++
+	if l.mutes > l.settings.MutesBeforeDisconnect {
+		return false, metrics.PenaltyDisconnect
+	}
+	l.mutedUntil = now.Add(l.settings.MuteDuration)
+	return false, metrics.PenaltyMute
+}
+
+// throttle applies rate limits to the message and reports whether it should be handled.
+// It returns false with disconnect set when the client must be disconnected.
+func (client *Client) throttle(message Message) (handle bool, disconnect bool) {
+	allowed, penalty := client.limiter.allow(message.Action, time.Now())
+	if allowed {
+		return true, false
+	}
+	action := message.Action
+	if !isKnownAction(action) {
+		action = "unknown"
+	}
+	metrics.ThrottledMessages.WithLabelValues(action, penalty).Inc()
+	if penalty == metrics.PenaltyMuted {
+		return false, false
+	}
+	metrics.ThrottledClients.WithLabelValues(penalty).Inc()
+	logger := client.logger().WithField("action", message.Action)
+
+	switch penalty {
+	case metrics.PenaltyWarning:
+		logger.Debug("message throttled")
+		client.notifyClient(NewMessage(Error, ErrorMessage{
+			Code:    15,
+			Reason:  RateLimited,
+			Message: "too many messages, slow down",
+		}, message.Target, nil, time.Now()))
+	case metrics.PenaltyMute:
+		duration := client.limiter.settings.MuteDuration
+		logger.Warn("client muted")
+		metrics.MutedClients.Inc()
+		time.AfterFunc(duration, metrics.MutedClients.Dec)
+		client.notifyClient(NewMessage(Error, ErrorMessage{
+			Code:    15,
+			Reason:  RateLimited,
+			Message: fmt.Sprintf("too many messages, messages are ignored for %s", duration),
+		}, message.Target, nil, time.Now()))
+	case metrics.PenaltyDisconnect:
+		logger.Warn("client disconnected for exceeding rate limits")
+		return false, true
+	}
+	return false, false
+}
+
+// remoteIP returns IP address of the client of the request. A request from a
+// trusted proxy is attributed to the last address of X-Forwarded-For which is
+// not a trusted proxy, as addresses left of it can be forged by the client.
+func remoteIP(r *http.Request, trustedProxies []string) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0 && isTrustedProxy(ip, trustedProxies); i-- {
+		next := strings.TrimSpace(forwarded[i])
+		if _, err := netip.ParseAddr(next); err != nil {
+			break
+		}
+		ip = next
+	}
+	return ip
+}
+
+func isTrustedProxy(ip string, trustedProxies []string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	for _, proxy := range trustedProxies {
+		if prefix, err := config.ParseProxy(proxy); err == nil && prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// acquireConnection reserves a connection from ip and reports whether the
+// limit of connections from the address is not reached.
+func (server *WsServer) acquireConnection(ip string) bool {
+	settings := server.getSettings().RateLimit
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if settings.Enabled && settings.ConnectionsPerIP > 0 && server.connectionsByIP[ip] >= settings.ConnectionsPerIP {
+		return false
+	}
+	server.connectionsByIP[ip]++
+	return true
+}
+
+// releaseConnection frees a connection reserved with acquireConnection.
+func (server *WsServer) releaseConnection(ip string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.connectionsByIP[ip]--
+	if server.connectionsByIP[ip] <= 0 {
+		delete(server.connectionsByIP, ip)
+	}
+}
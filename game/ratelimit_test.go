@@ -0,0 +1,120 @@
+package game
+
+import (
+	"GameService/config"
+	"GameService/metrics"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRemoteIP(t *testing.T) {
+	trusted := []string{"10.0.0.0/8", "2001:db8::/32", "192.0.2.1"}
+
+	tests := []struct {
+		name      string
+		peer      string
+		forwarded []string
+		want      string
+	}{
+		{name: "direct", peer: "203.0.113.5:1234", want: "203.0.113.5"},
+		{name: "untrusted peer", peer: "203.0.113.5:1234", forwarded: []string{"198.51.100.1"}, want: "203.0.113.5"},
+		{name: "trusted proxy", peer: "10.0.0.1:1234", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "trusted address", peer: "192.0.2.1:1234", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "trusted proxy without header", peer: "10.0.0.1:1234", want: "10.0.0.1"},
+		{name: "multiple hops", peer: "10.0.0.1:1234", forwarded: []string{"198.51.100.1, 10.0.0.3, 10.0.0.2"}, want: "198.51.100.1"},
+		{name: "forged hops", peer: "10.0.0.1:1234", forwarded: []string{"10.0.0.9, 1.1.1.1, 198.51.100.1"}, want: "198.51.100.1"},
+		{name: "multiple headers", peer: "10.0.0.1:1234", forwarded: []string{"1.1.1.1", "198.51.100.1, 10.0.0.2"}, want: "198.51.100.1"},
+		{name: "malformed header", peer: "10.0.0.1:1234", forwarded: []string{"unknown"}, want: "10.0.0.1"},
+		{name: "malformed hop", peer: "10.0.0.1:1234", forwarded: []string{"198.51.100.1, bad, 10.0.0.2"}, want: "10.0.0.2"},
+		{name: "hop with port", peer: "10.0.0.1:1234", forwarded: []string{"198.51.100.1:80"}, want: "10.0.0.1"},
+		{name: "ipv6 peer", peer: "[2a00:1450::1]:443", forwarded: []string{"198.51.100.1"}, want: "2a00:1450::1"},
+		{name: "ipv6 proxy", peer: "[2001:db8::1]:443", forwarded: []string{"2a00:1450::5"}, want: "2a00:1450::5"},
+		{name: "ipv4-mapped proxy", peer: "[::ffff:10.0.0.1]:443", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "peer without port", peer: "10.0.0.1", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest("GET", "/ws", nil)
+			request.RemoteAddr = tt.peer
+			for _, value := range tt.forwarded {
+				request.Header.Add("X-Forwarded-For", value)
+			}
+			if got := remoteIP(request, trusted); got != tt.want {
+				t.Fatalf("remote IP is %s, expected %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAcquireConnection(t *testing.T) {
+	settings := testSettings()
+	settings.RateLimit = config.RateLimit{Enabled: true, ConnectionsPerIP: 2}
+	server, _ := newTestServer(settings)
+
+	for i := 0; i < 2; i++ {
+		if !server.acquireConnection("198.51.100.1") {
+			t.Fatalf("connection %d is refused", i+1)
+		}
+	}
+	if server.acquireConnection("198.51.100.1") {
+		t.Fatal("connection over the limit is accepted")
+	}
+	if !server.acquireConnection("2a00:1450::1") {
+		t.Fatal("connection from another address is refused")
+	}
+	server.releaseConnection("198.51.100.1")
+	if !server.acquireConnection("198.51.100.1") {
+		t.Fatal("released connection is not reusable")
+	}
+
+	// Connections are counted but not limited when the limit is disabled.
+	settings.RateLimit.Enabled = false
+	server.UpdateSettings(settings)
+	if !server.acquireConnection("198.51.100.1") {
+		t.Fatal("connection is refused without the limit")
+	}
+	for i := 0; i < 4; i++ {
+		server.releaseConnection("198.51.100.1")
+	}
+	server.releaseConnection("2a00:1450::1")
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if len(server.connectionsByIP) != 0 {
+		t.Fatalf("connections are left: %v", server.connectionsByIP)
+	}
+}
+
+func TestLimiterEscalates(t *testing.T) {
+	l := newLimiter(config.RateLimit{
+		Enabled:               true,
+		Messages:              config.Limit{Rate: 1, Burst: 1},
+		Warnings:              1,
+		MuteDuration:          time.Minute,
+		MutesBeforeDisconnect: 1,
+	})
+	now := time.Now()
+	steps := []struct {
+		after   time.Duration
+		allowed bool
+		penalty string
+	}{
+		{allowed: true},
+		{penalty: metrics.PenaltyWarning},
+		{penalty: metrics.PenaltyMute},
+		{after: 30 * time.Second, penalty: metrics.PenaltyMuted},
+		{after: time.Minute, allowed: true},
+		{after: time.Minute, penalty: metrics.PenaltyWarning},
+		{after: time.Minute, penalty: metrics.PenaltyDisconnect},
+	}
+	for i, step := range steps {
+		allowed, penalty := l.allow(SendMessageAction, now.Add(step.after))
+		if allowed != step.allowed || penalty != step.penalty {
+			t.Fatalf("message %d: allowed %v with penalty %q, expected %v with %q", i+1, allowed, penalty, step.allowed, step.penalty)
+		}
+	}
+	if newLimiter(config.RateLimit{}) != nil {
+		t.Fatal("disabled limiter is created")
+	}
+}
@@ -0,0 +1,263 @@
+package game
+
+import (
+	"GameService/consts/game_status"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// SetRoleAction changes the role of a player. Payload is roleChange, the
+// message is broadcast to the game on success.
+const SetRoleAction = "set-role"
+
+// PermissionDenied is the reason of errors of actions the role of the client does not permit.
+const PermissionDenied = "permission-denied"
+
+// Role of a client in a game.
+type Role string
+
+const (
+	// RoleAdmin is a moderator connected with an admin token. It joins any game
+	// without taking a seat and manages it like the creator.
+	RoleAdmin   Role = "admin"
+	RoleCreator Role = "creator"
+	// RoleCoHost is a player promoted by the creator to help running the game.
+	RoleCoHost Role = "co-host"
+	RolePlayer Role = "player"
+	// RoleSpectator watches the game without playing, see models.Entitlements.Spectators.
+	RoleSpectator Role = "spectator"
+	// RoleGuest is a player connected without a user token.
+	RoleGuest Role = "guest"
+)
+
+// Permission allows actions in a game.
+type Permission string
+
+const (
+	PermissionChat         Permission = "chat"
+	PermissionAnswer       Permission = "answer"
+	PermissionRate         Permission = "rate"
+	PermissionSelectTopics Permission = "select-topics"
+	PermissionStartGame    Permission = "start-game"
+	PermissionRunRounds    Permission = "run-rounds"
+	PermissionEndGame      Permission = "end-game"
+	PermissionKick         Permission = "kick"
+	PermissionAddBots      Permission = "add-bots"
+	PermissionAssignRoles  Permission = "assign-roles"
+	PermissionAdmitGuests  Permission = "admit-guests"
+	PermissionExport       Permission = "export-results"
+)
+
+// permissions is the permission matrix of roles.
+var permissions = map[Role][]Permission{
+	RoleAdmin: {PermissionChat, PermissionSelectTopics, PermissionStartGame, PermissionRunRounds,
+		PermissionEndGame, PermissionKick, PermissionAddBots, PermissionAssignRoles, PermissionAdmitGuests,
+		PermissionExport},
+	RoleCreator: {PermissionChat, PermissionAnswer, PermissionRate, PermissionSelectTopics, PermissionStartGame,
+		PermissionRunRounds, PermissionEndGame, PermissionKick, PermissionAddBots, PermissionAssignRoles,
+		PermissionAdmitGuests, PermissionExport},
+	RoleCoHost: {PermissionChat, PermissionAnswer, PermissionRate, PermissionSelectTopics, PermissionStartGame,
+		PermissionRunRounds, PermissionKick, PermissionAddBots, PermissionAdmitGuests, PermissionExport},
+	RolePlayer:    {PermissionChat, PermissionAnswer, PermissionRate},
+	RoleGuest:     {PermissionChat, PermissionAnswer, PermissionRate},
+	RoleSpectator: {PermissionChat},
+}
+
+// actionPermissions maps actions to the permission they require. Other actions,
+// like join-game and leave-game, are allowed to every client.
+var actionPermissions = map[string]Permission{
+	SendMessageAction:     PermissionChat,
+	UserStartAnswerAction: PermissionAnswer,
+	UserEndAnswerAction:   PermissionAnswer,
+	RateAction:            PermissionRate,
+	SelectTopicAction:     PermissionSelectTopics,
+	StartGameAction:       PermissionStartGame,
+	StartRoundAction:      PermissionRunRounds,
+	StartStageAction:      PermissionRunRounds,
+	EndGameAction:         PermissionEndGame,
+	DeleteUserAction:      PermissionKick,
+	AddBotAction:          PermissionAddBots,
+	SetRoleAction:         PermissionAssignRoles,
+	AdmitGuestAction:      PermissionAdmitGuests,
+	ExportResultsAction:   PermissionExport,
+}
+
+// Can reports whether the role has the permission.
+func (role Role) Can(permission Permission) bool {
+	for _, granted := range permissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// roleChange is the payload of set-role.
+type roleChange struct {
+	UserId uuid.UUID `json:"user_id"`
+	Role   Role      `json:"role"`
+}
+
+// roleOf returns the role of the client in the game. It fails for clients which
+// neither joined the game nor created it.
+func (game *Game) roleOf(client *Client) (Role, bool) {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	return game.role(client.User)
+}
+
+// role returns the role of the user in the game. game.mutex must be held.
+func (game *Game) role(user *User) (Role, bool) {
+	switch {
+	case user.Admin:
+		return RoleAdmin, true
+	case user.Id == game.Creator:
+		return RoleCreator, true
+	case game.spectators[user.Id]:
+		return RoleSpectator, true
+	}
+	for _, player := range game.Users {
+		if player.Id != user.Id {
+			continue
+		}
+		switch {
+		case game.isCoHost(user.Id):
+			return RoleCoHost, true
+		case !user.Authorized && !user.Bot:
+			return RoleGuest, true
+		}
+		return RolePlayer, true
+	}
+	return "", false
+}
+
+func (game *Game) isCoHost(userId uuid.UUID) bool {
+	for _, id := range game.CoHosts {
+		if id == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize checks that the role of the client permits the action and
+// notifies the client about the missing permission otherwise.
+func (game *Game) authorize(client *Client, action string) bool {
+	permission, ok := actionPermissions[action]
+	if !ok {
+		return true
+	}
+	role, member := game.roleOf(client)
+	if member && role.Can(permission) {
+		return true
+	}
+	message := fmt.Sprintf("permission denied: %s requires %q which %s does not have", action, permission, role)
+	if !member {
+		message = fmt.Sprintf("permission denied: %s requires %q, join the game first", action, permission)
+	}
+	client.notifyClient(NewMessage(Error, ErrorMessage{
+		Code:       8,
+		Reason:     PermissionDenied,
+		Message:    message,
+		Permission: string(permission),
+	}, game.ID, nil, time.Now()))
+	return false
+}
+
+// authorizeRemoval checks that the sender may remove the user from the game and
+// notifies the sender otherwise. Admins are never removed, and co-hosts are
+// removed only by the creator and admins.
+func (game *Game) authorizeRemoval(sender *Client, userId uuid.UUID) bool {
+	game.mutex.Lock()
+	senderRole, _ := game.role(sender.User)
+	var targetRole Role
+	for client := range game.Clients {
+		if client.User.Id == userId {
+			targetRole, _ = game.role(client.User)
+			break
+		}
+	}
+	if targetRole == "" {
+		for _, player := range game.Users {
+			if player.Id == userId {
+				targetRole, _ = game.role(player)
+				break
+			}
+		}
+	}
+	game.mutex.Unlock()
+
+	switch {
+	case targetRole == RoleAdmin:
+	case targetRole == RoleCoHost && senderRole != RoleCreator && senderRole != RoleAdmin:
+	default:
+		return true
+	}
+	sender.notifyClient(NewMessage(Error, ErrorMessage{
+		Code:       8,
+		Reason:     PermissionDenied,
+		Message:    fmt.Sprintf("permission denied: %s cannot remove %s", senderRole, targetRole),
+		Permission: string(PermissionKick),
+	}, game.ID, nil, time.Now()))
+	return false
+}
+
+// handleSetRoleMessage promotes a player to co-host or demotes a co-host to player.
+func (client *Client) handleSetRoleMessage(ctx context.Context, message Message) {
+	game := client.wsServer.findGame(ctx, message.Target)
+	if game == nil {
+		client.notifyClient(NewMessage(Error, ErrorMessage{
+			Code:    2,
+			Message: fmt.Sprintf("game %s is not found", message.Target),
+		}, message.Target, nil, time.Now()))
+		return
+	}
+	if !game.authorize(client, message.Action) {
+		return
+	}
+
+	var change roleChange
+	payload, err := json.Marshal(message.Payload)
+	if err == nil {
+		err = json.Unmarshal(payload, &change)
+	}
+	if err != nil || (change.Role != RoleCoHost && change.Role != RolePlayer) {
+		client.notifyClient(NewMessage(Error, ErrorMessage{
+			Code:    9,
+			Message: "incorrect payload: role must be co-host or player",
+		}, game.ID, nil, time.Now()))
+		return
+	}
+
+	game.mutex.Lock()
+	var target *User
+	for _, player := range game.Users {
+		if player.Id == change.UserId {
+			target = player
+		}
+	}
+	if target == nil || !target.Authorized || target.Id == game.Creator || game.Status == game_status.GameEnded {
+		game.mutex.Unlock()
+		client.notifyClient(NewMessage(Error, ErrorMessage{
+			Code:    9,
+			Message: fmt.Sprintf("role of user %s cannot be changed", change.UserId),
+		}, game.ID, nil, time.Now()))
+		return
+	}
+	coHosts := make([]uuid.UUID, 0, len(game.CoHosts)+1)
+	for _, id := range game.CoHosts {
+		if id != change.UserId {
+			coHosts = append(coHosts, id)
+		}
+	}
+	if change.Role == RoleCoHost {
+		coHosts = append(coHosts, change.UserId)
+	}
+	game.CoHosts = coHosts
+	game.mutex.Unlock()
+
+	game.broadcast <- NewMessage(SetRoleAction, change, game.ID, client.User, time.Now())
+}
@@ -0,0 +1,126 @@
+package game
+
+import (
+	"GameService/consts/plan_types"
+	"testing"
+)
+
+// rolesGame returns a game with a client of every role, keyed by role.
+func rolesGame(t *testing.T) (*Game, map[Role]*Client) {
+	t.Helper()
+	server, repository := newTestServer(testSettings())
+	game := newTestGame(t, server, repository, plan_types.Premium)
+	clients := map[Role]*Client{
+		RoleAdmin:   newTestClient(server, User{Name: "Moderator", Authorized: true, Admin: true}),
+		RoleCreator: newTestClient(server, User{Id: game.Creator, Name: "Host", Authorized: true}),
+		RoleCoHost:  newTestClient(server, User{Name: "Cohost", Authorized: true}),
+		RolePlayer:  newTestClient(server, User{Name: "Player", Authorized: true}),
+		RoleGuest:   newTestClient(server, User{Name: "Guest"}),
+	}
+	for _, role := range []Role{RoleCoHost, RolePlayer, RoleGuest} {
+		join(game, clients[role])
+	}
+	clients[RoleSpectator] = newTestClient(server, User{Name: "Watcher"})
+	game.mutex.Lock()
+	game.CoHosts = append(game.CoHosts, clients[RoleCoHost].User.Id)
+	game.spectators[clients[RoleSpectator].User.Id] = true
+	game.Clients[clients[RoleSpectator]] = true
+	game.mutex.Unlock()
+	return game, clients
+}
+
+func TestRoleOf(t *testing.T) {
+	game, clients := rolesGame(t)
+	for role, client := range clients {
+		if got, member := game.roleOf(client); !member || got != role {
+			t.Errorf("%s: role is %q, member %v", client.User.Name, got, member)
+		}
+	}
+	bot := newTestClient(clients[RoleCreator].wsServer, User{Name: "Bot", Bot: true})
+	join(game, bot)
+	if role, _ := game.roleOf(bot); role != RolePlayer {
+		t.Errorf("bot role is %q, expected %q", role, RolePlayer)
+	}
+	if role, member := game.roleOf(newTestClient(bot.wsServer, User{Name: "Stranger", Authorized: true})); member {
+		t.Errorf("stranger has role %q", role)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	game, clients := rolesGame(t)
+	stranger := newTestClient(clients[RoleCreator].wsServer, User{Name: "Stranger", Authorized: true})
+
+	tests := []struct {
+		name    string
+		client  *Client
+		action  string
+		allowed bool
+	}{
+		{name: "creator ends game", client: clients[RoleCreator], action: EndGameAction, allowed: true},
+		{name: "admin ends game", client: clients[RoleAdmin], action: EndGameAction, allowed: true},
+		{name: "co-host ends game", client: clients[RoleCoHost], action: EndGameAction},
+		{name: "player ends game", client: clients[RolePlayer], action: EndGameAction},
+		{name: "co-host starts game", client: clients[RoleCoHost], action: StartGameAction, allowed: true},
+		{name: "co-host selects topics", client: clients[RoleCoHost], action: SelectTopicAction, allowed: true},
+		{name: "co-host assigns roles", client: clients[RoleCoHost], action: SetRoleAction},
+		{name: "co-host kicks", client: clients[RoleCoHost], action: DeleteUserAction, allowed: true},
+		{name: "player selects topics", client: clients[RolePlayer], action: SelectTopicAction},
+		{name: "guest answers", client: clients[RoleGuest], action: UserStartAnswerAction, allowed: true},
+		{name: "guest admits guests", client: clients[RoleGuest], action: AdmitGuestAction},
+		{name: "admin rates", client: clients[RoleAdmin], action: RateAction},
+		{name: "spectator chats", client: clients[RoleSpectator], action: SendMessageAction, allowed: true},
+		{name: "spectator answers", client: clients[RoleSpectator], action: UserStartAnswerAction},
+		{name: "player exports", client: clients[RolePlayer], action: ExportResultsAction},
+		{name: "stranger chats", client: stranger, action: SendMessageAction},
+		{name: "stranger joins", client: stranger, action: JoinGameAction, allowed: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if allowed := game.authorize(tt.client, tt.action); allowed != tt.allowed {
+				t.Fatalf("allowed: %v", allowed)
+			}
+			if tt.allowed {
+				expectNothing(t, tt.client, 0)
+				return
+			}
+			denied := receiveError(t, tt.client)
+			if denied.Code != 8 || denied.Reason != PermissionDenied || denied.Permission != string(actionPermissions[tt.action]) {
+				t.Fatalf("error is %+v", denied)
+			}
+		})
+	}
+}
+
+func TestAuthorizeRemoval(t *testing.T) {
+	game, clients := rolesGame(t)
+	join(game, clients[RoleAdmin])
+
+	tests := []struct {
+		name    string
+		sender  Role
+		target  Role
+		allowed bool
+	}{
+		{name: "creator removes co-host", sender: RoleCreator, target: RoleCoHost, allowed: true},
+		{name: "admin removes co-host", sender: RoleAdmin, target: RoleCoHost, allowed: true},
+		{name: "co-host removes co-host", sender: RoleCoHost, target: RoleCoHost},
+		{name: "co-host removes player", sender: RoleCoHost, target: RolePlayer, allowed: true},
+		{name: "co-host removes guest", sender: RoleCoHost, target: RoleGuest, allowed: true},
+		{name: "creator removes admin", sender: RoleCreator, target: RoleAdmin},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			sender := clients[tt.sender]
+			if allowed := game.authorizeRemoval(sender, clients[tt.target].User.Id); allowed != tt.allowed {
+				t.Fatalf("allowed: %v", allowed)
+			}
+			if !tt.allowed {
+				if denied := receiveError(t, sender); denied.Code != 8 || denied.Permission != string(PermissionKick) {
+					t.Fatalf("error is %+v", denied)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package game
+
+import (
+	"GameService/config"
+	"GameService/consts/plan_types"
+	"GameService/repository/fake"
+	"GameService/repository/models"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+const testSigningKey = "game-test-signing-key"
+
+// testSettings returns settings of servers created by newTestServer.
+func testSettings() Settings {
+	return Settings{
+		WebSocket: config.WebSocket{
+			WriteWait:       10 * time.Second,
+			PongWait:        60 * time.Second,
+			PingPeriod:      54 * time.Second,
+			MaxMessageSize:  10000,
+			ReadBufferSize:  4096,
+			WriteBufferSize: 4096,
+			SendBufferSize:  16,
+			ActionTimeout:   5 * time.Second,
+			TicketTTL:       time.Minute,
+		},
+		Plans: map[string]models.Entitlements{
+			plan_types.Basic:    {MaxPlayers: 3, TopicCount: 1},
+			plan_types.Advanced: {MaxPlayers: 5, CustomTopics: true, Spectators: 2, Timers: true, Exports: true},
+			plan_types.Premium:  {MaxPlayers: 10, CustomTopics: true, Spectators: 10, Timers: true, Exports: true},
+		},
+		EntitlementsSource:             config.EntitlementsSourceConfig,
+		EntitlementsRevalidateInterval: time.Hour,
+		Guests:                         config.Guests{TokenTTL: time.Hour, NameMinLength: 2, NameMaxLength: 32},
+		Bots:                           config.Bots{MaxPerGame: 2, AnswerDelay: time.Millisecond, RateDelay: time.Millisecond},
+	}
+}
+
+// newTestServer runs a game server backed by the fake repository.
+func newTestServer(settings Settings) (*WsServer, *fake.Repository) {
+	repository := fake.New(testSigningKey)
+	server := NewWebsocketServer(repository.Repository(), NewJWTGenerator("key", "secret"), settings)
+	go server.Run()
+	return server, repository
+}
+
+// newTestGame loads a new game created by a user of the plan.
+func newTestGame(t *testing.T, server *WsServer, repository *fake.Repository, planType string) *Game {
+	t.Helper()
+	creator := repository.AddUser("Host", planType)
+	stored := repository.AddGame("test", creator.Id)
+	game := server.findGame(context.Background(), stored.Id)
+	if game == nil {
+		t.Fatalf("game %s is not loaded", stored.Id)
+	}
+	return game
+}
+
+// eventually fails the test when condition does not hold within a second.
+func eventually(t *testing.T, condition func() bool, format string, args ...interface{}) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf(format, args...)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// newTestClient creates a client without connection whose messages are read with receive.
+func newTestClient(server *WsServer, user User) *Client {
+	if user.Id == uuid.Nil {
+		user.Id = uuid.New()
+	}
+	return newClient(nil, server, user)
+}
+
+// join seats the client in the game without the join-game checks.
+func join(game *Game, client *Client) {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	game.Users = append(game.Users, client.User)
+	game.Clients[client] = true
+}
+
+// received is a message sent to a test client.
+type received struct {
+	Action  string          `json:"action"`
+	Payload json.RawMessage `json:"payload"`
+	Sender  *User           `json:"sender"`
+}
+
+// receive returns the next message sent to the client.
+func receive(t *testing.T, client *Client) received {
+	t.Helper()
+	select {
+	case data := <-client.send:
+		var message received
+		if err := json.Unmarshal(data, &message); err != nil {
+			t.Fatalf("cannot decode message %s: %v", data, err)
+		}
+		return message
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+		return received{}
+	}
+}
+
+// receiveError returns the error sent to the client, failing when the next message is not an error.
+func receiveError(t *testing.T, client *Client) ErrorMessage {
+	t.Helper()
+	message := receive(t, client)
+	var payload ErrorMessage
+	if message.Action != Error || json.Unmarshal(message.Payload, &payload) != nil {
+		t.Fatalf("received %s %s, expected an error", message.Action, message.Payload)
+	}
+	return payload
+}
+
+// expectNothing fails when a message is sent to the client within d.
+func expectNothing(t *testing.T, client *Client, d time.Duration) {
+	t.Helper()
+	select {
+	case data := <-client.send:
+		t.Fatalf("unexpected message %s", data)
+	case <-time.After(d):
+	}
+}
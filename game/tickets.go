@@ -0,0 +1,200 @@
+package game
+
+import (
+	"GameService/auth"
+	"GameService/logging"
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/google/uuid"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Subprotocol is selected by the server when a client sends its token in
+// Sec-WebSocket-Protocol as BearerProtocolPrefix followed by the token.
+// Browsers cannot set headers of WebSocket requests, so they offer both
+// protocols: new WebSocket(url, ["game-service", "bearer." + token]).
+const Subprotocol = "game-service"
+
+// BearerProtocolPrefix prefixes a token sent as a WebSocket subprotocol.
+const BearerProtocolPrefix = "bearer."
+
+// GuestTokenHeader carries the token restoring a guest identity, see GuestIdentity.
+const GuestTokenHeader = "X-Guest-Token"
+
+// GuestProtocolPrefix prefixes a guest token sent as a WebSocket subprotocol,
+// offered with Subprotocol like bearer tokens.
+const GuestProtocolPrefix = "guest."
+
+// ticket is a single-use credential of a user exchanged for a token at /ws/ticket.
+type ticket struct {
+	userId uuid.UUID
+	// admin is set for tickets exchanged for admin tokens.
+	admin   bool
+	expires time.Time
+}
+
+// ticketStore keeps issued tickets until they are redeemed or expire.
+type ticketStore struct {
+	mutex   sync.Mutex
+	tickets map[string]ticket
+}
+
+func newTicketStore() *ticketStore {
+	return &ticketStore{tickets: make(map[string]ticket)}
+}
+
+// issue returns a new ticket of the user valid for ttl.
+func (s *ticketStore) issue(userId uuid.UUID, admin bool, ttl time.Duration, now time.Time) (string, time.Time, error) {
+	buffer := make([]byte, 32)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", time.Time{}, err
+	}
+	id := base64.RawURLEncoding.EncodeToString(buffer)
+	expires := now.Add(ttl)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for key, issued := range s.tickets {
+		if !now.Before(issued.expires) {
+			delete(s.tickets, key)
+		}
+	}
+	s.tickets[id] = ticket{userId: userId, admin: admin, expires: expires}
+	return id, expires, nil
+}
+
+// redeem returns the ticket and removes it. It fails when the ticket is
+// unknown, already redeemed or expired.
+func (s *ticketStore) redeem(id string, now time.Time) (ticket, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	issued, ok := s.tickets[id]
+	if !ok {
+		return ticket{}, false
+	}
+	delete(s.tickets, id)
+	if !now.Before(issued.expires) {
+		return ticket{}, false
+	}
+	return issued, true
+}
+
+// TicketHandler exchanges a user token in Authorization header for a ticket
+// which is passed to /ws in the ticket query parameter instead of the token.
+type TicketHandler struct {
+	server *WsServer
+}
+
+// NewTicketHandler creates a new TicketHandler.
+func NewTicketHandler(server *WsServer) *TicketHandler {
+	return &TicketHandler{server: server}
+}
+
+type ticketResponse struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (h *TicketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !h.server.checkOrigin(r) {
+		writeJSONError(w, http.StatusForbidden, "origin is not allowed")
+		return
+	}
+	ctx := logging.WithRequestID(r.Context(), r.Header.Get(logging.RequestIDHeader))
+	logger := logging.FromContext(ctx).WithField("remote_addr", r.RemoteAddr)
+
+	token, ok := bearerToken(r)
+	if !ok {
+		writeJSONError(w, http.StatusUnauthorized, "bearer token is required")
+		return
+	}
+	id, access, err := h.server.service.ParseToken(token)
+	if err != nil {
+		logger.WithError(err).Warn("cannot parse token")
+		writeJSONError(w, http.StatusUnauthorized, "invalid token: "+auth.Reason(err))
+		return
+	}
+	if access != "user" && access != "admin" {
+		logger.WithField(logging.UserIdField, id).Warn("cannot issue ticket: permission denied")
+		writeJSONError(w, http.StatusForbidden, "permission denied")
+		return
+	}
+
+	ticket, expires, err := h.server.tickets.issue(id, access == "admin", h.server.getSettings().WebSocket.TicketTTL, time.Now())
+	if err != nil {
+		logger.WithError(err).Error("cannot issue ticket")
+		writeJSONError(w, http.StatusInternalServerError, "cannot issue ticket")
+		return
+	}
+	logger.WithField(logging.UserIdField, id).Debug("ticket issued")
+	writeJSON(w, http.StatusOK, ticketResponse{Ticket: ticket, ExpiresAt: expires})
+}
+
+// bearerToken returns the token of Authorization header.
+func bearerToken(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return token, ok && token != ""
+}
+
+// protocolToken returns the token offered as a WebSocket subprotocol.
+func protocolToken(r *http.Request) (string, bool) {
+	return prefixedProtocol(r, BearerProtocolPrefix)
+}
+
+// guestToken returns the guest token of GuestTokenHeader or offered as a WebSocket subprotocol.
+func guestToken(r *http.Request) (string, bool) {
+	if token := r.Header.Get(GuestTokenHeader); token != "" {
+		return token, true
+	}
+	return prefixedProtocol(r, GuestProtocolPrefix)
+}
+
+// prefixedProtocol returns the value of the first WebSocket subprotocol with prefix.
+func prefixedProtocol(r *http.Request, prefix string) (string, bool) {
+	for _, protocol := range websocketProtocols(r) {
+		if value, ok := strings.CutPrefix(protocol, prefix); ok && value != "" {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+func websocketProtocols(r *http.Request) []string {
+	var protocols []string
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(header, ",") {
+			if protocol = strings.TrimSpace(protocol); protocol != "" {
+				protocols = append(protocols, protocol)
+			}
+		}
+	}
+	return protocols
+}
+
+// checkOrigin reports whether the request origin is allowed by settings.
+// Requests without Origin header are not sent by browsers and are allowed.
+func (server *WsServer) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	allowed := server.getSettings().WebSocket.AllowedOrigins
+	if len(allowed) == 0 {
+		parsed, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(parsed.Host, r.Host)
+	}
+	for _, allowedOrigin := range allowed {
+		if allowedOrigin == "*" || strings.EqualFold(strings.TrimSuffix(allowedOrigin, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// maxAnswerTime is the longest answer time of a stage.
+const maxAnswerTime = time.Hour
+
+// stagePayload is the optional payload of start-stage.
+type stagePayload struct {
+	// AnswerTime limits the answer of the respondent in seconds, see models.Entitlements.Timers.
+	AnswerTime int `json:"answer_time"`
+}
+
+// answerTime returns the answer time requested in the payload of start-stage,
+// zero when the answer is not limited. It notifies the client and reports false
+// when the payload is invalid or the plan of the creator has no timers.
+func (client *Client) answerTime(game *Game, payload interface{}) (time.Duration, bool) {
+	if payload == nil {
+		return 0, true
+	}
+	var stage stagePayload
+	jsonPayload, err := json.Marshal(payload)
+	if err == nil {
+		err = json.Unmarshal(jsonPayload, &stage)
+	}
+	answerTime := time.Duration(stage.AnswerTime) * time.Second
+	if err != nil || answerTime < 0 || answerTime > maxAnswerTime {
+		client.notifyClient(NewMessage(Error, ErrorMessage{
+			Code:    9,
+			Message: fmt.Sprintf("answer_time must be from 0 to %d seconds", int(maxAnswerTime/time.Second)),
+		}, game.ID, nil, time.Now()))
+		return 0, false
+	}
+	if answerTime > 0 && !game.getEntitlements().Timers {
+		client.notifyClient(newFeatureNotInPlanMessage(game.ID, FeatureTimers, "answer time cannot be limited"))
+		return 0, false
+	}
+	return answerTime, true
+}
+
+// setAnswerTimer stops the answer timer of the previous stage and, when answerTime
+// is positive, ends the answer of the respondent of the stage after answerTime
+// as if the respondent sent end-answer.
+func (game *Game) setAnswerTimer(stage *UserQuestion, answerTime time.Duration) {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	game.stopAnswerTimer()
+	if stage == nil || answerTime <= 0 {
+		return
+	}
+	stage.AnswerTime = int(answerTime / time.Second)
+	respondent, current := stage.User, game.answerStage
+	game.answerTimer = time.AfterFunc(answerTime, func() {
+		game.expireAnswer(current, respondent)
+	})
+}
+
+// stopAnswerTimer stops the answer timer. The caller must hold game.mutex.
+func (game *Game) stopAnswerTimer() {
+	if game.answerTimer != nil {
+		game.answerTimer.Stop()
+		game.answerTimer = nil
+	}
+	game.answerStage++
+}
+
+// expireAnswer ends the answer of the respondent unless the stage is over.
+func (game *Game) expireAnswer(stage int, respondent User) {
+	game.mutex.Lock()
+	if stage != game.answerStage {
+		game.mutex.Unlock()
+		return
+	}
+	game.stopAnswerTimer()
+	game.mutex.Unlock()
+	game.broadcast <- NewMessage(UserEndAnswerAction, nil, game.ID, &respondent, time.Now())
+}
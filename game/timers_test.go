@@ -0,0 +1,91 @@
+package game
+
+import (
+	"GameService/consts/plan_types"
+	"testing"
+	"time"
+)
+
+func TestAnswerTime(t *testing.T) {
+	server, repository := newTestServer(testSettings())
+	basic := newTestGame(t, server, repository, plan_types.Basic)
+	advanced := newTestGame(t, server, repository, plan_types.Advanced)
+
+	tests := []struct {
+		name    string
+		game    *Game
+		payload interface{}
+		want    time.Duration
+		code    int
+	}{
+		{name: "no payload", game: basic, payload: nil},
+		{name: "zero", game: basic, payload: map[string]interface{}{"answer_time": 0}},
+		{name: "limited", game: advanced, payload: map[string]interface{}{"answer_time": 30}, want: 30 * time.Second},
+		{name: "not in plan", game: basic, payload: map[string]interface{}{"answer_time": 30}, code: 13},
+		{name: "negative", game: advanced, payload: map[string]interface{}{"answer_time": -1}, code: 9},
+		{name: "too long", game: advanced, payload: map[string]interface{}{"answer_time": 3601}, code: 9},
+		{name: "malformed", game: advanced, payload: map[string]interface{}{"answer_time": "soon"}, code: 9},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(server, User{Name: "Host"})
+			got, ok := client.answerTime(tt.game, tt.payload)
+			if tt.code != 0 {
+				if ok {
+					t.Fatalf("answer time %s accepted", got)
+				}
+				if err := receiveError(t, client); err.Code != tt.code {
+					t.Fatalf("error code %d, expected %d: %s", err.Code, tt.code, err.Message)
+				}
+				return
+			}
+			if !ok || got != tt.want {
+				t.Fatalf("answer time is %s, %v, expected %s", got, ok, tt.want)
+			}
+			expectNothing(t, client, 0)
+		})
+	}
+}
+
+func TestAnswerTimerEndsAnswer(t *testing.T) {
+	server, repository := newTestServer(testSettings())
+	game := newTestGame(t, server, repository, plan_types.Advanced)
+	player := newTestClient(server, User{Name: "Player", Authorized: true})
+	join(game, player)
+
+	stage := &UserQuestion{User: *player.User}
+	game.setAnswerTimer(stage, 20*time.Millisecond)
+	if stage.AnswerTime != 0 {
+		t.Errorf("answer_time of a stage shorter than a second is %d", stage.AnswerTime)
+	}
+	message := receive(t, player)
+	if message.Action != UserEndAnswerAction || message.Sender == nil || message.Sender.Id != player.User.Id {
+		t.Fatalf("received %s from %v, expected end-answer of the respondent", message.Action, message.Sender)
+	}
+
+	game.setAnswerTimer(&UserQuestion{User: *player.User}, 2*time.Second)
+	game.setAnswerTimer(nil, 0)
+	game.mutex.Lock()
+	stopped := game.answerTimer == nil
+	game.mutex.Unlock()
+	if !stopped {
+		t.Fatal("timer of the previous stage is not stopped")
+	}
+}
+
+func TestAnswerTimerOfPreviousStageDoesNothing(t *testing.T) {
+	server, repository := newTestServer(testSettings())
+	game := newTestGame(t, server, repository, plan_types.Advanced)
+	player := newTestClient(server, User{Name: "Player", Authorized: true})
+	join(game, player)
+
+	game.setAnswerTimer(&UserQuestion{User: *player.User}, time.Second)
+	game.mutex.Lock()
+	stage := game.answerStage
+	game.mutex.Unlock()
+	game.setAnswerTimer(nil, 0)
+	// A timer which fired while the stage was ending must not end the next answer.
+	game.expireAnswer(stage, *player.User)
+	expectNothing(t, player, 50*time.Millisecond)
+}
@@ -0,0 +1,184 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOk   = "ok"
+	StatusFail = "fail"
+)
+
+// Check reports an error when the checked component is unhealthy.
+type Check func(ctx context.Context) error
+
+// Report is a health report returned by health endpoints.
+type Report struct {
+	Status  string            `json:"status"`
+	Checks  map[string]string `json:"checks"`
+	Uptime  string            `json:"uptime"`
+	Details interface{}       `json:"details,omitempty"`
+}
+
+// Checker runs liveness and readiness checks.
+type Checker struct {
+	startedAt time.Time
+	timeout   time.Duration
+	cacheFor  time.Duration
+	liveness  map[string]Check
+	readiness map[string]Check
+	details   func() interface{}
+}
+
+// NewChecker creates a new Checker. Every check is limited by timeout, and
+// results of readiness checks are reused for cacheFor so that frequent probes
+// do not reach upstreams on every request.
+func NewChecker(timeout time.Duration, cacheFor time.Duration, details func() interface{}) *Checker {
+	return &Checker{
+		startedAt: time.Now(),
+		timeout:   timeout,
+		cacheFor:  cacheFor,
+		liveness:  make(map[string]Check),
+		readiness: make(map[string]Check),
+		details:   details,
+	}
+}
+
+// AddLivenessCheck adds check which failure means the process must be restarted.
+// Liveness checks are also part of readiness.
+func (c *Checker) AddLivenessCheck(name string, check Check) {
+	c.liveness[name] = check
+}
+
+// AddReadinessCheck adds check which failure means the process must not receive traffic.
+func (c *Checker) AddReadinessCheck(name string, check Check) {
+	if c.cacheFor > 0 {
+		cached := &cachedCheck{check: check, interval: c.cacheFor}
+		check = cached.run
+	}
+	c.readiness[name] = check
+}
+
+// cachedCheck runs check at most once per interval and returns the last result in between.
+type cachedCheck struct {
+	check    Check
+	interval time.Duration
+	// mutex is held while the check runs, so concurrent probes wait for its result.
+	mutex   sync.Mutex
+	checked time.Time
+	err     error
+}
+
+func (c *cachedCheck) run(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.checked.IsZero() && time.Since(c.checked) < c.interval {
+		return c.err
+	}
+	err := c.check(ctx)
+	// Results of probes cancelled by the client are not cached.
+	if ctx.Err() != nil && !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	c.checked, c.err = time.Now(), err
+	return err
+}
+
+// LiveHandler serves /healthz.
+func (c *Checker) LiveHandler(w http.ResponseWriter, r *http.Request) {
+	c.serve(w, r, c.liveness)
+}
+
+// ReadyHandler serves /readyz.
+func (c *Checker) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	checks := make(map[string]Check, len(c.liveness)+len(c.readiness))
+	for name, check := range c.liveness {
+		checks[name] = check
+	}
+	for name, check := range c.readiness {
+		checks[name] = check
+	}
+	c.serve(w, r, checks)
+}
+
+func (c *Checker) serve(w http.ResponseWriter, r *http.Request, checks map[string]Check) {
+	ctx, cancel := context.WithTimeout(r.Context(), c.timeout)
+	defer cancel()
+
+	report := c.run(ctx, checks)
+	code := http.StatusOK
+	if report.Status != StatusOk {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+func (c *Checker) run(ctx context.Context, checks map[string]Check) Report {
+	report := Report{
+		Status: StatusOk,
+		Checks: make(map[string]string, len(checks)),
+		Uptime: time.Since(c.startedAt).Round(time.Second).String(),
+	}
+	if c.details != nil {
+		report.Details = c.details()
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			result := StatusOk
+			if err := check(ctx); err != nil {
+				result = StatusFail + ": " + err.Error()
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			report.Checks[name] = result
+			if result != StatusOk {
+				report.Status = StatusFail
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// HTTPCheck checks that url is reachable. Any response with status below 500
+// means the upstream is up.
+func HTTPCheck(url string) Check {
+	client := &http.Client{}
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return &StatusError{Code: resp.StatusCode}
+		}
+		return nil
+	}
+}
+
+// StatusError is returned by HTTPCheck when upstream responds with server error.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return "upstream responded with status " + http.StatusText(e.Code)
+}
@@ -0,0 +1,94 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testCacheFor = 50 * time.Millisecond
+
+// upstream is a test server answering with status and counting requests.
+type upstream struct {
+	*httptest.Server
+	requests atomic.Int32
+	status   atomic.Int32
+}
+
+func newUpstream(t *testing.T) *upstream {
+	u := &upstream{}
+	u.status.Store(http.StatusOK)
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.requests.Add(1)
+		w.WriteHeader(int(u.status.Load()))
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+// probe requests handler and returns the response status and report.
+func probe(t *testing.T, handler http.HandlerFunc) (int, Report) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	var report Report
+	if err := json.NewDecoder(recorder.Body).Decode(&report); err != nil {
+		t.Fatalf("cannot decode report: %v", err)
+	}
+	return recorder.Code, report
+}
+
+func TestReadinessChecksAreCached(t *testing.T) {
+	u := newUpstream(t)
+	checker := NewChecker(time.Second, testCacheFor, nil)
+	checker.AddReadinessCheck("upstream", HTTPCheck(u.URL))
+
+	for i := 0; i < 3; i++ {
+		if code, _ := probe(t, checker.ReadyHandler); code != http.StatusOK {
+			t.Fatalf("probe %d: status %d, expected 200", i, code)
+		}
+	}
+	if got := u.requests.Load(); got != 1 {
+		t.Fatalf("upstream requested %d times, expected once", got)
+	}
+
+	// A failure is reported after the interval and is cached as well.
+	u.status.Store(http.StatusBadGateway)
+	time.Sleep(testCacheFor)
+	for i := 0; i < 2; i++ {
+		code, report := probe(t, checker.ReadyHandler)
+		if code != http.StatusServiceUnavailable || report.Checks["upstream"] == StatusOk {
+			t.Fatalf("probe %d: status %d with %v, expected failed upstream", i, code, report.Checks)
+		}
+	}
+	if got := u.requests.Load(); got != 2 {
+		t.Fatalf("upstream requested %d times, expected twice", got)
+	}
+}
+
+func TestLivenessChecksAreNotCached(t *testing.T) {
+	u := newUpstream(t)
+	checker := NewChecker(time.Second, testCacheFor, nil)
+	checker.AddLivenessCheck("upstream", HTTPCheck(u.URL))
+
+	probe(t, checker.LiveHandler)
+	probe(t, checker.ReadyHandler)
+	if got := u.requests.Load(); got != 2 {
+		t.Fatalf("upstream requested %d times, expected twice", got)
+	}
+}
+
+func TestCachingDisabled(t *testing.T) {
+	u := newUpstream(t)
+	checker := NewChecker(time.Second, 0, nil)
+	checker.AddReadinessCheck("upstream", HTTPCheck(u.URL))
+
+	probe(t, checker.ReadyHandler)
+	probe(t, checker.ReadyHandler)
+	if got := u.requests.Load(); got != 2 {
+		t.Fatalf("upstream requested %d times, expected twice", got)
+	}
+}
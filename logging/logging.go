@@ -0,0 +1,130 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// RequestIDHeader is a header carrying request ID between services.
+const RequestIDHeader = "X-Request-Id"
+
+// Field names attached to log lines.
+const (
+	GameIdField    = "game_id"
+	UserIdField    = "user_id"
+	ClientIdField  = "client_id"
+	RequestIdField = "request_id"
+	ActionField    = "action"
+	TraceIdField   = "trace_id"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveFields are field names which values are never written to logs.
+var sensitiveFields = []string{"token", "password", "passcode", "secret", "api_key", "apikey", "webhook_key", "signing_key", "sdk_key", "authorization", "email"}
+
+type fieldsKey struct{}
+
+// Setup configures the standard logger with the given level and format.
+func Setup(level string, format string) error {
+	if level == "" {
+		level = logrus.InfoLevel.String()
+	}
+	parsedLevel, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	logrus.SetLevel(parsedLevel)
+
+	switch format {
+	case "", FormatText:
+		logrus.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	case FormatJSON:
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	logrus.AddHook(redactHook{})
+	return nil
+}
+
+// WithFields returns a copy of ctx carrying fields added to every line logged with FromContext.
+func WithFields(ctx context.Context, fields logrus.Fields) context.Context {
+	merged := make(logrus.Fields)
+	if parent, ok := ctx.Value(fieldsKey{}).(logrus.Fields); ok {
+		for k, v := range parent {
+			merged[k] = v
+		}
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// WithRequestID returns a copy of ctx carrying request ID.
+// New request ID is generated when id is empty.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		id = uuid.NewString()
+	}
+	return WithFields(ctx, logrus.Fields{RequestIdField: id})
+}
+
+// RequestID returns request ID carried by ctx.
+func RequestID(ctx context.Context) string {
+	if fields, ok := ctx.Value(fieldsKey{}).(logrus.Fields); ok {
+		if id, ok := fields[RequestIdField].(string); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+// FromContext returns a logger with fields carried by ctx and the current trace ID.
+func FromContext(ctx context.Context) *logrus.Entry {
+	entry := logrus.NewEntry(logrus.StandardLogger())
+	if fields, ok := ctx.Value(fieldsKey{}).(logrus.Fields); ok {
+		entry = entry.WithFields(fields)
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		entry = entry.WithField(TraceIdField, spanContext.TraceID().String())
+	}
+	return entry
+}
+
+// redactHook replaces values of sensitive fields.
+type redactHook struct{}
+
+func (redactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (redactHook) Fire(entry *logrus.Entry) error {
+	for key := range entry.Data {
+		if IsSensitive(key) {
+			entry.Data[key] = redacted
+		}
+	}
+	return nil
+}
+
+// IsSensitive reports whether values of the field must not be written to logs.
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for i := range sensitiveFields {
+		if strings.Contains(key, sensitiveFields[i]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"time"
+)
+
+const namespace = "game_service"
+
+const (
+	OutcomeOk      = "ok"
+	OutcomeError   = "error"
+	OutcomeInvalid = "invalid"
+	OutcomeUnknown = "unknown"
+)
+
+var (
+	ConnectionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "connections_total",
+		Help:      "Number of accepted WebSocket connections.",
+	}, []string{"kind"})
+
+	ConnectionsRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "connections_rejected_total",
+		Help:      "Number of rejected WebSocket connections.",
+	}, []string{"reason"})
+
+	TokenRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "token_rejections_total",
+		Help:      "Number of rejected user tokens by reason.",
+	}, []string{"reason"})
+
+	JWKSRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jwks_refreshes_total",
+		Help:      "Number of JWKS document loads by outcome.",
+	}, []string{"outcome"})
+
+	ActionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "actions_total",
+		Help:      "Number of handled client actions by type and outcome.",
+	}, []string{"action", "outcome"})
+
+	ActionDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "action_duration_seconds",
+		Help:      "Duration of client action handling.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"action"})
+
+	BroadcastDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "broadcast_duration_seconds",
+		Help:      "Time to fan out a message to all clients in a game.",
+		Buckets:   prometheus.ExponentialBuckets(0.00001, 4, 10),
+	})
+
+	BroadcastFanOut = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "broadcast_recipients",
+		Help:      "Number of clients a game message is broadcast to.",
+		Buckets:   prometheus.LinearBuckets(1, 1, 10),
+	})
+
+	SendQueueDepth = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "send_queue_depth",
+		Help:      "Number of messages queued for a client when the write pump picks up a message.",
+		Buckets:   []float64{0, 1, 2, 4, 8, 16, 32, 64, 128, 256},
+	})
+
+	UpstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Duration of requests to ConnectTeam and the meeting provider.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"call", "outcome"})
+
+	CircuitBreakerOpen = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "circuit_breaker_open",
+		Help:      "Whether circuit breaker of an upstream call is open.",
+	}, []string{"call"})
+
+	UpstreamErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_errors_total",
+		Help:      "Number of failed requests to ConnectTeam and the meeting provider.",
+	}, []string{"call"})
+
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Number of cache lookups by cache and result.",
+	}, []string{"cache", "result"})
+
+	CacheEvictions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_evictions_total",
+		Help:      "Number of entries evicted from cache because it is full.",
+	}, []string{"cache"})
+
+	CacheEntries = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cache_entries",
+		Help:      "Number of entries in cache.",
+	}, []string{"cache"})
+
+	ThrottledMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "throttled_messages_total",
+		Help:      "Number of client messages dropped by rate limits by action and penalty.",
+	}, []string{"action", "penalty"})
+
+	ThrottledClients = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "throttled_clients_total",
+		Help:      "Number of clients warned, muted or disconnected by rate limits.",
+	}, []string{"penalty"})
+
+	MutedClients = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "muted_clients",
+		Help:      "Number of currently muted clients.",
+	})
+)
+
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// Penalties of clients exceeding rate limits.
+const (
+	PenaltyWarning    = "warning"
+	PenaltyMute       = "mute"
+	PenaltyMuted      = "muted"
+	PenaltyDisconnect = "disconnect"
+)
+
+// ObserveUpstream records duration and outcome of repository call.
+// Use it with defer and a named error result.
+func ObserveUpstream(call string, start time.Time, err *error) {
+	outcome := OutcomeOk
+	if err != nil && *err != nil {
+		outcome = OutcomeError
+		UpstreamErrors.WithLabelValues(call).Inc()
+	}
+	UpstreamDuration.WithLabelValues(call, outcome).Observe(time.Since(start).Seconds())
+}
+
+// RegisterGameStats registers gauges reporting connected clients and active games by status.
+func RegisterGameStats(stats func() (clients int, games map[string]int)) {
+	prometheus.MustRegister(&statsCollector{stats: stats})
+}
+
+var (
+	clientsDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "connected_clients"),
+		"Number of connected clients.", nil, nil)
+	gamesDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "active_games"),
+		"Number of games loaded in memory by status.", []string{"status"}, nil)
+)
+
+type statsCollector struct {
+	stats func() (clients int, games map[string]int)
+}
+
+func (c *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- clientsDesc
+	ch <- gamesDesc
+}
+
+func (c *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	clients, games := c.stats()
+	ch <- prometheus.MustNewConstMetric(clientsDesc, prometheus.GaugeValue, float64(clients))
+	for status, count := range games {
+		ch <- prometheus.MustNewConstMetric(gamesDesc, prometheus.GaugeValue, float64(count), status)
+	}
+}
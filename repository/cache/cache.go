@@ -0,0 +1,96 @@
+// Package cache decorates ConnectTeam repositories with read-through caches.
+// Random topics and questions are never cached.
+package cache
+
+import (
+	"GameService/config"
+	"GameService/repository/models"
+	"GameService/repository/requests"
+	"context"
+	"github.com/google/uuid"
+)
+
+// Cache holds cached responses and implements requests.Invalidator.
+type Cache struct {
+	topics       *lru[uuid.UUID, models.Topic]
+	users        *lru[uuid.UUID, models.User]
+	plans        *lru[uuid.UUID, models.UserPlan]
+	entitlements *lru[string, models.Entitlements]
+}
+
+// Wrap returns repository reading topics, users, plans and entitlements through cache.
+func Wrap(repository *requests.Repository, cfg config.Cache) *requests.Repository {
+	c := &Cache{
+		topics:       newLRU[uuid.UUID, models.Topic]("topics", cfg.Topics.TTL, cfg.Topics.Size),
+		users:        newLRU[uuid.UUID, models.User]("users", cfg.Users.TTL, cfg.Users.Size),
+		plans:        newLRU[uuid.UUID, models.UserPlan]("plans", cfg.Plans.TTL, cfg.Plans.Size),
+		entitlements: newLRU[string, models.Entitlements]("entitlements", cfg.Entitlements.TTL, cfg.Entitlements.Size),
+	}
+	return &requests.Repository{
+		Game:        repository.Game,
+		User:        &userRepo{User: repository.User, cache: c},
+		Topic:       &topicRepo{Topic: repository.Topic, cache: c},
+		Meeting:     repository.Meeting,
+		Plan:        &planRepo{Plan: repository.Plan, cache: c},
+		Invalidator: c,
+	}
+}
+
+func (c *Cache) InvalidateUser(id uuid.UUID) {
+	c.users.remove(id)
+	c.plans.remove(id)
+}
+
+func (c *Cache) InvalidateTopic(id uuid.UUID) {
+	c.topics.remove(id)
+}
+
+func (c *Cache) InvalidatePlan(planType string) {
+	c.entitlements.remove(planType)
+}
+
+func (c *Cache) Purge() {
+	c.topics.purge()
+	c.users.purge()
+	c.plans.purge()
+	c.entitlements.purge()
+}
+
+type topicRepo struct {
+	requests.Topic
+	cache *Cache
+}
+
+func (r *topicRepo) GetTopic(ctx context.Context, id uuid.UUID) (models.Topic, error) {
+	return fetch(ctx, r.cache.topics, id, func(ctx context.Context) (models.Topic, error) {
+		return r.Topic.GetTopic(ctx, id)
+	})
+}
+
+type userRepo struct {
+	requests.User
+	cache *Cache
+}
+
+func (r *userRepo) GetUserById(ctx context.Context, id uuid.UUID) (models.User, error) {
+	return fetch(ctx, r.cache.users, id, func(ctx context.Context) (models.User, error) {
+		return r.User.GetUserById(ctx, id)
+	})
+}
+
+func (r *userRepo) GetCreatorPlan(ctx context.Context, id uuid.UUID) (models.UserPlan, error) {
+	return fetch(ctx, r.cache.plans, id, func(ctx context.Context) (models.UserPlan, error) {
+		return r.User.GetCreatorPlan(ctx, id)
+	})
+}
+
+type planRepo struct {
+	requests.Plan
+	cache *Cache
+}
+
+func (r *planRepo) GetEntitlements(ctx context.Context, planType string) (models.Entitlements, error) {
+	return fetch(ctx, r.cache.entitlements, planType, func(ctx context.Context) (models.Entitlements, error) {
+		return r.Plan.GetEntitlements(ctx, planType)
+	})
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"GameService/metrics"
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// lru is a size bounded cache which entries expire after ttl.
+type lru[K comparable, V any] struct {
+	name  string
+	ttl   time.Duration
+	size  int
+	mutex sync.Mutex
+	items map[K]*list.Element
+	order *list.List
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+func newLRU[K comparable, V any](name string, ttl time.Duration, size int) *lru[K, V] {
+	return &lru[K, V]{
+		name:  name,
+		ttl:   ttl,
+		size:  size,
+		items: make(map[K]*list.Element),
+		order: list.New(),
+	}
+}
+
+func (c *lru[K, V]) get(key K) (value V, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, ok := c.items[key]
+	if ok && time.Now().After(element.Value.(*entry[K, V]).expires) {
+		c.removeElement(element)
+		ok = false
+	}
+	if !ok {
+		metrics.CacheRequests.WithLabelValues(c.name, metrics.CacheMiss).Inc()
+		return value, false
+	}
+	metrics.CacheRequests.WithLabelValues(c.name, metrics.CacheHit).Inc()
+	c.order.MoveToFront(element)
+	return element.Value.(*entry[K, V]).value, true
+}
+
+func (c *lru[K, V]) add(key K, value V) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	expires := time.Now().Add(c.ttl)
+	if element, ok := c.items[key]; ok {
+		element.Value = &entry[K, V]{key: key, value: value, expires: expires}
+		c.order.MoveToFront(element)
+		return
+	}
+	c.items[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expires: expires})
+	for c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+		metrics.CacheEvictions.WithLabelValues(c.name).Inc()
+	}
+	metrics.CacheEntries.WithLabelValues(c.name).Set(float64(c.order.Len()))
+}
+
+func (c *lru[K, V]) remove(key K) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.items[key]; ok {
+		c.removeElement(element)
+	}
+}
+
+func (c *lru[K, V]) purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.items = make(map[K]*list.Element)
+	c.order.Init()
+	metrics.CacheEntries.WithLabelValues(c.name).Set(0)
+}
+
+// removeElement requires the mutex to be held.
+func (c *lru[K, V]) removeElement(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.items, element.Value.(*entry[K, V]).key)
+	metrics.CacheEntries.WithLabelValues(c.name).Set(float64(c.order.Len()))
+}
+
+// fetch returns cached value of key or loads and caches it. Errors are not cached.
+func fetch[K comparable, V any](ctx context.Context, c *lru[K, V], key K,
+	load func(ctx context.Context) (V, error)) (V, error) {
+	if value, ok := c.get(key); ok {
+		return value, nil
+	}
+	value, err := load(ctx)
+	if err != nil {
+		return value, err
+	}
+	c.add(key, value)
+	return value, nil
+}
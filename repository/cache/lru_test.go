@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+const testTTL = 100 * time.Millisecond
+
+// expectKeys expects the cache to hold exactly keys, most recently used first.
+func expectKeys(t *testing.T, c *lru[string, int], keys ...string) {
+	t.Helper()
+	var cached []string
+	for element := c.order.Front(); element != nil; element = element.Next() {
+		cached = append(cached, element.Value.(*entry[string, int]).key)
+	}
+	if len(cached) != len(keys) || len(c.items) != len(keys) {
+		t.Fatalf("expected keys %q, got %q", keys, cached)
+	}
+	for i := range keys {
+		if cached[i] != keys[i] {
+			t.Fatalf("expected keys %q, got %q", keys, cached)
+		}
+	}
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newLRU[string, int]("test", time.Minute, 3)
+	c.add("a", 1)
+	c.add("b", 2)
+	c.add("c", 3)
+	c.get("a")
+	c.add("d", 4)
+	expectKeys(t, c, "d", "a", "c")
+
+	// Updating an entry makes it the most recently used.
+	c.add("c", 30)
+	c.add("e", 5)
+	expectKeys(t, c, "e", "c", "d")
+	if value, ok := c.get("c"); !ok || value != 30 {
+		t.Fatalf("expected updated value 30, got %d %v", value, ok)
+	}
+}
+
+func TestLRUExpiredEntries(t *testing.T) {
+	c := newLRU[string, int]("test", testTTL, 3)
+	c.add("a", 1)
+	c.add("b", 2)
+	time.Sleep(testTTL / 2)
+	c.add("c", 3)
+
+	// Reads do not extend entries, so the recently read a expires first.
+	c.get("a")
+	time.Sleep(testTTL / 2)
+	if _, ok := c.get("a"); ok {
+		t.Fatal("expired entry is returned")
+	}
+	expectKeys(t, c, "c", "b")
+
+	// The slot of the removed entry is reused without evicting live entries.
+	c.add("d", 4)
+	expectKeys(t, c, "d", "c", "b")
+
+	// Expired entries which are not read are evicted in LRU order.
+	c.add("e", 5)
+	expectKeys(t, c, "e", "d", "c")
+
+	// Updating an entry extends it.
+	c.add("c", 30)
+	time.Sleep(testTTL / 2)
+	if value, ok := c.get("c"); !ok || value != 30 {
+		t.Fatalf("expected extended entry, got %d %v", value, ok)
+	}
+}
+
+func TestFetchDoesNotCacheErrors(t *testing.T) {
+	c := newLRU[string, int]("test", time.Minute, 3)
+	loads := 0
+	load := func(err error) func(ctx context.Context) (int, error) {
+		return func(ctx context.Context) (int, error) {
+			loads++
+			return loads, err
+		}
+	}
+
+	if _, err := fetch(context.Background(), c, "a", load(errors.New("unavailable"))); err == nil {
+		t.Fatal("expected load error")
+	}
+	for i := 0; i < 2; i++ {
+		if value, err := fetch(context.Background(), c, "a", load(nil)); err != nil || value != 2 {
+			t.Fatalf("fetch %d: expected 2, got %d %v", i, value, err)
+		}
+	}
+	if loads != 2 {
+		t.Fatalf("expected 2 loads, got %d", loads)
+	}
+}
@@ -1,18 +1,23 @@
 package endpoints
 
+// ConnectTeam endpoints relative to upstream.connect_team_url.
 const (
-	BaseURL              = "http://localhost:8001"
-	ZoomApiBaseURL       = "https://api.zoom.us/v2"
-	GetGameURL           = BaseURL + "/api/games/{id}"
-	StartGameURL         = BaseURL + "/api/games/start/{id}"
-	EndGameURL           = BaseURL + "/api/games/end/{id}"
-	SaveResultsURL       = BaseURL + "/api/games/{id}/results"
-	GetUserByIdURL       = BaseURL + "/api/users/{id}"
-	GetUserActivePlanURL = BaseURL + "/api/users/{id}/plan"
-	GetTopicWithIdURL    = BaseURL + "/api/topics/{id}"
-	GetRandQuestionsURL  = BaseURL + "/api/questions/"
-	GetRandTopicsURL     = BaseURL + "/api/topics/list/{limit}"
-	CreateMeetingURL     = ZoomApiBaseURL + "/users/{user_id}/meetings"
-	RefreshTokenURL      = "https://zoom.us/oauth/token"
-	GetResultsURL        = BaseURL + "/api/games/results/{id}"
+	GetGameURL           = "/api/games/{id}"
+	GetGameAccessURL     = "/api/games/{id}/access"
+	StartGameURL         = "/api/games/start/{id}"
+	EndGameURL           = "/api/games/end/{id}"
+	SaveResultsURL       = "/api/games/{id}/results"
+	GetUserByIdURL       = "/api/users/{id}"
+	GetUserActivePlanURL = "/api/users/{id}/plan"
+	GetTopicWithIdURL    = "/api/topics/{id}"
+	GetRandQuestionsURL  = "/api/questions/"
+	GetRandTopicsURL     = "/api/topics/list/{limit}"
+	GetResultsURL        = "/api/games/results/{id}"
+	GetEntitlementsURL   = "/api/plans/{type}/entitlements"
+)
+
+// Zoom endpoints relative to zoom.api_url and zoom.oauth_url.
+const (
+	CreateMeetingURL = "/users/{user_id}/meetings"
+	RefreshTokenURL  = "/oauth/token"
 )
@@ -0,0 +1,427 @@
+// Package fake implements repositories in memory with injectable faults
+// for exercising the game server without ConnectTeam and Zoom.
+package fake
+
+import (
+	"GameService/auth"
+	"GameService/config"
+	"GameService/consts/game_status"
+	"GameService/repository/models"
+	"GameService/repository/requests"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Fault describes how a call misbehaves.
+type Fault struct {
+	// Latency before the call returns. A call is cancelled when its context is done.
+	Latency time.Duration
+	// Err is returned by the call.
+	Err error
+	// Malformed makes the call fail as if the response body could not be decoded.
+	Malformed bool
+	// Times limits number of faulty calls, zero means every call.
+	Times int
+}
+
+// Repository is an in-memory store implementing every requests.Repository interface.
+// Calls are named after interface methods, e.g. GetGame.
+type Repository struct {
+	mutex        sync.Mutex
+	signingKey   string
+	verifier     *auth.Verifier
+	games        map[uuid.UUID]models.Game
+	access       map[uuid.UUID]models.GameAccess
+	users        map[uuid.UUID]models.User
+	plans        map[uuid.UUID]string
+	entitlements map[string]models.Entitlements
+	topics       []models.Topic
+	questions    map[uuid.UUID][]models.Question
+	results      map[uuid.UUID][]models.Rates
+	faults       map[string]*Fault
+	calls        map[string]int
+	meetings     int
+}
+
+// New creates an empty repository verifying tokens signed with signingKey.
+func New(signingKey string) *Repository {
+	return &Repository{
+		signingKey:   signingKey,
+		verifier:     auth.NewVerifier(config.Auth{SigningKey: signingKey}),
+		games:        make(map[uuid.UUID]models.Game),
+		access:       make(map[uuid.UUID]models.GameAccess),
+		users:        make(map[uuid.UUID]models.User),
+		plans:        make(map[uuid.UUID]string),
+		entitlements: make(map[string]models.Entitlements),
+		questions:    make(map[uuid.UUID][]models.Question),
+		results:      make(map[uuid.UUID][]models.Rates),
+		faults:       make(map[string]*Fault),
+		calls:        make(map[string]int),
+	}
+}
+
+// Repository returns r as requests.Repository.
+func (r *Repository) Repository() *requests.Repository {
+	return &requests.Repository{
+		Game:        r,
+		User:        r,
+		Topic:       r,
+		Meeting:     r,
+		Plan:        r,
+		Invalidator: requests.NoCache{},
+	}
+}
+
+// AddGame stores a game which is not started.
+func (r *Repository) AddGame(name string, creatorId uuid.UUID) models.Game {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	game := models.Game{Id: uuid.New(), Name: name, CreatorId: creatorId, Status: game_status.GameNotStarted}
+	r.games[game.Id] = game
+	return game
+}
+
+// SetAccess sets the access policy of the game.
+func (r *Repository) SetAccess(gameId uuid.UUID, access models.GameAccess) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.access[gameId] = access
+}
+
+// SetEmail sets the email of the user.
+func (r *Repository) SetEmail(userId uuid.UUID, email string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	user := r.users[userId]
+	user.Email = email
+	r.users[userId] = user
+}
+
+// AddUser stores a user with active plan of planType.
+func (r *Repository) AddUser(firstName string, planType string) models.User {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	user := models.User{Id: uuid.New(), FirstName: firstName, Access: "user"}
+	r.users[user.Id] = user
+	r.plans[user.Id] = planType
+	return user
+}
+
+// SetPlan changes the active plan of the user.
+func (r *Repository) SetPlan(userId uuid.UUID, planType string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.plans[userId] = planType
+}
+
+// AddTopic stores a topic with count questions.
+func (r *Repository) AddTopic(title string, count int) models.Topic {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	topic := models.Topic{Id: uuid.New(), Title: title}
+	r.topics = append(r.topics, topic)
+	for i := 0; i < count; i++ {
+		r.questions[topic.Id] = append(r.questions[topic.Id], models.Question{
+			Id:      uuid.New(),
+			TopicId: topic.Id,
+			Content: title + " question " + strconv.Itoa(i+1),
+			Tags:    []models.Tag{{Id: uuid.New(), Name: title}},
+		})
+	}
+	return topic
+}
+
+// SetEntitlements sets entitlements returned for planType.
+func (r *Repository) SetEntitlements(planType string, entitlements models.Entitlements) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.entitlements[planType] = entitlements
+}
+
+// Token returns a token of the user signed with the signing key.
+func (r *Repository) Token(userId uuid.UUID, access string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": userId.String(),
+		"access":  access,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	})
+	signed, _ := token.SignedString([]byte(r.signingKey))
+	return signed
+}
+
+// SetVerifier replaces the verifier of tokens, e.g. with one using a JWKS document.
+func (r *Repository) SetVerifier(verifier *auth.Verifier) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.verifier = verifier
+}
+
+// Inject makes the call misbehave as described by fault.
+func (r *Repository) Inject(call string, fault Fault) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.faults[call] = &fault
+}
+
+// Reset removes injected faults.
+func (r *Repository) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.faults = make(map[string]*Fault)
+}
+
+// Calls returns number of calls made to call.
+func (r *Repository) Calls(call string) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.calls[call]
+}
+
+// Game returns the stored game.
+func (r *Repository) Game(id uuid.UUID) (models.Game, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	game, ok := r.games[id]
+	return game, ok
+}
+
+// Results returns results saved for the game.
+func (r *Repository) Results(id uuid.UUID) ([]models.Rates, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	results, ok := r.results[id]
+	return results, ok
+}
+
+// call records the call and applies its fault.
+func (r *Repository) call(ctx context.Context, call string) error {
+	r.mutex.Lock()
+	r.calls[call]++
+	fault, ok := r.faults[call]
+	if ok && fault.Times > 0 {
+		fault.Times--
+		if fault.Times == 0 {
+			delete(r.faults, call)
+		}
+	}
+	r.mutex.Unlock()
+	if !ok {
+		return ctx.Err()
+	}
+
+	if fault.Latency > 0 {
+		timer := time.NewTimer(fault.Latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return &requests.UpstreamError{Call: call, Kind: requests.ErrUpstreamDown, Err: ctx.Err()}
+		}
+	}
+	if fault.Malformed {
+		return &requests.UpstreamError{Call: call, Status: 200, Kind: requests.ErrDecode,
+			Err: errors.New("malformed body")}
+	}
+	return fault.Err
+}
+
+func (r *Repository) GetGame(ctx context.Context, id uuid.UUID) (models.Game, error) {
+	if err := r.call(ctx, "GetGame"); err != nil {
+		return models.Game{}, err
+	}
+	game, ok := r.Game(id)
+	if !ok {
+		return models.Game{}, requests.ErrNotFound
+	}
+	return game, nil
+}
+
+func (r *Repository) GetGameAccess(ctx context.Context, id uuid.UUID) (models.GameAccess, error) {
+	if err := r.call(ctx, "GetGameAccess"); err != nil {
+		return models.GameAccess{}, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	access, ok := r.access[id]
+	if !ok {
+		return models.GameAccess{}, requests.ErrNotFound
+	}
+	return access, nil
+}
+
+func (r *Repository) SaveResults(ctx context.Context, id uuid.UUID, results []models.Rates) error {
+	if err := r.call(ctx, "SaveResults"); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.results[id] = results
+	return nil
+}
+
+func (r *Repository) EndGame(ctx context.Context, id uuid.UUID) error {
+	if err := r.call(ctx, "EndGame"); err != nil {
+		return err
+	}
+	return r.setStatus(id, game_status.GameEnded)
+}
+
+func (r *Repository) StartGame(ctx context.Context, id uuid.UUID) error {
+	if err := r.call(ctx, "StartGame"); err != nil {
+		return err
+	}
+	return r.setStatus(id, game_status.GameInProgress)
+}
+
+func (r *Repository) setStatus(id uuid.UUID, status string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	game, ok := r.games[id]
+	if !ok {
+		return requests.ErrNotFound
+	}
+	game.Status = status
+	r.games[id] = game
+	return nil
+}
+
+func (r *Repository) GetResults(ctx context.Context, gameId uuid.UUID) (models.GetResultsResponse, error) {
+	if err := r.call(ctx, "GetResults"); err != nil {
+		return models.GetResultsResponse{}, err
+	}
+	rates, ok := r.Results(gameId)
+	if !ok {
+		return models.GetResultsResponse{}, requests.ErrNotFound
+	}
+	response := models.GetResultsResponse{Results: make([]models.Results, len(rates))}
+	for i, rate := range rates {
+		response.Results[i] = models.Results{
+			Value:           rate.Value,
+			UserId:          rate.UserId,
+			UserTemporaryId: rate.UserTemporaryId,
+			Name:            rate.Name,
+		}
+	}
+	return response, nil
+}
+
+func (r *Repository) ParseToken(token string) (uuid.UUID, string, error) {
+	r.mutex.Lock()
+	verifier := r.verifier
+	r.mutex.Unlock()
+	return verifier.ParseToken(token)
+}
+
+func (r *Repository) GetUserById(ctx context.Context, id uuid.UUID) (models.User, error) {
+	if err := r.call(ctx, "GetUserById"); err != nil {
+		return models.User{}, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return models.User{}, requests.ErrNotFound
+	}
+	return user, nil
+}
+
+func (r *Repository) GetCreatorPlan(ctx context.Context, id uuid.UUID) (models.UserPlan, error) {
+	if err := r.call(ctx, "GetCreatorPlan"); err != nil {
+		return models.UserPlan{}, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	planType, ok := r.plans[id]
+	if !ok {
+		return models.UserPlan{}, requests.ErrNotFound
+	}
+	return models.UserPlan{Id: id, PlanType: planType}, nil
+}
+
+func (r *Repository) GetEntitlements(ctx context.Context, planType string) (models.Entitlements, error) {
+	if err := r.call(ctx, "GetEntitlements"); err != nil {
+		return models.Entitlements{}, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	entitlements, ok := r.entitlements[planType]
+	if !ok {
+		return models.Entitlements{}, requests.ErrNotFound
+	}
+	return entitlements, nil
+}
+
+func (r *Repository) GetTopic(ctx context.Context, id uuid.UUID) (models.Topic, error) {
+	if err := r.call(ctx, "GetTopic"); err != nil {
+		return models.Topic{}, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, topic := range r.topics {
+		if topic.Id == id {
+			return topic, nil
+		}
+	}
+	return models.Topic{}, requests.ErrNotFound
+}
+
+// GetRandQuestionsWithLimit returns first limit questions of the topic.
+func (r *Repository) GetRandQuestionsWithLimit(ctx context.Context, topicId uuid.UUID, limit int) ([]models.Question, error) {
+	if err := r.call(ctx, "GetRandQuestionsWithLimit"); err != nil {
+		return nil, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	questions, ok := r.questions[topicId]
+	if !ok {
+		return nil, requests.ErrNotFound
+	}
+	if len(questions) > limit {
+		questions = questions[:limit]
+	}
+	return append([]models.Question(nil), questions...), nil
+}
+
+func (r *Repository) GetRandQuestionsPerTopic(ctx context.Context, topicIds []uuid.UUID, limit int) (map[uuid.UUID][]models.Question, error) {
+	var errs []error
+	questions := make(map[uuid.UUID][]models.Question, len(topicIds))
+	for _, topicId := range topicIds {
+		topicQuestions, err := r.GetRandQuestionsWithLimit(ctx, topicId, limit)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("topic %s: %w", topicId, err))
+			continue
+		}
+		questions[topicId] = topicQuestions
+	}
+	return questions, errors.Join(errs...)
+}
+
+// GetRandTopicsWithLimit returns first limit topics.
+func (r *Repository) GetRandTopicsWithLimit(ctx context.Context, limit int) ([]models.Topic, error) {
+	if err := r.call(ctx, "GetRandTopicsWithLimit"); err != nil {
+		return nil, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	topics := r.topics
+	if len(topics) > limit {
+		topics = topics[:limit]
+	}
+	return append([]models.Topic(nil), topics...), nil
+}
+
+func (r *Repository) CreateMeeting(ctx context.Context) (string, string, error) {
+	if err := r.call(ctx, "CreateMeeting"); err != nil {
+		return "", "", err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.meetings++
+	return strconv.Itoa(1000000000 + r.meetings), "123456", nil
+}
@@ -0,0 +1,218 @@
+package local
+
+import (
+	"GameService/auth"
+	"GameService/config"
+	"GameService/consts/game_status"
+	"GameService/consts/plan_types"
+	"GameService/repository/models"
+	"GameService/repository/requests"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io/fs"
+	"math/rand"
+	"strconv"
+)
+
+// NewRepository loads data files and returns repository working without network.
+// Meetings are not created: CreateMeeting returns random meeting number and passcode.
+func NewRepository(cfg *config.Config) (*requests.Repository, error) {
+	s, err := load(cfg.Repository.DataDir, cfg.Repository.ResultsDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load local data: %w", err)
+	}
+	return &requests.Repository{
+		Game:        &gameRepo{store: s},
+		User:        &userRepo{store: s, verifier: auth.NewVerifier(cfg.Auth)},
+		Topic:       &topicRepo{store: s},
+		Meeting:     meetingRepo{},
+		Plan:        &planRepo{store: s},
+		Invalidator: requests.NoCache{},
+	}, nil
+}
+
+type gameRepo struct {
+	store *store
+}
+
+func (r *gameRepo) GetGame(ctx context.Context, id uuid.UUID) (models.Game, error) {
+	r.store.mutex.Lock()
+	defer r.store.mutex.Unlock()
+	game, ok := r.store.games[id]
+	if !ok {
+		return models.Game{}, requests.ErrNotFound
+	}
+	return game, nil
+}
+
+func (r *gameRepo) GetGameAccess(ctx context.Context, id uuid.UUID) (models.GameAccess, error) {
+	r.store.mutex.Lock()
+	defer r.store.mutex.Unlock()
+	access, ok := r.store.access[id]
+	if !ok {
+		return models.GameAccess{}, requests.ErrNotFound
+	}
+	return access, nil
+}
+
+func (r *gameRepo) SaveResults(ctx context.Context, id uuid.UUID, results []models.Rates) error {
+	return r.store.writeResults(id, results)
+}
+
+func (r *gameRepo) EndGame(ctx context.Context, id uuid.UUID) error {
+	return r.setStatus(id, game_status.GameEnded)
+}
+
+func (r *gameRepo) StartGame(ctx context.Context, id uuid.UUID) error {
+	return r.setStatus(id, game_status.GameInProgress)
+}
+
+func (r *gameRepo) setStatus(id uuid.UUID, status string) error {
+	r.store.mutex.Lock()
+	defer r.store.mutex.Unlock()
+	game, ok := r.store.games[id]
+	if !ok {
+		return requests.ErrNotFound
+	}
+	game.Status = status
+	r.store.games[id] = game
+	return nil
+}
+
+func (r *gameRepo) GetResults(ctx context.Context, gameId uuid.UUID) (models.GetResultsResponse, error) {
+	rates, err := r.store.readResults(gameId)
+	if errors.Is(err, fs.ErrNotExist) {
+		return models.GetResultsResponse{}, requests.ErrNotFound
+	}
+	if err != nil {
+		return models.GetResultsResponse{}, err
+	}
+	response := models.GetResultsResponse{Results: make([]models.Results, len(rates))}
+	for i, rate := range rates {
+		tags := make([]models.Tag, 0, len(rate.Tags))
+		for _, tagId := range rate.Tags {
+			if tag, ok := r.store.tags[tagId]; ok {
+				tags = append(tags, tag)
+			}
+		}
+		response.Results[i] = models.Results{
+			Value:           rate.Value,
+			Tags:            tags,
+			UserId:          rate.UserId,
+			UserTemporaryId: rate.UserTemporaryId,
+			Name:            rate.Name,
+		}
+	}
+	return response, nil
+}
+
+type userRepo struct {
+	store    *store
+	verifier *auth.Verifier
+}
+
+func (r *userRepo) ParseToken(token string) (uuid.UUID, string, error) {
+	return r.verifier.ParseToken(token)
+}
+
+func (r *userRepo) GetUserById(ctx context.Context, id uuid.UUID) (models.User, error) {
+	user, ok := r.store.users[id]
+	if !ok {
+		return models.User{}, requests.ErrNotFound
+	}
+	return user.User, nil
+}
+
+func (r *userRepo) GetCreatorPlan(ctx context.Context, id uuid.UUID) (models.UserPlan, error) {
+	user, ok := r.store.users[id]
+	if !ok {
+		return models.UserPlan{}, requests.ErrNotFound
+	}
+	plan := models.UserPlan{Id: user.Id, PlanType: user.PlanType}
+	if plan.PlanType == "" {
+		plan.PlanType = plan_types.Basic
+	}
+	return plan, nil
+}
+
+type topicRepo struct {
+	store *store
+}
+
+func (r *topicRepo) GetTopic(ctx context.Context, id uuid.UUID) (models.Topic, error) {
+	topic, ok := r.store.topicsById[id]
+	if !ok {
+		return models.Topic{}, requests.ErrNotFound
+	}
+	return topic.Topic, nil
+}
+
+func (r *topicRepo) GetRandQuestionsWithLimit(ctx context.Context, topicId uuid.UUID, limit int) ([]models.Question, error) {
+	topic, ok := r.store.topicsById[topicId]
+	if !ok {
+		return nil, requests.ErrNotFound
+	}
+	questions := make([]models.Question, 0, limit)
+	for _, i := range rand.Perm(len(topic.Questions)) {
+		if len(questions) == limit {
+			break
+		}
+		question := topic.Questions[i]
+		questions = append(questions, models.Question{
+			Id:      question.Id,
+			TopicId: topic.Id,
+			Content: question.Content,
+			Tags:    question.Tags,
+		})
+	}
+	return questions, nil
+}
+
+func (r *topicRepo) GetRandQuestionsPerTopic(ctx context.Context, topicIds []uuid.UUID, limit int) (map[uuid.UUID][]models.Question, error) {
+	var errs []error
+	questions := make(map[uuid.UUID][]models.Question, len(topicIds))
+	for _, topicId := range topicIds {
+		topicQuestions, err := r.GetRandQuestionsWithLimit(ctx, topicId, limit)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("topic %s: %w", topicId, err))
+			continue
+		}
+		questions[topicId] = topicQuestions
+	}
+	return questions, errors.Join(errs...)
+}
+
+func (r *topicRepo) GetRandTopicsWithLimit(ctx context.Context, limit int) ([]models.Topic, error) {
+	topics := make([]models.Topic, 0, limit)
+	for _, i := range rand.Perm(len(r.store.topics)) {
+		if len(topics) == limit {
+			break
+		}
+		topics = append(topics, r.store.topics[i].Topic)
+	}
+	return topics, nil
+}
+
+type planRepo struct {
+	store *store
+}
+
+// GetEntitlements returns entitlements from the plans file. ErrNotFound makes
+// the server fall back to plans from config.
+func (r *planRepo) GetEntitlements(ctx context.Context, planType string) (models.Entitlements, error) {
+	entitlements, ok := r.store.plans[planType]
+	if !ok {
+		return models.Entitlements{}, requests.ErrNotFound
+	}
+	return entitlements, nil
+}
+
+type meetingRepo struct{}
+
+func (meetingRepo) CreateMeeting(ctx context.Context) (string, string, error) {
+	meetingNumber := strconv.FormatInt(1e10+rand.Int63n(9e10), 10)
+	passcode := strconv.Itoa(100000 + rand.Intn(900000))
+	return meetingNumber, passcode, nil
+}
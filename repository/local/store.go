@@ -0,0 +1,184 @@
+// Package local implements repositories backed by local files so the service
+// runs without ConnectTeam and Zoom.
+package local
+
+import (
+	"GameService/repository/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Data files are looked up in the data directory with any of these extensions.
+var extensions = []string{".yml", ".yaml", ".json"}
+
+const (
+	gamesFile  = "games"
+	usersFile  = "users"
+	plansFile  = "plans"
+	topicsFile = "topics"
+)
+
+type game struct {
+	models.Game
+	// Access policy of the game, the game is open without it.
+	Access *models.GameAccess `json:"access"`
+}
+
+type user struct {
+	models.User
+	// Active plan of the user, basic by default.
+	PlanType string `json:"plan_type"`
+}
+
+type question struct {
+	Id      uuid.UUID    `json:"id"`
+	Content string       `json:"content"`
+	Tags    []models.Tag `json:"tags"`
+}
+
+type topic struct {
+	models.Topic
+	Questions []question `json:"questions"`
+}
+
+// store holds data loaded from files. Game statuses are changed in memory only.
+type store struct {
+	mutex      sync.Mutex
+	games      map[uuid.UUID]models.Game
+	access     map[uuid.UUID]models.GameAccess
+	users      map[uuid.UUID]user
+	plans      map[string]models.Entitlements
+	topics     []topic
+	topicsById map[uuid.UUID]*topic
+	tags       map[uuid.UUID]models.Tag
+	resultsDir string
+}
+
+// load reads data files from dataDir. Missing files are treated as empty.
+func load(dataDir string, resultsDir string) (*store, error) {
+	var (
+		games  []game
+		users  []user
+		plans  map[string]models.Entitlements
+		topics []topic
+	)
+	if err := errors.Join(
+		readFile(dataDir, gamesFile, &games),
+		readFile(dataDir, usersFile, &users),
+		readFile(dataDir, plansFile, &plans),
+		readFile(dataDir, topicsFile, &topics),
+	); err != nil {
+		return nil, err
+	}
+
+	s := &store{
+		games:      make(map[uuid.UUID]models.Game, len(games)),
+		access:     make(map[uuid.UUID]models.GameAccess),
+		users:      make(map[uuid.UUID]user, len(users)),
+		plans:      plans,
+		topics:     topics,
+		topicsById: make(map[uuid.UUID]*topic, len(topics)),
+		tags:       make(map[uuid.UUID]models.Tag),
+		resultsDir: resultsDir,
+	}
+	var errs []error
+	for _, game := range games {
+		if _, ok := s.games[game.Id]; ok || game.Id == uuid.Nil {
+			errs = append(errs, fmt.Errorf("%s: missing or duplicate game id %s", gamesFile, game.Id))
+		}
+		s.games[game.Id] = game.Game
+		if game.Access != nil {
+			s.access[game.Id] = *game.Access
+		}
+	}
+	for _, user := range users {
+		if _, ok := s.users[user.Id]; ok || user.Id == uuid.Nil {
+			errs = append(errs, fmt.Errorf("%s: missing or duplicate user id %s", usersFile, user.Id))
+		}
+		s.users[user.Id] = user
+	}
+	for i := range s.topics {
+		topic := &s.topics[i]
+		if _, ok := s.topicsById[topic.Id]; ok || topic.Id == uuid.Nil {
+			errs = append(errs, fmt.Errorf("%s: missing or duplicate topic id %s", topicsFile, topic.Id))
+		}
+		s.topicsById[topic.Id] = topic
+		for _, question := range topic.Questions {
+			if question.Id == uuid.Nil {
+				errs = append(errs, fmt.Errorf("%s: question of topic %s has no id", topicsFile, topic.Id))
+			}
+			for _, tag := range question.Tags {
+				s.tags[tag.Id] = tag
+			}
+		}
+	}
+	return s, errors.Join(errs...)
+}
+
+// readFile decodes YAML or JSON file name from dir into v using JSON field names of models.
+func readFile(dir string, name string, v interface{}) error {
+	for _, extension := range extensions {
+		path := filepath.Join(dir, name+extension)
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		var raw interface{}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		data, err = json.Marshal(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return nil
+	}
+	return nil
+}
+
+// writeResults atomically writes results of the game to the results directory.
+func (s *store) writeResults(id uuid.UUID, results []models.Rates) error {
+	if err := os.MkdirAll(s.resultsDir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(map[string]interface{}{"results": results}, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := s.resultsPath(id)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (s *store) readResults(id uuid.UUID) ([]models.Rates, error) {
+	data, err := os.ReadFile(s.resultsPath(id))
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Results []models.Rates `json:"results"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	return file.Results, nil
+}
+
+func (s *store) resultsPath(id uuid.UUID) string {
+	return filepath.Join(s.resultsDir, id.String()+".json")
+}
@@ -9,3 +9,15 @@ type Game struct {
 	CreatorId uuid.UUID `json:"creator_id"`
 	MaxSize   int       `json:"max_size"`
 }
+
+// GameAccess is the access policy of a game set by its creator in ConnectTeam.
+// A game without invited users, invited domains and passcode is open to everyone.
+type GameAccess struct {
+	InvitedUsers []uuid.UUID `json:"invited_users"`
+	// Email domains of invited users, e.g. "example.com".
+	InvitedDomains []string `json:"invited_domains"`
+	// Passcode required from users who are not invited, empty when not required.
+	Passcode string `json:"passcode"`
+	// WaitingRoom makes guests wait until a host admits them.
+	WaitingRoom bool `json:"waiting_room"`
+}
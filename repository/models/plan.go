@@ -8,3 +8,20 @@ type UserPlan struct {
 	Id       uuid.UUID `json:"id"`
 	PlanType string    `json:"plan_type"`
 }
+
+// Entitlements are features and limits available to games created by a user with the plan.
+type Entitlements struct {
+	MaxPlayers int `json:"max_players" mapstructure:"max_players"`
+	// Number of random topics for plans without custom topics,
+	// otherwise max number of selected topics. Zero means no limit.
+	TopicCount   int  `json:"topic_count" mapstructure:"topic_count"`
+	CustomTopics bool `json:"custom_topics" mapstructure:"custom_topics"`
+	// Max number of spectators.
+	Spectators int `json:"spectators" mapstructure:"spectators"`
+	// The host can limit the answer time of a stage.
+	Timers bool `json:"timers" mapstructure:"timers"`
+	// Results of the ended game can be exported.
+	Exports bool `json:"exports" mapstructure:"exports"`
+	// Video meeting is created for the game.
+	Meeting bool `json:"meeting" mapstructure:"meeting"`
+}
@@ -0,0 +1,70 @@
+package requests
+
+import (
+	"GameService/metrics"
+	"sync"
+	"time"
+)
+
+// breaker is a circuit breaker of an upstream endpoint. It opens after threshold
+// consecutive failures and lets a single trial request through after openDuration.
+type breaker struct {
+	name         string
+	threshold    int
+	openDuration time.Duration
+	mutex        sync.Mutex
+	failures     int
+	openedAt     time.Time
+	open         bool
+	trial        bool
+}
+
+func newBreaker(name string, threshold int, openDuration time.Duration) *breaker {
+	return &breaker{name: name, threshold: threshold, openDuration: openDuration}
+}
+
+// allow reports whether request can be sent.
+func (b *breaker) allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if !b.open {
+		return true
+	}
+	if b.trial || time.Since(b.openedAt) < b.openDuration {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// release lets another trial request through when the allowed request was
+// abandoned by the caller, without recording its result.
+func (b *breaker) release() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.trial = false
+}
+
+// record records result of the allowed request.
+func (b *breaker) record(success bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.trial = false
+	if success {
+		b.failures = 0
+		if b.open {
+			b.open = false
+			metrics.CircuitBreakerOpen.WithLabelValues(b.name).Set(0)
+		}
+		return
+	}
+
+	b.failures++
+	if b.open || b.failures >= b.threshold {
+		b.open = true
+		b.openedAt = time.Now()
+		metrics.CircuitBreakerOpen.WithLabelValues(b.name).Set(1)
+	}
+}
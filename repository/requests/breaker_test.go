@@ -0,0 +1,188 @@
+package requests
+
+import (
+	"GameService/config"
+	"context"
+	"errors"
+	"github.com/go-resty/resty/v2"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testOpenDuration = 20 * time.Millisecond
+
+func TestBreakerTransitions(t *testing.T) {
+	b := newBreaker("test", 2, testOpenDuration)
+	expectAllow := func(step string, want bool) {
+		t.Helper()
+		if got := b.allow(); got != want {
+			t.Fatalf("%s: allowed %v, expected %v", step, got, want)
+		}
+	}
+
+	expectAllow("closed", true)
+	b.record(false)
+	expectAllow("below threshold", true)
+	b.record(false)
+	expectAllow("open", false)
+
+	time.Sleep(testOpenDuration)
+	expectAllow("trial", true)
+	expectAllow("during trial", false)
+	b.record(false)
+	expectAllow("reopened by failed trial", false)
+
+	time.Sleep(testOpenDuration)
+	expectAllow("trial", true)
+	b.release()
+	expectAllow("trial after abandoned trial", true)
+	b.record(true)
+	expectAllow("closed by successful trial", true)
+	expectAllow("closed", true)
+
+	// Failures are counted from the last success.
+	b.record(false)
+	b.record(true)
+	b.record(false)
+	expectAllow("failures reset by success", true)
+}
+
+// upstream is a test server answering with status, after delay when it is set.
+type upstream struct {
+	*httptest.Server
+	requests atomic.Int32
+	status   atomic.Int32
+	delay    atomic.Int64
+}
+
+func newUpstream(t *testing.T) *upstream {
+	u := &upstream{}
+	u.status.Store(http.StatusOK)
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.requests.Add(1)
+		select {
+		case <-time.After(time.Duration(u.delay.Load())):
+		case <-r.Context().Done():
+			return
+		}
+		w.WriteHeader(int(u.status.Load()))
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+func newTestClient(u *upstream, threshold int) *HTTPClient {
+	return NewHTTPClient(u.URL, config.Upstream{
+		Timeout:             time.Second,
+		Retries:             2,
+		RetryWait:           time.Millisecond,
+		RetryMaxWait:        2 * time.Millisecond,
+		BreakerThreshold:    threshold,
+		BreakerOpenDuration: testOpenDuration,
+	})
+}
+
+func call(ctx context.Context, client *HTTPClient, method string) error {
+	var result map[string]interface{}
+	return client.execute(ctx, "Test", method, "/test", func(r *resty.Request) {}, &result)
+}
+
+func TestExecuteRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		status   int
+		requests int32
+		err      error
+	}{
+		{name: "GET upstream down", method: http.MethodGet, status: http.StatusServiceUnavailable, requests: 3, err: ErrUpstreamDown},
+		{name: "GET too many requests", method: http.MethodGet, status: http.StatusTooManyRequests, requests: 3, err: ErrUpstreamDown},
+		{name: "GET not found", method: http.MethodGet, status: http.StatusNotFound, requests: 1, err: ErrNotFound},
+		{name: "GET rejected", method: http.MethodGet, status: http.StatusBadRequest, requests: 1, err: ErrRejected},
+		{name: "POST upstream down", method: http.MethodPost, status: http.StatusServiceUnavailable, requests: 1, err: ErrUpstreamDown},
+		{name: "PUT upstream down", method: http.MethodPut, status: http.StatusBadGateway, requests: 1, err: ErrUpstreamDown},
+		{name: "GET ok", method: http.MethodGet, status: http.StatusOK, requests: 1},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			u := newUpstream(t)
+			u.status.Store(int32(tt.status))
+			err := call(context.Background(), newTestClient(u, 10), tt.method)
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("error is %v, expected %v", err, tt.err)
+			}
+			if requests := u.requests.Load(); requests != tt.requests {
+				t.Fatalf("sent %d requests, expected %d", requests, tt.requests)
+			}
+		})
+	}
+}
+
+func TestExecuteOpensBreaker(t *testing.T) {
+	u := newUpstream(t)
+	client := newTestClient(u, 3)
+	u.status.Store(http.StatusServiceUnavailable)
+
+	// Every attempt of the retried call counts as a failure.
+	if err := call(context.Background(), client, http.MethodGet); !errors.Is(err, ErrUpstreamDown) {
+		t.Fatal(err)
+	}
+	err := call(context.Background(), client, http.MethodGet)
+	if !errors.Is(err, ErrUpstreamDown) || u.requests.Load() != 3 {
+		t.Fatalf("open breaker sent %d requests: %v", u.requests.Load()-3, err)
+	}
+
+	// Rejected requests do not open the breaker, as upstream is up.
+	u.status.Store(http.StatusBadRequest)
+	time.Sleep(testOpenDuration)
+	if err := call(context.Background(), client, http.MethodGet); !errors.Is(err, ErrRejected) {
+		t.Fatal(err)
+	}
+	u.status.Store(http.StatusOK)
+	if err := call(context.Background(), client, http.MethodGet); err != nil {
+		t.Fatalf("breaker is not closed: %v", err)
+	}
+}
+
+func TestExecuteContextErrorsAreNotFailures(t *testing.T) {
+	u := newUpstream(t)
+	client := newTestClient(u, 1)
+	u.delay.Store(int64(time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := call(ctx, client, http.MethodGet); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error is %v, expected the context error", err)
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := call(cancelled, client, http.MethodGet); !errors.Is(err, context.Canceled) {
+		t.Fatalf("error is %v, expected the context error", err)
+	}
+
+	u.delay.Store(0)
+	if err := call(context.Background(), client, http.MethodGet); err != nil {
+		t.Fatalf("breaker opened by context errors: %v", err)
+	}
+}
+
+func TestExecuteTimeoutIsFailure(t *testing.T) {
+	u := newUpstream(t)
+	client := newTestClient(u, 1)
+	client.cfg.Timeout = 10 * time.Millisecond
+	client.cfg.Retries = 0
+	u.delay.Store(int64(time.Second))
+
+	if err := call(context.Background(), client, http.MethodGet); !errors.Is(err, ErrUpstreamDown) {
+		t.Fatalf("error is %v, expected upstream down", err)
+	}
+	u.delay.Store(0)
+	if err := call(context.Background(), client, http.MethodGet); !errors.Is(err, ErrUpstreamDown) || u.requests.Load() != 1 {
+		t.Fatalf("breaker is not opened by the timeout: %v", err)
+	}
+}
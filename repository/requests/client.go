@@ -0,0 +1,172 @@
+package requests
+
+import (
+	"GameService/config"
+	"GameService/logging"
+	"GameService/metrics"
+	"GameService/tracing"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-resty/resty/v2"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTPClient is a pooled client of an upstream shared by repositories. It limits every
+// call with a timeout, retries idempotent requests and stops calling failing endpoints.
+type HTTPClient struct {
+	client   *resty.Client
+	cfg      config.Upstream
+	mutex    sync.Mutex
+	breakers map[string]*breaker
+}
+
+// NewHTTPClient creates a client sending requests to baseURL.
+func NewHTTPClient(baseURL string, cfg config.Upstream) *HTTPClient {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	// Config keys are case-insensitive.
+	timeouts := make(map[string]time.Duration, len(cfg.CallTimeouts))
+	for call, timeout := range cfg.CallTimeouts {
+		timeouts[strings.ToLower(call)] = timeout
+	}
+	cfg.CallTimeouts = timeouts
+	return &HTTPClient{
+		client:   resty.New().SetBaseURL(baseURL).SetTransport(transport),
+		cfg:      cfg,
+		breakers: make(map[string]*breaker),
+	}
+}
+
+// execute sends request of the call and decodes response body into result when it is not nil.
+// Request is built by prepare on every attempt. GET requests are retried when upstream is down.
+// When ctx is done its error is returned as is and does not count as a failure of the upstream.
+func (c *HTTPClient) execute(ctx context.Context, call string, method string, url string,
+	prepare func(r *resty.Request), result interface{}) (err error) {
+	ctx, done := startCall(ctx, call)
+	defer done(&err)
+
+	breaker := c.breaker(call)
+	var body []byte
+	for attempt := 0; ; attempt++ {
+		if !breaker.allow() {
+			return &UpstreamError{Call: call, Kind: ErrUpstreamDown, Err: errors.New("circuit breaker is open")}
+		}
+		body, err = c.attempt(ctx, call, method, url, prepare)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			breaker.release()
+			return ctxErr
+		}
+		breaker.record(!errors.Is(err, ErrUpstreamDown))
+		if err == nil {
+			break
+		}
+		if method != http.MethodGet || !errors.Is(err, ErrUpstreamDown) || attempt >= c.cfg.Retries {
+			return err
+		}
+		logging.FromContext(ctx).WithError(err).WithField("attempt", attempt+1).Warn("retrying upstream call")
+		select {
+		case <-time.After(c.backoff(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, result); err != nil {
+		return &UpstreamError{Call: call, Kind: ErrDecode, Err: err}
+	}
+	return nil
+}
+
+// attempt sends request once and classifies the response.
+func (c *HTTPClient) attempt(ctx context.Context, call string, method string, url string,
+	prepare func(r *resty.Request)) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout(call))
+	defer cancel()
+
+	request := newRequest(ctx, c.client)
+	prepare(request)
+	resp, err := request.Execute(method, url)
+	if err != nil {
+		return nil, &UpstreamError{Call: call, Kind: ErrUpstreamDown, Err: err}
+	}
+	logResponse(ctx, call, resp)
+
+	status := resp.StatusCode()
+	switch {
+	case status == http.StatusNotFound:
+		return nil, &UpstreamError{Call: call, Status: status, Kind: ErrNotFound}
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return nil, &UpstreamError{Call: call, Status: status, Kind: ErrUnauthorized}
+	case status == http.StatusTooManyRequests || status >= http.StatusInternalServerError:
+		return nil, &UpstreamError{Call: call, Status: status, Kind: ErrUpstreamDown}
+	case status >= http.StatusBadRequest:
+		return nil, &UpstreamError{Call: call, Status: status, Kind: ErrRejected}
+	}
+	return resp.Body(), nil
+}
+
+func (c *HTTPClient) timeout(call string) time.Duration {
+	if timeout, ok := c.cfg.CallTimeouts[strings.ToLower(call)]; ok {
+		return timeout
+	}
+	return c.cfg.Timeout
+}
+
+// backoff returns exponential wait time with jitter before the next attempt.
+func (c *HTTPClient) backoff(attempt int) time.Duration {
+	wait := c.cfg.RetryWait << attempt
+	if wait <= 0 || wait > c.cfg.RetryMaxWait {
+		wait = c.cfg.RetryMaxWait
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+func (c *HTTPClient) breaker(call string) *breaker {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	b, ok := c.breakers[call]
+	if !ok {
+		b = newBreaker(call, c.cfg.BreakerThreshold, c.cfg.BreakerOpenDuration)
+		c.breakers[call] = b
+	}
+	return b
+}
+
+// startCall starts a span for the upstream call and returns function finishing it.
+// The function must be deferred with a pointer to the named error result.
+func startCall(ctx context.Context, name string) (context.Context, func(err *error)) {
+	start := time.Now()
+	ctx, span := tracing.Tracer().Start(ctx, "requests."+name, trace.WithSpanKind(trace.SpanKindClient))
+	return ctx, func(err *error) {
+		metrics.ObserveUpstream(name, start, err)
+		tracing.End(span, *err)
+	}
+}
+
+// newRequest creates a request bound to ctx with propagated trace headers and request ID.
+func newRequest(ctx context.Context, client *resty.Client) *resty.Request {
+	request := client.R().SetContext(ctx).SetHeaders(tracing.Headers(ctx))
+	if requestId := logging.RequestID(ctx); requestId != "" {
+		request.SetHeader(logging.RequestIDHeader, requestId)
+	}
+	return request
+}
+
+// logResponse writes response status to debug log. Bodies are never logged as they contain personal data.
+func logResponse(ctx context.Context, call string, resp *resty.Response) {
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"call":     call,
+		"status":   resp.StatusCode(),
+		"duration": resp.Time(),
+	}).Debug("upstream response")
+}
@@ -0,0 +1,42 @@
+package requests
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of upstream errors. Check them with errors.Is.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrRejected     = errors.New("request rejected")
+	ErrUpstreamDown = errors.New("upstream is down")
+	ErrDecode       = errors.New("cannot decode response")
+)
+
+// UpstreamError is returned by repository calls when upstream request fails.
+type UpstreamError struct {
+	Call   string
+	Status int
+	Kind   error
+	Err    error
+}
+
+func (e *UpstreamError) Error() string {
+	message := e.Call + ": " + e.Kind.Error()
+	if e.Status != 0 {
+		message += fmt.Sprintf(" (status %d)", e.Status)
+	}
+	if e.Err != nil {
+		message += ": " + e.Err.Error()
+	}
+	return message
+}
+
+func (e *UpstreamError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *UpstreamError) Unwrap() error {
+	return e.Err
+}
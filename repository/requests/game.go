@@ -3,83 +3,58 @@ package requests
 import (
 	"GameService/repository/endpoints"
 	"GameService/repository/models"
-	"encoding/json"
+	"context"
 	"github.com/go-resty/resty/v2"
 	"github.com/google/uuid"
+	"net/http"
 )
 
 type GameRepo struct {
+	client *HTTPClient
 	apiKey string
 }
 
-func NewGameRepo(apiKey string) Game {
-	return &GameRepo{apiKey: apiKey}
+func NewGameRepo(client *HTTPClient, apiKey string) Game {
+	return &GameRepo{client: client, apiKey: apiKey}
 }
 
-func (s *GameRepo) GetResults(gameId uuid.UUID) (results models.GetResultsResponse, err error) {
-	client := resty.New()
-	resp, err := client.R().
-		SetHeader("X-API-Key", s.apiKey).SetPathParam("id", gameId.String()).Get(endpoints.GetResultsURL)
-	if err != nil {
-		return results, err
-	}
-	err = json.Unmarshal(resp.Body(), &results)
-	if err != nil {
-		return results, err
-	}
-
+func (s *GameRepo) GetResults(ctx context.Context, gameId uuid.UUID) (results models.GetResultsResponse, err error) {
+	err = s.client.execute(ctx, "GetResults", http.MethodGet, endpoints.GetResultsURL, func(r *resty.Request) {
+		r.SetHeader("X-API-Key", s.apiKey).SetPathParam("id", gameId.String())
+	}, &results)
 	return results, err
 }
 
-func (s *GameRepo) SaveResults(id uuid.UUID, results []models.Rates) error {
-	client := resty.New()
-	var _, err = client.R().
-		SetHeader("X-API-Key", s.apiKey).
-		SetBody(map[string]interface{}{"results": results}).
-		SetPathParam("id", id.String()).Post(endpoints.SaveResultsURL)
-	if err != nil {
-		return err
-	}
-
-	return nil
+func (s *GameRepo) SaveResults(ctx context.Context, id uuid.UUID, results []models.Rates) error {
+	return s.client.execute(ctx, "SaveResults", http.MethodPost, endpoints.SaveResultsURL, func(r *resty.Request) {
+		r.SetHeader("X-API-Key", s.apiKey).
+			SetBody(map[string]interface{}{"results": results}).
+			SetPathParam("id", id.String())
+	}, nil)
 }
 
-func (s *GameRepo) EndGame(id uuid.UUID) error {
-	client := resty.New()
-	var _, err = client.R().
-		SetHeader("X-API-Key", s.apiKey).
-		SetPathParam("id", id.String()).Patch(endpoints.EndGameURL)
-	if err != nil {
-		return err
-	}
-
-	return nil
+func (s *GameRepo) EndGame(ctx context.Context, id uuid.UUID) error {
+	return s.client.execute(ctx, "EndGame", http.MethodPatch, endpoints.EndGameURL, func(r *resty.Request) {
+		r.SetHeader("X-API-Key", s.apiKey).SetPathParam("id", id.String())
+	}, nil)
 }
 
-func (s *GameRepo) StartGame(id uuid.UUID) error {
-	client := resty.New()
-	var _, err = client.R().
-		SetHeader("X-API-Key", s.apiKey).
-		SetPathParam("id", id.String()).Patch(endpoints.StartGameURL)
-	if err != nil {
-		return err
-	}
-	return nil
+func (s *GameRepo) StartGame(ctx context.Context, id uuid.UUID) error {
+	return s.client.execute(ctx, "StartGame", http.MethodPatch, endpoints.StartGameURL, func(r *resty.Request) {
+		r.SetHeader("X-API-Key", s.apiKey).SetPathParam("id", id.String())
+	}, nil)
 }
 
-func (s *GameRepo) GetGame(id uuid.UUID) (game models.Game, err error) {
-	client := resty.New()
-	resp, err := client.R().
-		SetHeader("X-API-Key", s.apiKey).SetPathParam("id", id.String()).Get(endpoints.GetGameURL)
-	if err != nil {
-		return game, err
-	}
-
-	println(string(resp.Body()))
-	err = json.Unmarshal(resp.Body(), &game)
-	if err != nil {
-		return game, err
-	}
-
+func (s *GameRepo) GetGame(ctx context.Context, id uuid.UUID) (game models.Game, err error) {
+	err = s.client.execute(ctx, "GetGame", http.MethodGet, endpoints.GetGameURL, func(r *resty.Request) {
+		r.SetHeader("X-API-Key", s.apiKey).SetPathParam("id", id.String())
+	}, &game)
 	return game, err
 }
+
+func (s *GameRepo) GetGameAccess(ctx context.Context, id uuid.UUID) (access models.GameAccess, err error) {
+	err = s.client.execute(ctx, "GetGameAccess", http.MethodGet, endpoints.GetGameAccessURL, func(r *resty.Request) {
+		r.SetHeader("X-API-Key", s.apiKey).SetPathParam("id", id.String())
+	}, &access)
+	return access, err
+}
@@ -1,84 +1,86 @@
 package requests
 
 import (
+	"GameService/config"
 	"GameService/repository/endpoints"
 	"GameService/repository/models"
-	"encoding/json"
+	"context"
+	"errors"
 	"github.com/go-resty/resty/v2"
 	"net/http"
 	"strconv"
+	"sync"
 )
 
 type MeetingRepo struct {
+	api          *HTTPClient
+	oauth        *HTTPClient
+	mutex        sync.Mutex
 	accessToken  string
 	refreshToken string
 	clientId     string
 	clientSecret string
 }
 
-func (r *MeetingRepo) CreateMeeting() (meetingNumber string, passcode string, err error) {
-	client := resty.New()
-	resp, err := client.R().
-		SetAuthToken(r.accessToken).
-		SetBody(models.CreateMeetingRequest{
-			Topic:    "Game meeting",
-			Type:     2,
-			Settings: models.Settings{},
-		}).
-		SetPathParam(
-			"user_id", "me").Post(endpoints.CreateMeetingURL)
-	if err != nil {
-		return "", "", err
-	}
-	if resp.RawResponse.StatusCode == http.StatusUnauthorized {
-		err := r.refreshAccessToken()
-		if err != nil {
-			return "", "", err
-		}
-		resp, err = client.R().
-			SetAuthToken(r.accessToken).
-			SetBody(models.CreateMeetingRequest{
-				Topic:    "Game meeting",
-				Type:     2,
-				Settings: models.Settings{},
-			}).
-			SetPathParam(
-				"user_id", "me").Post(endpoints.CreateMeetingURL)
+func (r *MeetingRepo) CreateMeeting(ctx context.Context) (meetingNumber string, passcode string, err error) {
+	meeting, err := r.createMeeting(ctx)
+	if errors.Is(err, ErrUnauthorized) {
+		err = r.refreshAccessToken(ctx)
 		if err != nil {
 			return "", "", err
 		}
+		meeting, err = r.createMeeting(ctx)
 	}
-	var meeting models.CreateMeetingResponse
-	err = json.Unmarshal(resp.Body(), &meeting)
 	if err != nil {
 		return "", "", err
 	}
 
-	return strconv.FormatInt(meeting.Id, 10), meeting.Password, err
+	return strconv.FormatInt(meeting.Id, 10), meeting.Password, nil
+}
 
+func (r *MeetingRepo) createMeeting(ctx context.Context) (meeting models.CreateMeetingResponse, err error) {
+	accessToken := r.getAccessToken()
+	err = r.api.execute(ctx, "CreateMeeting", http.MethodPost, endpoints.CreateMeetingURL, func(request *resty.Request) {
+		request.SetAuthToken(accessToken).
+			SetBody(models.CreateMeetingRequest{
+				Topic:    "Game meeting",
+				Type:     2,
+				Settings: models.Settings{},
+			}).
+			SetPathParam("user_id", "me")
+	}, &meeting)
+	return meeting, err
 }
 
-func (r *MeetingRepo) refreshAccessToken() error {
-	client := resty.New()
-	resp, err := client.R().
-		SetBasicAuth(r.clientId, r.clientSecret).
-		SetQueryParams(map[string]string{"grant_type": "refresh_token", "refresh_token": r.refreshToken}).
-		Post(endpoints.RefreshTokenURL)
-	if err != nil {
-		return err
-	}
+func (r *MeetingRepo) refreshAccessToken(ctx context.Context) error {
 	var authData models.AuthData
-	err = json.Unmarshal(resp.Body(), &authData)
+	err := r.oauth.execute(ctx, "RefreshAccessToken", http.MethodPost, endpoints.RefreshTokenURL, func(request *resty.Request) {
+		request.SetBasicAuth(r.clientId, r.clientSecret).
+			SetQueryParams(map[string]string{"grant_type": "refresh_token", "refresh_token": r.refreshToken})
+	}, &authData)
 	if err != nil {
 		return err
 	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 	r.accessToken = authData.AccessToken
 	return nil
 }
 
-func NewMeetingRepo(accessToken string,
-	refreshToken string,
-	clientId string,
-	clientSecret string) Meeting {
-	return &MeetingRepo{accessToken: accessToken, refreshToken: refreshToken, clientId: clientId, clientSecret: clientSecret}
+func (r *MeetingRepo) getAccessToken() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.accessToken
+}
+
+func NewMeetingRepo(api *HTTPClient, oauth *HTTPClient, cfg config.Zoom) Meeting {
+	return &MeetingRepo{
+		api:          api,
+		oauth:        oauth,
+		accessToken:  cfg.AccessToken,
+		refreshToken: cfg.RefreshToken,
+		clientId:     cfg.SDKKey,
+		clientSecret: cfg.SDKSecret,
+	}
 }
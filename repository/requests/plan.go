@@ -0,0 +1,25 @@
+package requests
+
+import (
+	"GameService/repository/endpoints"
+	"GameService/repository/models"
+	"context"
+	"github.com/go-resty/resty/v2"
+	"net/http"
+)
+
+type PlanRepo struct {
+	client *HTTPClient
+	apiKey string
+}
+
+func NewPlanRepo(client *HTTPClient, apiKey string) Plan {
+	return &PlanRepo{client: client, apiKey: apiKey}
+}
+
+func (s *PlanRepo) GetEntitlements(ctx context.Context, planType string) (entitlements models.Entitlements, err error) {
+	err = s.client.execute(ctx, "GetEntitlements", http.MethodGet, endpoints.GetEntitlementsURL, func(r *resty.Request) {
+		r.SetHeader("X-API-Key", s.apiKey).SetPathParam("type", planType)
+	}, &entitlements)
+	return entitlements, err
+}
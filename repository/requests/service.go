@@ -1,7 +1,10 @@
 package requests
 
 import (
+	"GameService/auth"
+	"GameService/config"
 	"GameService/repository/models"
+	"context"
 	"github.com/google/uuid"
 )
 
@@ -10,40 +13,73 @@ type Repository struct {
 	User
 	Topic
 	Meeting
+	Plan
+	Invalidator
 }
 
 type Game interface {
-	GetGame(id uuid.UUID) (models.Game, error)
-	SaveResults(id uuid.UUID, results []models.Rates) error
-	EndGame(id uuid.UUID) error
-	StartGame(id uuid.UUID) error
-	GetResults(gameId uuid.UUID) (results models.GetResultsResponse, err error)
+	GetGame(ctx context.Context, id uuid.UUID) (models.Game, error)
+	// GetGameAccess returns the access policy of the game, ErrNotFound when the game has none.
+	GetGameAccess(ctx context.Context, id uuid.UUID) (models.GameAccess, error)
+	SaveResults(ctx context.Context, id uuid.UUID, results []models.Rates) error
+	EndGame(ctx context.Context, id uuid.UUID) error
+	StartGame(ctx context.Context, id uuid.UUID) error
+	GetResults(ctx context.Context, gameId uuid.UUID) (results models.GetResultsResponse, err error)
 }
 type Topic interface {
-	GetTopic(id uuid.UUID) (models.Topic, error)
-	GetRandQuestionsWithLimit(topicId uuid.UUID, limit int) ([]models.Question, error)
-	GetRandTopicsWithLimit(limit int) (questions []models.Topic, err error)
+	GetTopic(ctx context.Context, id uuid.UUID) (models.Topic, error)
+	GetRandQuestionsWithLimit(ctx context.Context, topicId uuid.UUID, limit int) ([]models.Question, error)
+	// GetRandQuestionsPerTopic gets limit random questions of every topic. ConnectTeam has
+	// no multi-topic endpoint, so it costs one GetRandQuestionsWithLimit call per topic.
+	// Questions of failed topics are omitted and their errors are joined.
+	GetRandQuestionsPerTopic(ctx context.Context, topicIds []uuid.UUID, limit int) (map[uuid.UUID][]models.Question, error)
+	GetRandTopicsWithLimit(ctx context.Context, limit int) (questions []models.Topic, err error)
 }
 
 type User interface {
 	ParseToken(token string) (id uuid.UUID, access string, err error)
-	GetUserById(id uuid.UUID) (user models.User, err error)
-	GetCreatorPlan(id uuid.UUID) (plan models.UserPlan, err error)
+	GetUserById(ctx context.Context, id uuid.UUID) (user models.User, err error)
+	GetCreatorPlan(ctx context.Context, id uuid.UUID) (plan models.UserPlan, err error)
+}
+
+type Plan interface {
+	GetEntitlements(ctx context.Context, planType string) (entitlements models.Entitlements, err error)
 }
 
 type Meeting interface {
-	CreateMeeting() (meetingNumber string, passcode string, err error)
+	CreateMeeting(ctx context.Context) (meetingNumber string, passcode string, err error)
+}
+
+// Invalidator drops cached responses of ConnectTeam, see package cache.
+type Invalidator interface {
+	// InvalidateUser drops the user and their active plan.
+	InvalidateUser(id uuid.UUID)
+	InvalidateTopic(id uuid.UUID)
+	// InvalidatePlan drops entitlements of the plan type.
+	InvalidatePlan(planType string)
+	Purge()
 }
 
-func NewHTTPService(apiKey string,
-	accessToken string,
-	refreshToken string,
-	clientId string,
-	clientSecret string) *Repository {
+// NoCache is Invalidator of a repository without cache.
+type NoCache struct{}
+
+func (NoCache) InvalidateUser(uuid.UUID)  {}
+func (NoCache) InvalidateTopic(uuid.UUID) {}
+func (NoCache) InvalidatePlan(string)     {}
+func (NoCache) Purge()                    {}
+
+func NewHTTPService(cfg *config.Config) *Repository {
+	connectTeam := NewHTTPClient(cfg.Upstream.ConnectTeamURL, cfg.Upstream)
+	apiKey := cfg.Upstream.APIKey
 	return &Repository{
-		Game:    NewGameRepo(apiKey),
-		User:    NewUserService(apiKey),
-		Topic:   NewTopicRepo(apiKey),
-		Meeting: NewMeetingRepo(accessToken, refreshToken, clientId, clientSecret),
+		Game:  NewGameRepo(connectTeam, apiKey),
+		User:  NewUserService(connectTeam, apiKey, auth.NewVerifier(cfg.Auth)),
+		Topic: NewTopicRepo(connectTeam, apiKey),
+		Meeting: NewMeetingRepo(
+			NewHTTPClient(cfg.Zoom.APIURL, cfg.Upstream),
+			NewHTTPClient(cfg.Zoom.OAuthURL, cfg.Upstream),
+			cfg.Zoom),
+		Plan:        NewPlanRepo(connectTeam, apiKey),
+		Invalidator: NoCache{},
 	}
 }
@@ -3,71 +3,74 @@ package requests
 import (
 	"GameService/repository/endpoints"
 	"GameService/repository/models"
-	"encoding/json"
+	"context"
+	"errors"
+	"fmt"
 	"github.com/go-resty/resty/v2"
 	"github.com/google/uuid"
+	"net/http"
 	"strconv"
+	"sync"
 )
 
 type TopicRepo struct {
+	client *HTTPClient
 	apiKey string
 }
 
-func NewTopicRepo(apiKey string) Topic {
-	return &TopicRepo{apiKey: apiKey}
+func NewTopicRepo(client *HTTPClient, apiKey string) Topic {
+	return &TopicRepo{client: client, apiKey: apiKey}
 }
 
-func (s *TopicRepo) GetRandQuestionsWithLimit(topicId uuid.UUID, limit int) (questions []models.Question, _ error) {
-	client := resty.New()
-	println(topicId.String())
-	var resp, err = client.R().
-		SetHeader("X-API-Key", s.apiKey).
-		SetQueryParams(map[string]string{
-			"topic_id": topicId.String(),
-			"limit":    strconv.Itoa(limit),
-		}).
-		Get(endpoints.GetRandQuestionsURL)
-	if err != nil {
-		return questions, err
-	}
-
-	err = json.Unmarshal(resp.Body(), &questions)
-	println(string(resp.Body()))
-	if err != nil {
-		return questions, err
-	}
-
+func (s *TopicRepo) GetRandQuestionsWithLimit(ctx context.Context, topicId uuid.UUID, limit int) (questions []models.Question, err error) {
+	err = s.client.execute(ctx, "GetRandQuestionsWithLimit", http.MethodGet, endpoints.GetRandQuestionsURL, func(r *resty.Request) {
+		r.SetHeader("X-API-Key", s.apiKey).
+			SetQueryParams(map[string]string{
+				"topic_id": topicId.String(),
+				"limit":    strconv.Itoa(limit),
+			})
+	}, &questions)
 	return questions, err
 }
 
-func (s *TopicRepo) GetRandTopicsWithLimit(limit int) (topics []models.Topic, err error) {
-	client := resty.New()
-	resp, err := client.R().
-		SetHeader("X-API-Key", s.apiKey).SetPathParam("limit", strconv.Itoa(limit)).Get(endpoints.GetRandTopicsURL)
-	if err != nil {
-		return topics, err
-	}
-
-	err = json.Unmarshal(resp.Body(), &topics)
-	if err != nil {
-		return topics, err
+// GetRandQuestionsPerTopic fans out one GetRandQuestionsWithLimit request per topic
+// concurrently. Questions of failed topics are omitted and their errors are joined.
+func (s *TopicRepo) GetRandQuestionsPerTopic(ctx context.Context, topicIds []uuid.UUID, limit int) (map[uuid.UUID][]models.Question, error) {
+	var (
+		wg        sync.WaitGroup
+		mutex     sync.Mutex
+		errs      []error
+		questions = make(map[uuid.UUID][]models.Question, len(topicIds))
+	)
+	for _, topicId := range topicIds {
+		wg.Add(1)
+		go func(topicId uuid.UUID) {
+			defer wg.Done()
+			topicQuestions, err := s.GetRandQuestionsWithLimit(ctx, topicId, limit)
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("topic %s: %w", topicId, err))
+				return
+			}
+			questions[topicId] = topicQuestions
+		}(topicId)
 	}
+	wg.Wait()
+	return questions, errors.Join(errs...)
+}
 
+func (s *TopicRepo) GetRandTopicsWithLimit(ctx context.Context, limit int) (topics []models.Topic, err error) {
+	err = s.client.execute(ctx, "GetRandTopicsWithLimit", http.MethodGet, endpoints.GetRandTopicsURL, func(r *resty.Request) {
+		r.SetHeader("X-API-Key", s.apiKey).SetPathParam("limit", strconv.Itoa(limit))
+	}, &topics)
 	return topics, err
 }
-func (s *TopicRepo) GetTopic(id uuid.UUID) (topic models.Topic, err error) {
-	topic.Id = id
-	client := resty.New()
-	resp, err := client.R().
-		SetHeader("X-API-Key", s.apiKey).SetPathParam("id", id.String()).Get(endpoints.GetTopicWithIdURL)
-	if err != nil {
-		return topic, err
-	}
-
-	err = json.Unmarshal(resp.Body(), &topic)
-	if err != nil {
-		return topic, err
-	}
 
+func (s *TopicRepo) GetTopic(ctx context.Context, id uuid.UUID) (topic models.Topic, err error) {
+	topic.Id = id
+	err = s.client.execute(ctx, "GetTopic", http.MethodGet, endpoints.GetTopicWithIdURL, func(r *resty.Request) {
+		r.SetHeader("X-API-Key", s.apiKey).SetPathParam("id", id.String())
+	}, &topic)
 	return topic, err
 }